@@ -0,0 +1,127 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+)
+
+// runAdopt moves existing files into the dotfiles location and links them back
+func runAdopt(args []string, configFilePath string) (err error) {
+	fs := flag.NewFlagSet("adopt", flag.ExitOnError)
+	into := fs.String("into", "", "Directory to move files into (instead of the config target)")
+	fs.StringVar(&configFilePath, "config", configFilePath, "Config file used to look up targets")
+	fs.BoolVar(dryRun, "dry-run", *dryRun, "Show what would be done without making changes")
+	fs.Usage = func() {
+		fmt.Println("Usage: symlinker adopt [flags] <path>...")
+		fmt.Println("\nMove existing files into the dotfiles repo and replace them with symlinks.")
+		fmt.Println("\nFlags:")
+		fs.PrintDefaults()
+	}
+	paths := parseArgs(fs, args)
+
+	if len(paths) == 0 {
+		fs.Usage()
+		return fmt.Errorf("adopt requires at least one path")
+	}
+
+	if !*dryRun {
+		unlock, err := acquireLock()
+		if err != nil {
+			return err
+		}
+		defer unlock()
+	}
+
+	st, err := loadState()
+	if err != nil {
+		return err
+	}
+
+	j := newJournal("adopt", st)
+	defer func() {
+		if saveErr := j.save(); saveErr != nil && err == nil {
+			err = saveErr
+		}
+		if saveErr := st.save(); saveErr != nil && err == nil {
+			err = saveErr
+		}
+	}()
+
+	// Config is only needed when targets are derived from it
+	var entries []entry
+	if *into == "" {
+		if entries, err = parseConfig(configFilePath); err != nil {
+			return err
+		}
+	}
+
+	for _, arg := range paths {
+		if err := adoptPath(arg, *into, entries, st, j, *dryRun); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// adoptPath moves a single file or directory to its target and symlinks it back
+func adoptPath(path, into string, entries []entry, st *state, j *journal, dryRun bool) error {
+	symlinkPath, err := filepath.Abs(expandPath(path))
+	if err != nil {
+		return fmt.Errorf("error resolving path %s: %w", path, err)
+	}
+
+	info, err := os.Lstat(symlinkPath)
+	if err != nil {
+		return fmt.Errorf("error reading %s: %w", symlinkPath, err)
+	}
+	if info.Mode()&os.ModeSymlink != 0 {
+		return fmt.Errorf("%s is already a symlink", symlinkPath)
+	}
+
+	// Work out where the file should live
+	var targetPath, config string
+	inConfig := false
+	if into != "" {
+		targetPath = filepath.Join(expandPath(into), filepath.Base(symlinkPath))
+	} else if e, ok := findEntry(entries, symlinkPath); ok {
+		targetPath = e.target
+		config = e.config
+		inConfig = true
+	} else {
+		return fmt.Errorf("no config entry for %s (use --into to choose a destination)", symlinkPath)
+	}
+
+	if _, err := os.Lstat(targetPath); err == nil {
+		return fmt.Errorf("target already exists: %s", targetPath)
+	}
+
+	// Move the original into place
+	targetDir := filepath.Dir(targetPath)
+	if err := ensureDirExists(targetDir, defaultOwnership(), dryRun, j); err != nil {
+		return fmt.Errorf("error creating directory %s: %w", targetDir, err)
+	}
+
+	if dryRun {
+		infof("[DRY RUN] Would move: %s -> %s\n", contractPath(symlinkPath), contractPath(targetPath))
+		infof("[DRY RUN] Would create symlink: %s -> %s\n", contractPath(symlinkPath), contractPath(targetPath))
+	} else {
+		infof("Moving: %s -> %s\n", contractPath(symlinkPath), contractPath(targetPath))
+		if err := auditMove(symlinkPath, targetPath); err != nil {
+			return fmt.Errorf("error moving %s: %w", symlinkPath, err)
+		}
+		j.add(journalOp{Op: opMove, Path: symlinkPath, Target: targetPath})
+
+		// Link it back
+		if _, err := createSymlink(targetPath, symlinkPath, applyOptions{}, j); err != nil {
+			return fmt.Errorf("error creating symlink %s: %w", symlinkPath, err)
+		}
+		st.record(managedLink{Link: symlinkPath, Target: targetPath, Config: config})
+	}
+
+	if !inConfig {
+		fmt.Printf("Add to your config: %s %s\n", quoteField(contractPath(symlinkPath)), quoteField(contractPath(targetPath)))
+	}
+	return nil
+}
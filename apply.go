@@ -0,0 +1,808 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strconv"
+	"strings"
+
+	"github.com/frizadiga/symlinker/pkg/symlinker"
+)
+
+// Conflict policies for when something other than the desired link exists at a link path
+const (
+	conflictOverwrite = "overwrite"
+	conflictSkip      = "skip"
+	conflictBackup    = "backup"
+	conflictAsk       = "ask"
+)
+
+// validateConflictPolicy checks an --on-conflict or on-conflict= value
+func validateConflictPolicy(policy string) error {
+	switch policy {
+	case conflictOverwrite, conflictSkip, conflictBackup, conflictAsk:
+		return nil
+	}
+	return fmt.Errorf("invalid conflict policy %q (want overwrite, skip, backup or ask)", policy)
+}
+
+// applyOptions controls how entries are applied
+type applyOptions struct {
+	dryRun          bool
+	backup          bool   // move displaced files and directories to backupDir instead of deleting them
+	backupDir       string // this run's backup directory
+	trash           bool   // move displaced files and directories to the trash instead of deleting them
+	onConflict      string // what to do when something already exists at a link path
+	interactive     bool   // ask before each change
+	force           bool   // delete non-empty directories and large files in the way
+	requireTarget   bool   // fail instead of warning when an actual path doesn't exist
+	createTargets   bool   // create missing actual paths as empty files or directories
+	relative        bool   // link to actual paths relative to the link's directory
+	mode            string // link mode for the entry being applied; empty means symlink
+	copyFallback    bool   // copy actual paths where symlinks can't be created
+	sudo            bool   // re-run entries denied for lack of permission via sudo without asking
+	root            string // directory link paths are placed under, as with DESTDIR
+	rootTargets     bool   // place actual paths under root too
+	strict          bool   // refuse to apply a config with conflicting entries
+	continueOnError bool   // apply the remaining entries after one fails
+	output          string // format of the report written when the run ends
+	pre, post       string // commands run before and after the entries are applied
+	notify          string // which runs end with a desktop notification
+	changedExitCode bool   // return errChanged when the run changed, or would change, something
+}
+
+// optionsFromFlags builds the apply options from the command line flags
+func optionsFromFlags() (applyOptions, error) {
+	if backup.enabled && *trash {
+		return applyOptions{}, withExitCode(exitConfig, fmt.Errorf("--backup and --trash can't be used together"))
+	}
+	if err := validateConflictPolicy(*onConflict); err != nil {
+		return applyOptions{}, withExitCode(exitConfig, err)
+	}
+	if err := validateNotify(*notifyWhen); err != nil {
+		return applyOptions{}, withExitCode(exitConfig, err)
+	}
+
+	// The directory is only created if something is actually backed up
+	backupDir, err := runBackupDir(backupFlag{enabled: true, dir: backup.dir})
+	if err != nil {
+		return applyOptions{}, err
+	}
+	return applyOptions{
+		dryRun:          *dryRun,
+		backup:          backup.enabled,
+		backupDir:       backupDir,
+		trash:           *trash,
+		onConflict:      *onConflict,
+		interactive:     interactive,
+		force:           *force,
+		requireTarget:   *requireTarget,
+		createTargets:   *createTargets,
+		relative:        *relative,
+		copyFallback:    *copyFallback,
+		sudo:            *sudo,
+		root:            *rootDir,
+		rootTargets:     *rootTargets,
+		strict:          *strict,
+		continueOnError: *continueOnError,
+		output:          *outputFormat,
+		pre:             *preCommand,
+		post:            *postCommand,
+		notify:          *notifyWhen,
+	}, nil
+}
+
+// nextRun returns the options for another run with the same flags, as watch and daemon
+// make over and over: each run backs up into a directory of its own, since a path backed
+// up in an earlier run would clash with its backup there
+func (o applyOptions) nextRun() (applyOptions, error) {
+	dir, err := runBackupDir(backupFlag{enabled: true, dir: backup.dir})
+	if err != nil {
+		return o, err
+	}
+	o.backupDir = dir
+	return o, nil
+}
+
+// ensureDirExists creates a directory if it doesn't exist, giving the levels it creates
+// the mode and owner in own
+func ensureDirExists(path string, own ownership, dryRun bool, j *journal) error {
+	if _, err := os.Stat(path); os.IsNotExist(err) {
+		if dryRun {
+			infof("[DRY RUN] Would create directory: %s\n", path)
+			return nil
+		}
+		infof("Creating directory: %s\n", path)
+
+		// Journal each missing level so undo can remove them again
+		var missing []string
+		parent := path
+		for ; ; parent = filepath.Dir(parent) {
+			if _, err := os.Lstat(parent); err == nil || filepath.Dir(parent) == parent {
+				break
+			}
+			missing = append(missing, parent)
+		}
+
+		// New directories are never more open than the one they are made in, so
+		// nothing created under ~/.ssh or ~/.gnupg loosens their 0700
+		mode := own.dirMode
+		if info, err := os.Stat(parent); err == nil && !own.exact {
+			mode = mode&info.Mode().Perm() | 0700
+		}
+		if err := os.MkdirAll(path, mode); err != nil {
+			return err
+		}
+		for i := len(missing) - 1; i >= 0; i-- {
+			j.add(journalOp{Op: opMkdir, Path: missing[i]})
+			if own.exact {
+				if err := os.Chmod(missing[i], own.dirMode); err != nil {
+					return err
+				}
+			}
+			if own.changesOwner() {
+				if err := os.Lchown(missing[i], own.uid, own.gid); err != nil {
+					warnf("can't set owner of %s: %s\n", missing[i], err)
+				}
+			}
+		}
+	}
+	return nil
+}
+
+// opUnchanged is the operation createSymlink returns when the link was already in place;
+// it is never journaled
+const opUnchanged = "unchanged"
+
+// createSymlink creates a symbolic link (or a hard link or copy, per opts.mode), returning
+// the journal operation describing it
+func createSymlink(targetPath, symlinkPath string, opts applyOptions, j *journal) (journalOp, error) {
+	dryRun := opts.dryRun
+	op := journalOp{Op: opCreate, Path: symlinkPath, Target: targetPath, Mode: opts.mode}
+	if verbosity >= levelDebug {
+		if current, err := os.Readlink(symlinkPath); err == nil {
+			debugf("Existing symlink %s points to %s, want %s\n", symlinkPath, current, targetPath)
+		}
+	}
+
+	// Leave links that already point at the right target alone; a dry run lists
+	// what it would do at the end instead
+	if isOwnLink(symlinkPath, targetPath, opts.mode, j.st) {
+		if !dryRun {
+			verbosef("%s\n", paint(styleSkip, fmt.Sprintf("Unchanged: %s -> %s", symlinkPath, targetPath)))
+		}
+		if writesFile(opts.mode) {
+			op.Hash, _ = copyHash(symlinkPath)
+		}
+		if opts.mode == modeDecrypt {
+			op.Source, _ = targetHash(targetPath)
+		}
+		op.Op = opUnchanged
+		return op, nil
+	}
+
+	// Find out whether the link can be made before removing anything
+	if err := checkLink(targetPath, filepath.Dir(symlinkPath), opts.mode); err != nil {
+		return op, err
+	}
+
+	// Check if existing symlink or file exists
+	existing, err := inspectLink(symlinkPath, targetPath)
+	if err != nil {
+		return op, err
+	}
+	if existing.Kind != symlinker.ActionCreate {
+		op.Op = opReplace
+		op.Kind, op.Previous = existing.Existing, existing.Previous
+		if existing.Kind == symlinker.ActionUnchanged {
+			// A symlink to the actual path, where a copy or another kind of link goes
+			op.Kind, op.Previous = "symlink", targetPath
+		}
+		info, err := linkFS.Lstat(symlinkPath)
+		if err != nil {
+			return op, err
+		}
+
+		switch {
+		case opts.backup && op.Kind != "symlink":
+			// Keep real files and directories instead of destroying them
+			if op.Backup, err = backupExisting(opts.backupDir, symlinkPath, dryRun); err != nil {
+				return op, err
+			}
+		case opts.trash && op.Kind != "symlink":
+			if op.Trashed, err = moveToTrash(symlinkPath, dryRun); err != nil {
+				return op, err
+			}
+		case !opts.force && wasEdited(symlinkPath, j.st):
+			return op, withExitCode(exitConflict, fmt.Errorf("refusing to overwrite %s: it was edited since symlinker wrote it (use --force, --backup or --trash)", symlinkPath))
+		case !opts.force && preciousReason(symlinkPath, info) != "":
+			return op, withExitCode(exitConflict, fmt.Errorf("refusing to remove %s: %s (use --force, --backup or --trash)", symlinkPath, preciousReason(symlinkPath, info)))
+		case dryRun:
+			// The plan printed at the end shows what would be replaced
+		default:
+			infof("%s %s\n", paint(styleReplace, "Removing existing:"), symlinkPath)
+			if err := auditRemove(symlinkPath, linkFS.RemoveAll); err != nil {
+				return op, fmt.Errorf("error removing existing path: %w", err)
+			}
+		}
+	}
+
+	// Create the symlink
+	if dryRun {
+		return op, nil
+	}
+
+	infof("%s %s -> %s\n", paint(styleCreate, "Creating "+linkNoun(opts.mode)+":"), symlinkPath, targetPath)
+	if err := makeLink(targetPath, symlinkPath, opts.mode); err != nil {
+		return op, err
+	}
+	if writesFile(opts.mode) {
+		op.Hash, _ = copyHash(symlinkPath)
+	}
+	if opts.mode == modeDecrypt {
+		op.Source, _ = targetHash(targetPath)
+	}
+	j.add(op)
+	return op, nil
+}
+
+// largeFileSize is the size above which an existing file is only deleted with --force
+const largeFileSize = 1 << 20
+
+// preciousReason explains why deleting an existing path needs --force,
+// or returns "" if it can be deleted freely
+func preciousReason(path string, info os.FileInfo) string {
+	if info.Mode().IsRegular() {
+		if info.Size() > largeFileSize {
+			return fmt.Sprintf("it is a file of %d bytes", info.Size())
+		}
+		return ""
+	}
+	if !info.IsDir() {
+		return ""
+	}
+
+	dir, err := os.Open(path)
+	if err != nil {
+		return "it is a directory that can't be read"
+	}
+	defer dir.Close()
+	if names, _ := dir.Readdirnames(1); len(names) > 0 {
+		return "it is a non-empty directory"
+	}
+	return ""
+}
+
+// entryFlag returns a per-entry boolean option, or def if the entry doesn't set it
+func entryFlag(e entry, name string, def bool) bool {
+	if v, ok := e.options[name]; ok {
+		on, _ := strconv.ParseBool(v)
+		return on
+	}
+	return def
+}
+
+// createTarget creates a missing actual path: a directory if the config
+// wrote it with a trailing slash, an empty file otherwise
+func createTarget(e entry, path string, dryRun bool, j *journal) error {
+	if strings.HasSuffix(e.rawTarget, "/") || strings.HasSuffix(e.rawTarget, string(filepath.Separator)) {
+		return ensureDirExists(filepath.Clean(path), defaultOwnership(), dryRun, j)
+	}
+
+	if err := ensureDirExists(filepath.Dir(path), defaultOwnership(), dryRun, j); err != nil {
+		return err
+	}
+	if dryRun {
+		infof("[DRY RUN] Would create empty file: %s\n", path)
+		return nil
+	}
+	infof("Creating empty file: %s\n", path)
+	file, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0644)
+	if err != nil {
+		return err
+	}
+	j.add(journalOp{Op: opTouch, Path: path})
+	return file.Close()
+}
+
+// setLinkOwner gives a newly created link the owner in own: copies throughout, and the
+// link itself when running as root (only root can hand files to someone else). A hard
+// link is its actual path, so --user doesn't give those away.
+func setLinkOwner(path, mode string, own ownership) {
+	var err error
+	switch {
+	case mode == modeCopy:
+		err = chownTree(path, own)
+	case mode == modeHardlink && switchedUser != nil && own == defaultOwnership():
+		verbosef("Leaving the owner of hard link %s alone, since it is the actual path's too\n", path)
+	case os.Geteuid() == 0:
+		err = os.Lchown(path, own.uid, own.gid)
+	}
+	if err != nil {
+		warnf("can't set owner of %s: %s\n", path, err)
+	}
+}
+
+// underRoot places an absolute path inside root, as --root does; a drive letter is dropped
+func underRoot(root, path string) string {
+	if root == "" {
+		return path
+	}
+	abs := absPath(path)
+	return filepath.Join(root, abs[len(filepath.VolumeName(abs)):])
+}
+
+// symlinksSupported reports whether symlinks can be created in dir, or its nearest
+// existing ancestor, remembering answers in cache
+func symlinksSupported(dir string, cache map[string]bool) bool {
+	dir = existingAncestor(dir)
+	if ok, seen := cache[dir]; seen {
+		return ok
+	}
+	ok := !errors.Is(probeSymlink(dir), errNoSymlinks)
+	cache[dir] = ok
+	return ok
+}
+
+// resolveConflict applies the conflict policy when something other than the desired
+// link exists at an entry's link path. It returns the options to create the link with,
+// or false if the entry should be skipped.
+func resolveConflict(e entry, target string, opts applyOptions, st *state) (applyOptions, bool) {
+	info, err := os.Lstat(e.link)
+	if err != nil {
+		return opts, true
+	}
+
+	// Retargeting a link symlinker created earlier is not a conflict, and a stale
+	// copy it made can be replaced without keeping it
+	if isOwnLink(e.link, target, opts.mode, st) {
+		return opts, true
+	}
+	if l, ok := st.lookup(e.link); ok && isManaged(e.link, l) {
+		opts.backup = false
+		opts.trash = false
+		opts.force = true
+		return opts, true
+	}
+	kind := fileKind(info)
+
+	policy := opts.onConflict
+	if p := e.options["on-conflict"]; p != "" {
+		policy = p
+	}
+
+	if policy == conflictAsk {
+		if opts.dryRun {
+			infof("[DRY RUN] Would ask what to do with existing %s: %s\n", kind, e.link)
+			return opts, true
+		}
+		policy = askConflict(e.link, kind)
+	}
+
+	switch policy {
+	case conflictSkip:
+		verbosef("%s\n", paint(styleSkip, fmt.Sprintf("Skipping %s: existing %s is in the way (on-conflict=skip)", e.link, kind)))
+		return opts, false
+	case conflictBackup:
+		opts.backup = true
+		opts.trash = false
+	}
+	return opts, true
+}
+
+// askConflict prompts for what to do with an existing path, skipping it if input runs out
+func askConflict(path, kind string) string {
+	for {
+		answer, err := ask(fmt.Sprintf("%s already exists (%s). [o]verwrite, [s]kip or [b]ack up?", path, kind))
+		if err != nil {
+			return conflictSkip
+		}
+		switch strings.ToLower(answer) {
+		case "o", "overwrite":
+			return conflictOverwrite
+		case "s", "skip":
+			return conflictSkip
+		case "b", "backup", "back up":
+			return conflictBackup
+		}
+	}
+}
+
+// setupSymlinks reads configuration files and creates symlinks. Several configs are
+// applied as one, with later ones overriding earlier ones.
+func setupSymlinks(configs []string, opts applyOptions) error {
+	entries, err := parseConfigs(configs)
+	if err != nil {
+		return err
+	}
+	printEnvironmentInfo(entries, opts.dryRun)
+
+	label := "config"
+	if len(configs) > 1 {
+		label = "configs"
+	}
+	if opts.dryRun {
+		infof("[DRY RUN] Would set up symlinks from %s: %s\n", label, strings.Join(configs, ", "))
+	} else {
+		infof("Setting up symlinks from %s: %s\n", label, strings.Join(configs, ", "))
+	}
+
+	return applyEntries(entries, opts)
+}
+
+// applyEntries creates the symlinks for a set of config entries
+func applyEntries(entries []entry, opts applyOptions) (err error) {
+	dryRun := opts.dryRun
+
+	// Catch entries that would clobber each other before any of them is applied
+	if problems := conflictingEntries(entries); len(problems) > 0 {
+		if opts.strict {
+			return withExitCode(exitConfig, fmt.Errorf("%d conflicting entries in the config:\n  %s", len(problems), strings.Join(problems, "\n  ")))
+		}
+		for _, p := range problems {
+			warnf("%s\n", p)
+		}
+	}
+	unlock := func() {}
+	if !dryRun {
+		if unlock, err = acquireLock(); err != nil {
+			return err
+		}
+	}
+	defer func() { unlock() }()
+
+	defer bufferConsole()()
+	rep := newRunReport(opts.output, dryRun)
+	rep.changedLine = opts.changedExitCode
+	var denied []entry
+	var deniedActions []action
+	defer func() {
+		// Entries handed to sudo are reported once the root run is over
+		for _, act := range deniedActions {
+			rep.add(act)
+		}
+
+		// A dry run lists its plan at the end, even if it stops early
+		if dryRun && opts.output == outputText && verbosity >= levelNormal {
+			rep.writeDiff()
+		}
+		summary := rep.summarize()
+		switch {
+		case err != nil && opts.continueOnError:
+			noticef("Symlink setup incomplete: %s\n", summary)
+		case err != nil:
+			noticef("Symlink setup stopped: %s\n", summary)
+		case dryRun:
+			noticef("[DRY RUN] Symlink setup complete (no changes made): %s\n", summary)
+		default:
+			noticef("Symlink setup complete: %s\n", summary)
+		}
+		if writeErr := rep.finish(err); writeErr != nil && err == nil {
+			err = writeErr
+		}
+		if !dryRun {
+			notifyRun(opts.notify, summary, err)
+			reportRun(applyReport(entries, summary, err))
+		}
+		if err == nil && opts.changedExitCode && summary.changes() > 0 {
+			err = errChanged
+		}
+	}()
+
+	st, err := loadState()
+	if err != nil {
+		return err
+	}
+	j := newJournal("apply", st)
+	defer func() {
+		if saveErr := j.save(); saveErr != nil && err == nil {
+			err = saveErr
+		}
+		if saveErr := st.save(); saveErr != nil && err == nil {
+			err = saveErr
+		}
+	}()
+
+	approver := &confirmer{}
+	probed := map[string]bool{}
+	escalate := !dryRun && canEscalate()
+	madeDirs := map[string]bool{} // link directories known to exist
+
+	// Prompts would fight with a progress line, so interactive runs go without
+	var prog *progress
+	if !opts.interactive {
+		prog = startProgress(len(entries))
+	}
+	defer prog.stop()
+
+	// fail records an entry's failure, which ends the run unless --continue-on-error was given
+	var failures []error
+	fail := func(act action, err error) error {
+		rep.fail(act, err)
+		if !opts.continueOnError {
+			return err
+		}
+		errorf("%s\n", err)
+		failures = append(failures, err)
+		return nil
+	}
+	// hookFailed does the same for a global hook, which has no entry to report
+	hookFailed := func(err error) error {
+		if err == nil || !opts.continueOnError {
+			return err
+		}
+		errorf("%s\n", err)
+		failures = append(failures, err)
+		return nil
+	}
+
+	if opts.pre != "" {
+		if err := hookFailed(runHookCommand("pre hook", opts.pre, nil, dryRun)); err != nil {
+			return err
+		}
+	}
+	for _, e := range entries {
+		original := e
+		prog.step(e.link)
+
+		// With --root, links land under it, but still point where the actual path will be
+		// once the root is in use; actual is where that is on this host right now
+		relBase := filepath.Dir(e.link)
+		actual := underRoot(opts.root, e.target)
+		e.link = underRoot(opts.root, e.link)
+		if opts.rootTargets {
+			e.target = actual
+			relBase = filepath.Dir(e.link)
+		}
+
+		// Get the directory of the symlink
+		symlinkDir := filepath.Dir(e.link)
+		act := action{Line: e.line, Link: e.link, Target: e.target, Action: actionCreate}
+		if e.line > 0 {
+			verbosef("\nLine %d: %s -> %s\n", e.line, e.rawLink, e.rawTarget)
+		}
+		verbosef("Expanded: %s -> %s (dir: %s)\n", e.link, e.target, symlinkDir)
+
+		// Entry kinds that aren't links are left to their plugin
+		if isPluginMode(e.options["mode"]) {
+			act.Mode = e.options["mode"]
+			act, err := applyPluginEntry(e, act, dryRun)
+			if err != nil {
+				if err := fail(act, err); err != nil {
+					return err
+				}
+				continue
+			}
+			rep.add(act)
+			continue
+		}
+
+		// A link that resolves to itself breaks every tool that opens it
+		if reason := linkCycle(e.link, actual); reason != "" {
+			if err := fail(act, withExitCode(exitConfig, fmt.Errorf("refusing to link %s to %s at line %d: %s", e.link, e.target, e.line, reason))); err != nil {
+				return err
+			}
+			continue
+		}
+
+		// The path the link will hold
+		target := e.target
+		mode := e.options["mode"]
+		if mode == "" && (opts.copyFallback || runtime.GOOS == "windows") && !symlinksSupported(symlinkDir, probed) {
+			if mode = fallbackMode(actual, opts.copyFallback); mode != "" {
+				warnf("can't create symlinks in %s, creating a %s instead: %s\n", symlinkDir, linkNoun(mode), e.link)
+			}
+		}
+		switch {
+		case mode != "" && mode != modeSymlink:
+			// Hard links and copies need the actual file on this host
+			target = actual
+		case entryFlag(e, "relative", opts.relative):
+			if target, err = filepath.Rel(relBase, e.target); err != nil {
+				if err := fail(act, fmt.Errorf("error making relative link at line %d: %w", e.line, err)); err != nil {
+					return err
+				}
+				continue
+			}
+		}
+		act.Target, act.Mode = target, mode
+
+		if opts.interactive && !dryRun {
+			if action := pendingAction(e, target, mode, st); action != "" && !approver.approve(action) {
+				if approver.quit {
+					infof("Stopped; remaining entries were not applied.\n")
+					break
+				}
+				infof("%s\n", paint(styleSkip, "Skipped: "+e.link))
+				act.Action, act.Status, act.Reason = actionSkip, statusSkipped, "declined"
+				rep.add(act)
+				continue
+			}
+		}
+
+		// A missing actual path would leave a dangling link, usually a config typo
+		if _, err := os.Stat(actual); os.IsNotExist(err) {
+			switch {
+			case entryFlag(e, "create", opts.createTargets):
+				if err := createTarget(e, actual, dryRun, j); err != nil {
+					if err := fail(act, fmt.Errorf("error creating actual path at line %d: %w", e.line, err)); err != nil {
+						return err
+					}
+					continue
+				}
+			case opts.requireTarget:
+				if err := fail(act, withExitCode(exitEnv, fmt.Errorf("actual path does not exist at line %d: %s", e.line, actual))); err != nil {
+					return err
+				}
+				continue
+			default:
+				warnf("actual path does not exist, link will dangle: %s\n", actual)
+			}
+		}
+
+		entryOpts := opts
+		entryOpts.mode = mode
+		entryOpts, proceed := resolveConflict(e, target, entryOpts, st)
+		if !proceed {
+			act.Action, act.Status, act.Reason = actionSkip, statusSkipped, "on-conflict=skip"
+			rep.add(act)
+			continue
+		}
+
+		// Hooks before an entry only run when it is about to change
+		if pre := e.options["pre"]; pre != "" && pendingAction(e, target, mode, st) != "" {
+			if err := runHookCommand("pre hook for "+e.link, pre, entryHookEnv(e), dryRun); err != nil {
+				if err := fail(act, err); err != nil {
+					return err
+				}
+				continue
+			}
+		}
+
+		if err := checkUserLink(e.link); err != nil {
+			if err := fail(act, fmt.Errorf("refusing %s for --user: %w", e.link, err)); err != nil {
+				return err
+			}
+			continue
+		}
+
+		// Create symlink directory if it doesn't exist; most entries share a directory
+		// with the one before, so each is only checked once
+		own := entryOwnership(e, defaultOwnership())
+		if !madeDirs[symlinkDir] {
+			if err := ensureDirExists(symlinkDir, own, dryRun, j); err != nil {
+				if escalate && errors.Is(err, fs.ErrPermission) {
+					infof("Permission denied: %s\n", e.link)
+					denied = append(denied, original)
+					act.Status, act.Error = statusFailed, err.Error()
+					deniedActions = append(deniedActions, act)
+					continue
+				}
+				if err := fail(act, fmt.Errorf("error creating directory %s: %w", symlinkDir, err)); err != nil {
+					return err
+				}
+				continue
+			}
+			madeDirs[symlinkDir] = true
+		}
+
+		// Create the symlink
+		op, err := createSymlink(target, e.link, entryOpts, j)
+		// Something at the link path makes it a replace, even one that was refused
+		if op.Op == opReplace {
+			act.Action, act.Replaced, act.Previous = actionReplace, op.Kind, op.Previous
+		}
+		if escalate && errors.Is(err, fs.ErrPermission) {
+			infof("Permission denied: %s\n", e.link)
+			denied = append(denied, original)
+			act.Status, act.Error = statusFailed, err.Error()
+			deniedActions = append(deniedActions, act)
+			continue
+		}
+		if err != nil {
+			if err := fail(act, fmt.Errorf("error creating %s at line %d: %w", linkNoun(mode), e.line, err)); err != nil {
+				return err
+			}
+			continue
+		}
+		act.Status = statusDone
+		if dryRun {
+			act.Status = statusPlanned
+		}
+		switch op.Op {
+		case opUnchanged:
+			act.Action, act.Status = actionUnchanged, statusDone
+		case opReplace:
+			act.Backup, act.Trashed = op.Backup, op.Trashed
+		}
+		if !dryRun && own.changesOwner() {
+			setLinkOwner(e.link, mode, own)
+		}
+		if !dryRun {
+			st.record(managedLink{Link: e.link, Target: target, Mode: mode, Config: e.config, Backup: op.Backup, Trashed: op.Trashed, Hash: op.Hash, Source: op.Source})
+		}
+
+		// Hooks after an entry, such as reloading the program it configures, only run
+		// when it changed
+		if post := e.options["post"]; post != "" && op.Op != opUnchanged {
+			if err := runHookCommand("post hook for "+e.link, post, entryHookEnv(e), dryRun); err != nil {
+				if err := fail(act, err); err != nil {
+					return err
+				}
+				continue
+			}
+		}
+		rep.add(act)
+	}
+
+	prog.stop()
+
+	// Links outside the user's reach are made by a root run of their own
+	if len(denied) > 0 {
+		if err := sudoUnlocked(denied, opts.sudo, st, j, &unlock); err != nil {
+			return err
+		}
+		for i := range deniedActions {
+			deniedActions[i].Status, deniedActions[i].Error = statusSudo, ""
+		}
+	}
+
+	if opts.post != "" {
+		if err := hookFailed(runHookCommand("post hook", opts.post, nil, dryRun)); err != nil {
+			return err
+		}
+	}
+
+	if len(failures) > 0 {
+		return failedEntries(failures)
+	}
+
+	return nil
+}
+
+// sudoUnlocked hands denied entries to a root run, which takes the lock itself, so this
+// run's changes are saved and its lock let go of for the while. The lock is taken back
+// before returning, in place of unlock.
+func sudoUnlocked(denied []entry, always bool, st *state, j *journal, unlock *func()) error {
+	if err := j.save(); err != nil {
+		return err
+	}
+	if err := st.save(); err != nil {
+		return err
+	}
+	(*unlock)()
+	*unlock = func() {}
+
+	err := runWithSudo(denied, always)
+	relock, lockErr := acquireLock()
+	if lockErr != nil {
+		return errors.Join(err, lockErr)
+	}
+	*unlock = relock
+
+	// The root run recorded its links in the same state, which this run saves again
+	reloaded, loadErr := loadState()
+	if loadErr != nil {
+		return errors.Join(err, loadErr)
+	}
+	*st = *reloaded
+	return err
+}
+
+// failedEntries reports the entries that failed in a --continue-on-error run as one error,
+// keeping their exit code if they all agree
+func failedEntries(failures []error) error {
+	messages := make([]string, len(failures))
+	code := exitCodeOf(failures[0])
+	for i, err := range failures {
+		messages[i] = err.Error()
+		if exitCodeOf(err) != code {
+			code = exitFailure
+		}
+	}
+	return withExitCode(code, fmt.Errorf("%d entries failed:\n  %s", len(failures), strings.Join(messages, "\n  ")))
+}
@@ -0,0 +1,55 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/frizadiga/symlinker/pkg/symlinker"
+)
+
+// TestCreateSymlinkOnMemFS applies plain symlinks on an in-memory filesystem, which
+// the command inspects and links in through the library
+func TestCreateSymlinkOnMemFS(t *testing.T) {
+	root := filepath.VolumeName(os.TempDir()) + string(filepath.Separator)
+	p := func(s string) string { return filepath.Join(root, filepath.FromSlash(s)) }
+
+	tests := []struct {
+		name     string
+		setup    func(m *symlinker.MemFS) error
+		op       string
+		kind     string
+		previous string
+	}{
+		{"create", func(m *symlinker.MemFS) error { return nil }, opCreate, "", ""},
+		{"unchanged", func(m *symlinker.MemFS) error { return m.Symlink(p("/dots/vimrc"), p("/home/.vimrc")) }, opUnchanged, "", ""},
+		{"relink", func(m *symlinker.MemFS) error { return m.Symlink(p("/dots/old"), p("/home/.vimrc")) }, opReplace, "symlink", p("/dots/old")},
+		{"replace file", func(m *symlinker.MemFS) error { return m.WriteFile(p("/home/.vimrc"), []byte("set nu\n"), 0644) }, opReplace, "file", ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m := &symlinker.MemFS{}
+			if err := m.MkdirAll(p("/home"), 0755); err != nil {
+				t.Fatal(err)
+			}
+			if err := tt.setup(m); err != nil {
+				t.Fatal(err)
+			}
+			saved := linkFS
+			linkFS = m
+			defer func() { linkFS = saved }()
+
+			j := newJournal("apply", &state{Version: stateVersion})
+			op, err := createSymlink(p("/dots/vimrc"), p("/home/.vimrc"), applyOptions{}, j)
+			if err != nil {
+				t.Fatalf("createSymlink: %v", err)
+			}
+			if op.Op != tt.op || op.Kind != tt.kind || op.Previous != tt.previous {
+				t.Errorf("got {%s %q %q}, want {%s %q %q}", op.Op, op.Kind, op.Previous, tt.op, tt.kind, tt.previous)
+			}
+			if got, err := m.Readlink(p("/home/.vimrc")); err != nil || got != p("/dots/vimrc") {
+				t.Errorf("link points to %q (%v), want %q", got, err, p("/dots/vimrc"))
+			}
+		})
+	}
+}
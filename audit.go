@@ -0,0 +1,117 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// auditRecord is one line of the audit log: something symlinker removed or moved away
+type auditRecord struct {
+	Time   time.Time `json:"time"`
+	PID    int       `json:"pid"`
+	UID    int       `json:"uid"`
+	Args   []string  `json:"args"`   // the command line of the run
+	Action string    `json:"action"` // removed or moved
+	Path   string    `json:"path"`
+	Was    string    `json:"was"`          // what the path held, such as "a file of 120 bytes"
+	To     string    `json:"to,omitempty"` // where a moved path went
+	Build  string    `json:"version"`      // the symlinker build that made the change
+}
+
+// auditLogPath returns the audit log in the state directory. It is only ever appended to.
+func auditLogPath() (string, error) {
+	dir, err := stateDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, "audit.log"), nil
+}
+
+// auditRemove removes path with remove, recording in the audit log what it held
+func auditRemove(path string, remove func(string) error) error {
+	was := describeForAudit(path)
+	if err := remove(path); err != nil {
+		return err
+	}
+	audit(auditRecord{Action: "removed", Path: absPath(path), Was: was})
+	return nil
+}
+
+// auditMove moves src to dst, recording in the audit log what was moved away
+func auditMove(src, dst string) error {
+	was := describeForAudit(src)
+	if err := movePath(src, dst); err != nil {
+		return err
+	}
+	audit(auditRecord{Action: "moved", Path: absPath(src), Was: was, To: absPath(dst)})
+	return nil
+}
+
+// describeForAudit says what a path holds, in enough detail to recognize it later
+func describeForAudit(path string) string {
+	info, err := os.Lstat(path)
+	switch {
+	case err != nil:
+		return "missing"
+	case info.Mode()&os.ModeSymlink != 0:
+		target, _ := os.Readlink(path)
+		return "a symlink to " + target
+	case info.Mode().IsRegular():
+		return fmt.Sprintf("a file of %d bytes", info.Size())
+	case info.IsDir():
+		var files int
+		var size int64
+		filepath.WalkDir(path, func(_ string, d fs.DirEntry, err error) error {
+			if err == nil && !d.IsDir() {
+				files++
+				if info, err := d.Info(); err == nil {
+					size += info.Size()
+				}
+			}
+			return nil
+		})
+		return fmt.Sprintf("a directory of %d files, %d bytes", files, size)
+	}
+	return fmt.Sprintf("a special file (%s)", info.Mode().Type())
+}
+
+// audit appends a record to the audit log, warning if it can't be written
+func audit(r auditRecord) {
+	r.Time = time.Now()
+	r.PID = os.Getpid()
+	r.UID = os.Getuid()
+	r.Args = os.Args[1:]
+	r.Build = currentBuild().String()
+	if err := appendAudit(r); err != nil {
+		warnf("can't write audit log: %s\n", err)
+	}
+}
+
+// appendAudit writes one record as a JSON line
+func appendAudit(r auditRecord) error {
+	path, err := auditLogPath()
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+	file, err := os.OpenFile(path, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0600)
+	if err != nil {
+		return err
+	}
+	line, err := json.Marshal(r)
+	if err != nil {
+		file.Close()
+		return err
+	}
+	if _, err := file.Write(append(line, '\n')); err != nil {
+		file.Close()
+		return err
+	}
+	return file.Close()
+}
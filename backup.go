@@ -0,0 +1,161 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// backupFlag is an optional-value flag: --backup uses the default location, --backup=DIR a custom one
+type backupFlag struct {
+	enabled bool
+	dir     string
+}
+
+func (b *backupFlag) String() string {
+	if !b.enabled {
+		return ""
+	}
+	return b.dir
+}
+
+func (b *backupFlag) Set(value string) error {
+	if on, err := strconv.ParseBool(value); err == nil {
+		b.enabled = on
+		return nil
+	}
+	b.enabled = true
+	b.dir = value
+	return nil
+}
+
+func (b *backupFlag) IsBoolFlag() bool { return true }
+
+// defaultBackupRoot returns the directory backups are kept in when --backup has no value
+func defaultBackupRoot() (string, error) {
+	if dir := os.Getenv("XDG_DATA_HOME"); dir != "" {
+		return filepath.Join(dir, "symlinker", "backups"), nil
+	}
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return "", fmt.Errorf("error finding backup directory: %w", err)
+	}
+	return filepath.Join(home, ".local", "share", "symlinker", "backups"), nil
+}
+
+// runBackupDir returns the timestamped directory this run's backups go into,
+// or "" if backups are disabled
+func runBackupDir(b backupFlag) (string, error) {
+	if !b.enabled {
+		return "", nil
+	}
+
+	root := expandPath(b.dir)
+	if root == "" {
+		var err error
+		if root, err = defaultBackupRoot(); err != nil {
+			return "", err
+		}
+	}
+	root, err := filepath.Abs(root)
+	if err != nil {
+		return "", fmt.Errorf("error resolving backup directory: %w", err)
+	}
+	// Sub-second precision keeps runs straight after one another apart; the names still sort by time
+	return filepath.Join(root, time.Now().Format("20060102-150405.000000000")), nil
+}
+
+// backupPath returns where path is kept inside a backup directory, mirroring its absolute location
+func backupPath(backupDir, path string) string {
+	abs := absPath(path)
+	vol := filepath.VolumeName(abs)
+
+	// A drive letter becomes a plain directory name: C:\Users\me -> <backupDir>\C\Users\me
+	return filepath.Join(backupDir, strings.Trim(vol, `\/:`), abs[len(vol):])
+}
+
+// backupExisting moves path into the backup directory and returns where it went
+func backupExisting(backupDir, path string, dryRun bool) (string, error) {
+	dest := backupPath(backupDir, path)
+	if dryRun {
+		infof("[DRY RUN] Would back up existing: %s -> %s\n", path, dest)
+		return dest, nil
+	}
+
+	infof("Backing up existing: %s -> %s\n", path, dest)
+	if err := os.MkdirAll(filepath.Dir(dest), 0755); err != nil {
+		return "", fmt.Errorf("error creating backup directory: %w", err)
+	}
+	if _, err := os.Lstat(dest); err == nil {
+		return "", fmt.Errorf("backup already exists: %s", dest)
+	}
+	if err := auditMove(path, dest); err != nil {
+		return "", fmt.Errorf("error backing up %s: %w", path, err)
+	}
+	if err := appendBackupIndex(backupDir, absPath(path)); err != nil {
+		return "", err
+	}
+	return dest, nil
+}
+
+// backupIndexName is the file in each backup run directory listing the original paths
+const backupIndexName = ".symlinker-index"
+
+// appendBackupIndex records that path was backed up into backupDir
+func appendBackupIndex(backupDir, path string) error {
+	file, err := os.OpenFile(filepath.Join(backupDir, backupIndexName), os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0644)
+	if err != nil {
+		return fmt.Errorf("error writing backup index: %w", err)
+	}
+	defer file.Close()
+	_, err = fmt.Fprintln(file, path)
+	return err
+}
+
+// readBackupIndex returns the original paths backed up into a run directory that are still there
+func readBackupIndex(runDir string) ([]string, error) {
+	data, err := os.ReadFile(filepath.Join(runDir, backupIndexName))
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("error reading backup index: %w", err)
+	}
+
+	seen := map[string]bool{}
+	var paths []string
+	for _, line := range strings.Split(string(data), "\n") {
+		if line == "" || seen[line] {
+			continue
+		}
+		seen[line] = true
+		if _, err := os.Lstat(backupPath(runDir, line)); err == nil {
+			paths = append(paths, line)
+		}
+	}
+	return paths, nil
+}
+
+// backupRuns lists the timestamped run directories under a backup root, newest first
+func backupRuns(root string) ([]string, error) {
+	dirEntries, err := os.ReadDir(root)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("error reading backup directory: %w", err)
+	}
+
+	var runs []string
+	for _, d := range dirEntries {
+		if d.IsDir() {
+			runs = append(runs, d.Name())
+		}
+	}
+	sort.Sort(sort.Reverse(sort.StringSlice(runs)))
+	return runs, nil
+}
@@ -0,0 +1,122 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+)
+
+// bootstrapConfigs are the configs looked for in a cloned repo, in order
+var bootstrapConfigs = []string{"symlinker.conf", "install.conf.yaml", "install.conf.json"}
+
+// runBootstrap clones a dotfiles repo, or updates an existing clone, and applies its config
+func runBootstrap(args []string, configFilePath string) error {
+	flags := flag.NewFlagSet("bootstrap", flag.ExitOnError)
+	dest := flags.String("dest", "", "Where to clone the repo (default $DOTFILES_HOME, or ~/dotfiles)")
+	config := flags.String("config", "", "Config to apply, relative to the repo (default the first of "+fmt.Sprint(bootstrapConfigs)+")")
+	flags.BoolVar(dryRun, "dry-run", *dryRun, "Show what would be done without making changes")
+	flags.Usage = func() {
+		fmt.Println("Usage: symlinker bootstrap [flags] <git-url>")
+		fmt.Println("\nClone a dotfiles repo (or pull it if it is already cloned) and apply the config in it,")
+		fmt.Println("setting up a new machine in one command. DOTFILES_HOME is set to the clone if unset.")
+		fmt.Println("\nFlags:")
+		flags.PrintDefaults()
+	}
+	rest := parseArgs(flags, args)
+	if len(rest) != 1 {
+		flags.Usage()
+		return withExitCode(exitConfig, fmt.Errorf("bootstrap needs a git URL"))
+	}
+	url := rest[0]
+
+	if *dest == "" {
+		*dest = os.Getenv("DOTFILES_HOME")
+	}
+	if *dest == "" {
+		home, err := os.UserHomeDir()
+		if err != nil {
+			return withExitCode(exitEnv, fmt.Errorf("error finding home directory: %w", err))
+		}
+		*dest = filepath.Join(home, "dotfiles")
+	}
+	repo := absPath(expandTilde(expandPath(*dest)))
+
+	cloned, err := cloneOrPull(url, repo)
+	if err != nil {
+		return err
+	}
+	if !cloned {
+		// A dry run can't look inside a repo it didn't clone
+		return nil
+	}
+
+	// Configs written for this repo usually point into it through $DOTFILES_HOME
+	if os.Getenv("DOTFILES_HOME") == "" {
+		os.Setenv("DOTFILES_HOME", repo)
+	}
+
+	configFile, err := findBootstrapConfig(repo, *config)
+	if err != nil {
+		return err
+	}
+	opts, err := optionsFromFlags()
+	if err != nil {
+		return err
+	}
+	return setupSymlinks([]string{configFile}, opts)
+}
+
+// cloneOrPull clones url into repo, or fast-forwards repo if it is already a clone,
+// reporting whether repo is there to apply from
+func cloneOrPull(url, repo string) (bool, error) {
+	if _, err := os.Stat(filepath.Join(repo, ".git")); err == nil {
+		if *dryRun {
+			infof("[DRY RUN] Would pull: %s\n", repo)
+			return true, nil
+		}
+		infof("Updating %s\n", repo)
+		return true, git("-C", repo, "pull", "--ff-only")
+	}
+
+	if entries, err := os.ReadDir(repo); err == nil && len(entries) > 0 {
+		return false, withExitCode(exitConflict, fmt.Errorf("%s exists and is not a git clone; choose another --dest", repo))
+	}
+	if *dryRun {
+		infof("[DRY RUN] Would clone %s into %s and apply its config\n", url, repo)
+		return false, nil
+	}
+	infof("Cloning %s into %s\n", url, repo)
+	return true, git("clone", url, repo)
+}
+
+// findBootstrapConfig returns the config to apply from a repo: the one asked for, or
+// the first of bootstrapConfigs that exists
+func findBootstrapConfig(repo, config string) (string, error) {
+	if config != "" {
+		if !filepath.IsAbs(config) {
+			config = filepath.Join(repo, config)
+		}
+		return config, nil
+	}
+	for _, name := range bootstrapConfigs {
+		path := filepath.Join(repo, name)
+		if _, err := os.Stat(path); err == nil {
+			return path, nil
+		}
+	}
+	return "", withExitCode(exitConfig, fmt.Errorf("no config found in %s (looked for %v; use --config)", repo, bootstrapConfigs))
+}
+
+// git runs a git command with its output shown
+func git(args ...string) error {
+	cmd := exec.Command("git", args...)
+	cmd.Stdin, cmd.Stdout, cmd.Stderr = os.Stdin, os.Stdout, os.Stderr
+	flushConsole()
+	if err := cmd.Run(); err != nil {
+		return withExitCode(exitEnv, fmt.Errorf("error running git %s: %w", strings.Join(args, " "), err))
+	}
+	return nil
+}
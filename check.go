@@ -0,0 +1,109 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// conflictingEntries reports entries that would undo each other's work: the same link
+// path with different targets, or a link path inside a directory another entry replaces
+func conflictingEntries(entries []entry) []string {
+	var problems []string
+	byLink := map[string]entry{}
+	for _, e := range entries {
+		link := absPath(e.link)
+		if prev, ok := byLink[link]; ok {
+			if absPath(prev.target) != absPath(e.target) {
+				problems = append(problems, fmt.Sprintf("Lines %d and %d both link %s (to %s and %s); the later one would win", prev.line, e.line, e.link, prev.target, e.target))
+			}
+			continue
+		}
+		byLink[link] = e
+	}
+
+	// A link path below another link path ends up inside that link's target, or is
+	// clobbered when the outer path is replaced
+	for _, e := range entries {
+		link := absPath(e.link)
+		for dir := filepath.Dir(link); dir != filepath.Dir(dir); dir = filepath.Dir(dir) {
+			if outer, ok := byLink[dir]; ok {
+				problems = append(problems, fmt.Sprintf("Line %s links %s inside %s, which line %s replaces with a link", lineRef(e, outer), e.link, outer.link, lineRef(outer, e)))
+				break
+			}
+		}
+	}
+	return problems
+}
+
+// lineRef returns e's line number for a message that also mentions other, naming e's
+// config when the two come from different ones
+func lineRef(e, other entry) string {
+	if e.config == other.config {
+		return fmt.Sprint(e.line)
+	}
+	return fmt.Sprintf("%d of %s", e.line, contractPath(e.config))
+}
+
+// maxSymlinkHops bounds how many symlinks are followed when looking for cycles
+const maxSymlinkHops = 255
+
+// linkCycle reports why linking link to target would make a link that resolves to
+// itself, or "" if it wouldn't: link and target are the same path, or following the
+// target leads back through the link path
+func linkCycle(link, target string) string {
+	link = absPath(link)
+	target = absPath(target)
+	if link == target {
+		return "the link path and actual path are the same"
+	}
+
+	// Compare against the link path with its directory resolved too, so that
+	// /home -> /usr/home style aliases don't hide a cycle
+	links := []string{link}
+	if dir, err := filepath.EvalSymlinks(filepath.Dir(link)); err == nil {
+		links = append(links, filepath.Join(dir, filepath.Base(link)))
+	}
+
+	path := target
+	for hops := 0; hops <= maxSymlinkHops; hops++ {
+		for _, l := range links {
+			if isWithin(l, path) {
+				if hops == 0 {
+					return fmt.Sprintf("the actual path is inside the link path %s", link)
+				}
+				return fmt.Sprintf("the actual path resolves back to the link path through %s", path)
+			}
+		}
+		next, ok := followFirstSymlink(path)
+		if !ok {
+			return ""
+		}
+		path = next
+	}
+	return "the actual path goes through too many levels of symlinks"
+}
+
+// followFirstSymlink replaces the first symlink among path and its parent directories
+// with that symlink's target, reporting false if path crosses no symlinks
+func followFirstSymlink(path string) (string, bool) {
+	vol := filepath.VolumeName(path)
+	parts := strings.Split(path[len(vol):], string(filepath.Separator))
+	current := vol + string(filepath.Separator)
+	for i, part := range parts {
+		if part == "" {
+			continue
+		}
+		current = filepath.Join(current, part)
+		dest, err := os.Readlink(current)
+		if err != nil {
+			continue
+		}
+		if !filepath.IsAbs(dest) {
+			dest = filepath.Join(filepath.Dir(current), dest)
+		}
+		return filepath.Join(append([]string{dest}, parts[i+1:]...)...), true
+	}
+	return "", false
+}
@@ -0,0 +1,236 @@
+package main
+
+import (
+	"bufio"
+	"flag"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// Source name prefixes for entries chezmoi doesn't manage as plain files
+var chezmoiSkipPrefixes = map[string]string{
+	"create_":    "create-only files are not supported",
+	"modify_":    "modify scripts are not supported",
+	"remove_":    "remove entries are not supported",
+	"run_":       "scripts are not supported",
+	"symlink_":   "chezmoi symlinks are not supported",
+	"encrypted_": "encrypted files are not supported",
+}
+
+// Source name prefixes that only change attributes symlinks can't carry
+var chezmoiAttrPrefixes = []string{"private_", "readonly_", "empty_", "executable_", "exact_", "external_"}
+
+// runImportChezmoi converts a chezmoi source directory into config lines, or applies it directly
+func runImportChezmoi(args []string, configFilePath string) error {
+	flags := flag.NewFlagSet("import-chezmoi", flag.ExitOnError)
+	target := flags.String("target", "$HOME", "Directory chezmoi applies into")
+	output := flags.String("output", "", "Write config lines to this file instead of stdout")
+	apply := flags.Bool("apply", false, "Create the symlinks instead of printing config lines")
+	flags.BoolVar(dryRun, "dry-run", *dryRun, "Show what would be done without making changes")
+	flags.Usage = func() {
+		fmt.Println("Usage: symlinker import-chezmoi [flags] [source-dir]")
+		fmt.Println("\nConvert the plain files of a chezmoi source directory into symlinker config lines.")
+		fmt.Println("Templates, scripts and encrypted files are reported and skipped.")
+		fmt.Println("The source directory defaults to ~/.local/share/chezmoi.")
+		fmt.Println("\nFlags:")
+		flags.PrintDefaults()
+	}
+	rest := parseArgs(flags, args)
+
+	sourceDir := "~/.local/share/chezmoi"
+	if len(rest) > 0 {
+		sourceDir = rest[0]
+	}
+	sourceDir, err := filepath.Abs(expandTilde(expandPath(sourceDir)))
+	if err != nil {
+		return fmt.Errorf("error resolving source directory: %w", err)
+	}
+	targetDir, err := filepath.Abs(expandTilde(expandPath(*target)))
+	if err != nil {
+		return fmt.Errorf("error resolving target directory: %w", err)
+	}
+
+	// .chezmoiroot moves the source state into a subdirectory
+	if root, err := os.ReadFile(filepath.Join(sourceDir, ".chezmoiroot")); err == nil {
+		sourceDir = filepath.Join(sourceDir, strings.TrimSpace(string(root)))
+	}
+
+	entries, err := chezmoiEntries(sourceDir, targetDir)
+	if err != nil {
+		return err
+	}
+
+	if *apply {
+		opts, err := optionsFromFlags()
+		if err != nil {
+			return err
+		}
+		return applyEntries(entries, opts)
+	}
+	return writeConfigLines(*output, fmt.Sprintf("Imported from chezmoi source directory %s", contractPath(sourceDir)), entries)
+}
+
+// chezmoiEntries walks a chezmoi source directory and returns entries for its plain files
+func chezmoiEntries(sourceDir, targetDir string) ([]entry, error) {
+	ignore, err := chezmoiIgnorePatterns(sourceDir)
+	if err != nil {
+		return nil, err
+	}
+
+	var entries []entry
+	err = filepath.WalkDir(sourceDir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if path == sourceDir {
+			return nil
+		}
+
+		// chezmoi ignores dotted source names, except its own special files
+		if strings.HasPrefix(d.Name(), ".") {
+			if d.IsDir() {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+
+		rel, err := filepath.Rel(sourceDir, path)
+		if err != nil {
+			return err
+		}
+		targetRel, reason := chezmoiTargetPath(rel, d.IsDir())
+		if reason == "" && chezmoiIgnored(ignore, targetRel) {
+			reason = "ignored by .chezmoiignore"
+		}
+		if reason != "" {
+			fmt.Fprintf(os.Stderr, "Skipping %s: %s\n", rel, reason)
+			if d.IsDir() {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if d.IsDir() {
+			return nil
+		}
+
+		if strings.ContainsAny(path+targetRel, "\r\n") {
+			warnf("skipping path containing a line break: %q\n", path)
+			return nil
+		}
+		entries = append(entries, entry{link: filepath.Join(targetDir, targetRel), target: path, config: sourceDir})
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("error reading chezmoi source directory: %w", err)
+	}
+	return entries, nil
+}
+
+// chezmoiTargetPath translates a source-relative path into its target-relative path,
+// or returns a reason the entry can't be managed as a plain symlink
+func chezmoiTargetPath(rel string, isDir bool) (string, string) {
+	parts := strings.Split(rel, string(filepath.Separator))
+	for i, part := range parts {
+		last := i == len(parts)-1
+		name, reason := chezmoiTargetName(part, isDir || !last)
+		if reason != "" {
+			return "", reason
+		}
+		parts[i] = name
+	}
+	return filepath.Join(parts...), ""
+}
+
+// chezmoiTargetName strips chezmoi attribute prefixes and suffixes from one path component
+func chezmoiTargetName(name string, isDir bool) (string, string) {
+	if !isDir {
+		if strings.HasSuffix(name, ".tmpl") {
+			return "", "templates are not supported"
+		}
+		for prefix, reason := range chezmoiSkipPrefixes {
+			if strings.HasPrefix(name, prefix) {
+				return "", reason
+			}
+		}
+	} else if strings.HasPrefix(name, "remove_") {
+		return "", chezmoiSkipPrefixes["remove_"]
+	}
+
+	for {
+		if strings.HasPrefix(name, "literal_") {
+			name = strings.TrimPrefix(name, "literal_")
+			break
+		}
+		stripped := false
+		for _, prefix := range chezmoiAttrPrefixes {
+			if strings.HasPrefix(name, prefix) {
+				name = strings.TrimPrefix(name, prefix)
+				stripped = true
+			}
+		}
+		if strings.HasPrefix(name, "dot_") {
+			name = "." + strings.TrimPrefix(name, "dot_")
+			break
+		}
+		if !stripped {
+			break
+		}
+	}
+
+	if !isDir {
+		name = strings.TrimSuffix(name, ".literal")
+	}
+	return name, ""
+}
+
+// chezmoiIgnorePatterns reads the non-template lines of .chezmoiignore
+func chezmoiIgnorePatterns(sourceDir string) ([]string, error) {
+	file, err := os.Open(filepath.Join(sourceDir, ".chezmoiignore"))
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("error opening .chezmoiignore: %w", err)
+	}
+	defer file.Close()
+
+	var patterns []string
+	templated := false
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if strings.Contains(line, "{{") {
+			templated = true
+			continue
+		}
+		if line != "" && !strings.HasPrefix(line, "#") {
+			patterns = append(patterns, line)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("error reading .chezmoiignore: %w", err)
+	}
+
+	if templated {
+		fmt.Fprintln(os.Stderr, "Warning: .chezmoiignore contains templates; only its plain patterns were applied")
+	}
+	return patterns, nil
+}
+
+// chezmoiIgnored reports whether a target-relative path matches an ignore pattern
+func chezmoiIgnored(patterns []string, rel string) bool {
+	rel = filepath.ToSlash(rel)
+	for _, pattern := range patterns {
+		if matched, _ := filepath.Match(pattern, rel); matched {
+			return true
+		}
+		// A pattern matching a directory ignores everything under it
+		if matched, _ := filepath.Match(pattern+"/*", rel); matched || strings.HasPrefix(rel, strings.TrimSuffix(pattern, "/")+"/") {
+			return true
+		}
+	}
+	return false
+}
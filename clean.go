@@ -0,0 +1,80 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+)
+
+// runClean removes managed links that the configs no longer list or whose actual path is gone
+func runClean(args []string, configFilePath string) error {
+	flags := flag.NewFlagSet("clean", flag.ExitOnError)
+	flags.BoolVar(dryRun, "dry-run", *dryRun, "Show what would be done without making changes")
+	flags.Usage = func() {
+		fmt.Println("Usage: symlinker clean [flags] [config-file...]")
+		fmt.Println("\nRemove the links symlinker made from the configs that they no longer list, and")
+		fmt.Println("those whose actual path no longer exists, restoring backed up originals.")
+		fmt.Println("\nFlags:")
+		flags.PrintDefaults()
+	}
+	configs := parseArgs(flags, args)
+	if len(configs) == 0 {
+		configs = []string{configFilePath}
+	}
+
+	entries, err := parseConfigs(configs)
+	if err != nil {
+		return err
+	}
+	if !*dryRun {
+		unlock, err := acquireLock()
+		if err != nil {
+			return err
+		}
+		defer unlock()
+	}
+	st, err := loadState()
+	if err != nil {
+		return err
+	}
+
+	links := orphanedLinks(configs, entries, st)
+	for _, e := range entries {
+		l, ok := st.lookup(underRoot(*rootDir, e.link))
+		// Hard links and written files keep their content when the actual path goes
+		if !ok || l.Mode == modeHardlink || writesFile(l.Mode) {
+			continue
+		}
+		if _, err := os.Stat(underRoot(*rootDir, e.target)); os.IsNotExist(err) {
+			links = append(links, l)
+		}
+	}
+	if len(links) == 0 {
+		infof("Nothing to clean.\n")
+		return nil
+	}
+
+	j := newJournal("clean", st)
+	defer j.save()
+
+	failed := 0
+	for _, l := range links {
+		if err := uninstallLink(st, j, l, false, *dryRun); err != nil {
+			errorf("%s\n", err)
+			failed++
+		}
+	}
+	if err := st.save(); err != nil {
+		return err
+	}
+	if failed > 0 {
+		return fmt.Errorf("failed to remove %d links", failed)
+	}
+
+	if *dryRun {
+		infof("[DRY RUN] Clean complete! (No changes made)\n")
+	} else {
+		infof("Clean complete!\n")
+	}
+	return nil
+}
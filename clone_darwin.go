@@ -0,0 +1,33 @@
+package main
+
+import (
+	"os"
+	"syscall"
+	"unsafe"
+)
+
+// sysClonefileat is the clonefileat(2) system call, which the syscall package has no
+// number for; atFDCWD is AT_FDCWD, resolving relative paths against the current directory
+const (
+	sysClonefileat = 462
+	atFDCWD        = -2
+)
+
+// cloneFile makes dst a copy-on-write clone of src with clonefileat(2), failing with
+// ENOTSUP where the filesystem isn't APFS and EXDEV across volumes. The clone takes
+// src's mode, which is perm wherever copyFile is used.
+func cloneFile(src, dst string, perm os.FileMode) error {
+	s, err := syscall.BytePtrFromString(src)
+	if err != nil {
+		return err
+	}
+	d, err := syscall.BytePtrFromString(dst)
+	if err != nil {
+		return err
+	}
+	fdcwd := atFDCWD
+	if _, _, errno := syscall.Syscall6(sysClonefileat, uintptr(fdcwd), uintptr(unsafe.Pointer(s)), uintptr(fdcwd), uintptr(unsafe.Pointer(d)), 0, 0); errno != 0 {
+		return &os.LinkError{Op: "clonefileat", Old: src, New: dst, Err: errno}
+	}
+	return nil
+}
@@ -0,0 +1,13 @@
+//go:build !linux && !darwin
+
+package main
+
+import (
+	"errors"
+	"os"
+)
+
+// cloneFile is not available here; copyFile falls back to a byte copy
+func cloneFile(src, dst string, perm os.FileMode) error {
+	return errors.New("file cloning not supported on this platform")
+}
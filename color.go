@@ -0,0 +1,55 @@
+package main
+
+import (
+	"fmt"
+	"os"
+)
+
+// Settings for --color
+const (
+	colorAuto   = "auto"
+	colorAlways = "always"
+	colorNever  = "never"
+)
+
+// ANSI styles for the kinds of action
+const (
+	styleCreate  = "32" // green
+	styleReplace = "33" // yellow
+	styleError   = "31" // red
+	styleSkip    = "2"  // dim
+	styleCursor  = "7"  // reverse video, for the row under the cursor in the tui
+)
+
+// useColor is whether output is colorized, decided once by setupColor
+var useColor bool
+
+// setupColor decides whether to colorize output: always or never as asked, and in auto
+// mode only on a terminal and when NO_COLOR (https://no-color.org) isn't set
+func setupColor(setting string) error {
+	switch setting {
+	case colorAlways:
+		useColor = true
+	case colorNever:
+		useColor = false
+	case colorAuto:
+		useColor = os.Getenv("NO_COLOR") == "" && os.Getenv("TERM") != "dumb" && isTerminal(os.Stdout)
+	default:
+		return fmt.Errorf("invalid color setting %q (want auto, always or never)", setting)
+	}
+	return nil
+}
+
+// isTerminal reports whether f is a terminal rather than a file or pipe
+func isTerminal(f *os.File) bool {
+	info, err := f.Stat()
+	return err == nil && info.Mode()&os.ModeCharDevice != 0
+}
+
+// paint wraps s in an ANSI style when colors are on
+func paint(style, s string) string {
+	if !useColor {
+		return s
+	}
+	return "\x1b[" + style + "m" + s + "\x1b[0m"
+}
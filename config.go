@@ -0,0 +1,419 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"iter"
+	"maps"
+	"os"
+	"path/filepath"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/frizadiga/symlinker/pkg/symlinker"
+)
+
+// entry is a single symlink definition from a config file
+type entry struct {
+	line      int               // line number in the config file
+	rawLink   string            // symlink path as written in the config
+	rawTarget string            // actual path as written in the config
+	link      string            // expanded symlink path
+	target    string            // expanded actual path
+	config    string            // config file (or import source) the entry came from
+	options   map[string]string // per-entry option=value settings
+}
+
+// Per-entry options accepted after the two paths, with their validators
+var entryOptions = map[string]func(string) error{
+	"on-conflict": validateConflictPolicy,
+	"create":      validateBool,
+	"relative":    validateBool,
+	"mode":        validateLinkMode,
+	"owner":       validateOwner,
+	"group":       validateGroup,
+	"dirmode":     validateDirMode,
+	"pre":         validateCommand,
+	"post":        validateCommand,
+	"pick":        validatePick,
+	"resolve":     validateBool,
+}
+
+// validateBool checks a true/false option value
+func validateBool(value string) error {
+	if _, err := strconv.ParseBool(value); err != nil {
+		return fmt.Errorf("invalid boolean %q", value)
+	}
+	return nil
+}
+
+// validateOptions checks the options of an entry, returning the valid ones and warnings
+// for unknown or invalid ones
+func validateOptions(parsed map[string]string, lineNumber int) (map[string]string, []string) {
+	var options map[string]string
+	var warnings []string
+	for _, key := range slices.Sorted(maps.Keys(parsed)) {
+		value := parsed[key]
+		validate, known := entryOptions[key]
+		if !known {
+			if guess := closestName(key, maps.Keys(entryOptions)); guess != "" {
+				warnings = append(warnings, fmt.Sprintf("Unknown option at line %d: %s (did you mean %s?)", lineNumber, key, guess))
+			} else {
+				warnings = append(warnings, fmt.Sprintf("Unknown option at line %d: %s", lineNumber, key))
+			}
+			continue
+		}
+		if err := validate(value); err != nil {
+			warnings = append(warnings, fmt.Sprintf("Invalid option at line %d: %s: %s", lineNumber, key, err))
+			continue
+		}
+		if options == nil {
+			options = map[string]string{}
+		}
+		options[key] = value
+	}
+	return options, warnings
+}
+
+// formatOptions renders per-entry options as they are written after the two paths
+func formatOptions(options map[string]string) string {
+	keys := make([]string, 0, len(options))
+	for key := range options {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	var b strings.Builder
+	for _, key := range keys {
+		fmt.Fprintf(&b, " %s=%s", key, quoteField(options[key]))
+	}
+	return b.String()
+}
+
+// quoteField quotes a path or option value for a config line unless it reads back the
+// same as it is, as a Windows path or a $(command) with spaces does. Braces that would
+// expand are quoted too.
+func quoteField(value string) string {
+	if fields, err := symlinker.SplitFields(value); err == nil && len(fields) == 1 && fields[0] == value &&
+		!strings.HasPrefix(value, "#") && len(symlinker.ExpandBraces(value)) == 1 {
+		return value
+	}
+	return `"` + strings.NewReplacer(`\`, `\\`, `"`, `\"`).Replace(value) + `"`
+}
+
+// closestName returns the known name a misspelled one most likely meant, or ""
+func closestName(key string, names iter.Seq[string]) string {
+	best, bestDistance := "", 3
+	for name := range names {
+		if d := editDistance(key, name); d < bestDistance || (d == bestDistance && name < best) {
+			best, bestDistance = name, d
+		}
+	}
+	return best
+}
+
+// editDistance returns the Levenshtein distance between two strings
+func editDistance(a, b string) int {
+	prev := make([]int, len(b)+1)
+	for j := range prev {
+		prev[j] = j
+	}
+	for i := 1; i <= len(a); i++ {
+		cur := make([]int, len(b)+1)
+		cur[0] = i
+		for j := 1; j <= len(b); j++ {
+			cost := 1
+			if a[i-1] == b[j-1] {
+				cost = 0
+			}
+			cur[j] = min(prev[j]+1, cur[j-1]+1, prev[j-1]+cost)
+		}
+		prev = cur
+	}
+	return prev[len(b)]
+}
+
+// parseConfig reads a configuration file and returns its symlink entries, printing any warnings
+func parseConfig(configFilePath string) ([]entry, error) {
+	entries, warnings, err := readConfig(configFilePath)
+	for _, w := range warnings {
+		warnf("%s\n", w)
+	}
+	return entries, withExitCode(exitConfig, err)
+}
+
+// configSearchPaths are where a config is looked for when none is given, in order
+func configSearchPaths() []string {
+	home, _ := os.UserHomeDir()
+	configHome := os.Getenv("XDG_CONFIG_HOME")
+	if configHome == "" {
+		configHome = filepath.Join(home, ".config")
+	}
+	return []string{
+		filepath.Join(configHome, "symlinker", "symlinker.conf"),
+		filepath.Join(home, ".symlinker.conf"),
+	}
+}
+
+// findDefaultConfig returns the config to use when none is given: $SYMLINKER_CONFIG, or
+// else the first of configSearchPaths that exists. When none does, the first is
+// returned, with found false.
+func findDefaultConfig() (config string, found bool) {
+	if config := os.Getenv("SYMLINKER_CONFIG"); config != "" {
+		return config, true
+	}
+	paths := configSearchPaths()
+	for _, path := range paths {
+		if _, err := os.Stat(path); err == nil {
+			return path, true
+		}
+	}
+	return paths[0], false
+}
+
+// parseConfigs parses configs and merges their entries in order
+func parseConfigs(configs []string) ([]entry, error) {
+	perConfig := make([][]entry, len(configs))
+	for i, config := range configs {
+		entries, err := parseConfig(config)
+		if err != nil {
+			return nil, err
+		}
+		perConfig[i] = entries
+	}
+	return mergeEntries(perConfig), nil
+}
+
+// mergeEntries combines the entries of several configs in order. An entry for a link
+// path that an earlier config also links replaces that entry, so a machine-specific
+// config can override a shared one; duplicates within one config are left for
+// conflictingEntries to report.
+func mergeEntries(configs [][]entry) []entry {
+	var merged []entry
+	byLink := map[string]int{}
+	for _, entries := range configs {
+		for _, e := range entries {
+			link := absPath(e.link)
+			if i, ok := byLink[link]; ok && merged[i].config != e.config {
+				prev := merged[i]
+				verbosef("%s line %d overrides %s line %d: %s\n", contractPath(e.config), e.line, contractPath(prev.config), prev.line, e.link)
+				merged[i] = e
+				continue
+			}
+			if _, ok := byLink[link]; !ok {
+				byLink[link] = len(merged)
+			}
+			merged = append(merged, e)
+		}
+	}
+	return merged
+}
+
+// readConfig reads a configuration file and returns its symlink entries along with any warnings
+func readConfig(configFilePath string) ([]entry, []string, error) {
+	if configFilePath == stdinConfig {
+		return readStdinConfig()
+	}
+
+	// A URL is read from a downloaded copy
+	source := configFilePath
+	var warnings []string
+	if isRemoteConfig(configFilePath) {
+		local, fetchWarnings, err := fetchConfig(configFilePath)
+		if err != nil {
+			return nil, nil, err
+		}
+		source, warnings = local, fetchWarnings
+	}
+
+	// Check if config file exists
+	data, err := os.ReadFile(source)
+	if os.IsNotExist(err) {
+		return nil, nil, fmt.Errorf("error: Config file not found: %s", configFilePath)
+	}
+	if err != nil {
+		return nil, nil, fmt.Errorf("error opening config file: %w", err)
+	}
+
+	// The bytes checked are the bytes read, so the file can't change in between
+	if verifyingConfigs() {
+		sigPath := func() (string, error) { return source + ".sig", nil }
+		if isRemoteConfig(configFilePath) {
+			sigPath = func() (string, error) { return fetchSignature(configFilePath + ".sig") }
+		}
+		if err := verifyConfig(configFilePath, data, sigPath); err != nil {
+			return nil, warnings, err
+		}
+	}
+
+	var entries []entry
+	var configWarnings []string
+	if isDotbotConfig(source) {
+		// Link targets are relative to the directory holding the config
+		isJSON := strings.EqualFold(filepath.Ext(source), ".json")
+		entries, configWarnings, err = parseDotbotConfig(data, isJSON, absPath(filepath.Dir(source)))
+	} else {
+		entries, configWarnings, err = scanLineConfig(bytes.NewReader(data))
+	}
+	warnings = append(warnings, configWarnings...)
+	entries, configWarnings = resolveEntryTargets(entries)
+	warnings = append(warnings, configWarnings...)
+
+	// Remember which config each entry came from
+	configFilePath = configPath(configFilePath)
+	for i := range entries {
+		entries[i].config = configFilePath
+	}
+	return entries, warnings, err
+}
+
+// stdinConfig is the config argument that reads the config from stdin
+const stdinConfig = "-"
+
+// stdinData holds the config read from stdin, which can only be read once
+var stdinData = sync.OnceValues(func() ([]byte, error) {
+	return io.ReadAll(os.Stdin)
+})
+
+// readStdinConfig reads a config piped to stdin. The format is told by the content:
+// JSON if it starts with { or [, a Dotbot YAML list if its first line starts with "- ",
+// and line-based otherwise. Relative Dotbot targets are resolved against the working
+// directory.
+func readStdinConfig() ([]entry, []string, error) {
+	data, err := stdinData()
+	if err != nil {
+		return nil, nil, fmt.Errorf("error reading config from stdin: %w", err)
+	}
+	if verifyingConfigs() {
+		if err := verifyConfig("from stdin", data, nil); err != nil {
+			return nil, nil, err
+		}
+	}
+
+	var entries []entry
+	var warnings []string
+	first := firstConfigLine(data)
+	switch {
+	case strings.HasPrefix(first, "{") || strings.HasPrefix(first, "["):
+		entries, warnings, err = parseDotbotConfig(data, true, absPath("."))
+	case strings.HasPrefix(first, "- ") || first == "-":
+		entries, warnings, err = parseDotbotConfig(data, false, absPath("."))
+	default:
+		entries, warnings, err = scanLineConfig(bytes.NewReader(data))
+	}
+	entries, targetWarnings := resolveEntryTargets(entries)
+	warnings = append(warnings, targetWarnings...)
+	for i := range entries {
+		entries[i].config = stdinConfig
+	}
+	return entries, warnings, err
+}
+
+// firstConfigLine returns the first line of a config that isn't blank or a comment, trimmed
+func firstConfigLine(data []byte) string {
+	for line := range strings.Lines(strings.TrimPrefix(string(data), "\ufeff")) {
+		if line = strings.TrimSpace(line); line != "" && !strings.HasPrefix(line, "#") && line != "---" {
+			return line
+		}
+	}
+	return ""
+}
+
+// scanLineConfig reads a config in the line-based <symlink_path> <actual_path> [option=value...]
+// format, split into lines as symlinker.ReadLines does: CRLF endings are taken off and
+// lines ending in " \" continue on the next. A line with {a,b} braces can hold several entries.
+func scanLineConfig(r io.Reader) ([]entry, []string, error) {
+	lines, err := symlinker.ReadLines(r)
+	if err != nil {
+		return nil, nil, fmt.Errorf("error reading config file: %w", err)
+	}
+
+	var entries []entry
+	var warnings []string
+	for _, l := range lines {
+		line, lineNumber := l.Text, l.Pos.Line
+
+		// Split line into symlink_path and actual_path and expand environment variables
+		parsed, lineWarnings := symlinker.ParseLineAt(line, l.Pos, expandPath)
+		warnings = append(warnings, lineWarnings...)
+		if len(parsed) == 0 {
+			continue
+		}
+
+		// Check if expansion actually happened (detect unexpanded variables); with
+		// --no-expand, paths expanded already may hold a $ of their own
+		first := parsed[0]
+		switch {
+		case *noExpand:
+		case !*allowExec && (commandSubstRegex.MatchString(first.RawLink) || commandSubstRegex.MatchString(first.RawTarget)):
+			warnings = append(warnings, fmt.Sprintf("Command substitution at %s is only run with --allow-exec: %s", l.Pos, line))
+		case strings.Contains(first.Link, "$") || strings.Contains(first.Target, "$") ||
+			(*percentVars && (percentVarRegex.MatchString(first.Link) || percentVarRegex.MatchString(first.Target))):
+			warnings = append(warnings, fmt.Sprintf("Unexpanded environment variables at %s: %s", l.Pos, line))
+		}
+
+		// The entries of a line share its options
+		options, optionWarnings := validateOptions(first.Options, lineNumber)
+		warnings = append(warnings, optionWarnings...)
+
+		for _, p := range parsed {
+			entries = append(entries, entry{
+				line:      lineNumber,
+				rawLink:   p.RawLink,
+				rawTarget: p.RawTarget,
+				link:      p.Link,
+				target:    p.Target,
+				options:   maps.Clone(options),
+			})
+		}
+	}
+
+	return entries, warnings, nil
+}
+
+// findEntry returns the entry whose symlink path matches path
+func findEntry(entries []entry, path string) (entry, bool) {
+	path = filepath.Clean(path)
+	for _, e := range entries {
+		if filepath.Clean(e.link) == path {
+			return e, true
+		}
+	}
+	return entry{}, false
+}
+
+// unsetVars returns the names of environment variables referenced in path that are not set
+func unsetVars(path string) []string {
+	var names []string
+	for _, name := range referencedVars(path) {
+		if _, ok := os.LookupEnv(name); !ok {
+			names = append(names, name)
+		}
+	}
+	return names
+}
+
+// referencedVars returns the environment variables a path as written refers to, in
+// order and once each
+func referencedVars(path string) []string {
+	var names []string
+	add := func(name string) {
+		if name != "" && !slices.Contains(names, name) {
+			names = append(names, name)
+		}
+	}
+	os.Expand(path, func(name string) string {
+		add(name)
+		return ""
+	})
+	if *percentVars {
+		for _, match := range percentVarRegex.FindAllStringSubmatch(path, -1) {
+			add(match[1])
+		}
+	}
+	return names
+}
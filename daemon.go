@@ -0,0 +1,173 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+	"os/signal"
+	"strings"
+	"syscall"
+	"time"
+)
+
+// daemonStatus is what the daemon last did, kept in the state file for status checks
+type daemonStatus struct {
+	PID       int       `json:"pid"` // 0 once the daemon has stopped
+	Started   time.Time `json:"started"`
+	Interval  string    `json:"interval"`
+	Configs   []string  `json:"configs"`
+	LastCheck time.Time `json:"last_check"`
+	// LastRepair is when drift was last found and put right, and Repaired the links involved
+	LastRepair time.Time `json:"last_repair,omitzero"`
+	Repaired   []string  `json:"repaired,omitempty"`
+	LastError  string    `json:"last_error,omitempty"` // from the last check; empty if it succeeded
+}
+
+// runDaemon applies configs on an interval, repairing links that other tools deleted or
+// retargeted since, until stopped
+func runDaemon(args []string, configFilePath string) error {
+	flags := flag.NewFlagSet("daemon", flag.ExitOnError)
+	interval := flags.Duration("interval", 15*time.Minute, "How often to check the links and repair drift")
+	status := flags.Bool("status", false, "Print what the daemon last did, from the state file, and exit")
+	flags.Usage = func() {
+		fmt.Println("Usage: symlinker daemon [flags] [config-file...]")
+		fmt.Println("\nApply the configs now and then every interval, putting back links that were")
+		fmt.Println("deleted or retargeted since. The outcome of each check is kept in the state file.")
+		fmt.Println("\nFlags:")
+		flags.PrintDefaults()
+		fmt.Println("\nThe flags for applying configs, such as --backup, go before \"daemon\".")
+	}
+	configs := parseArgs(flags, args)
+	if *status {
+		return printDaemonStatus()
+	}
+	if len(configs) == 0 {
+		configs = []string{configFilePath}
+	}
+	for i, config := range configs {
+		configs[i] = configPath(config)
+	}
+	if *interval <= 0 {
+		return withExitCode(exitConfig, fmt.Errorf("--interval must be positive"))
+	}
+
+	opts, err := optionsFromFlags()
+	if err != nil {
+		return err
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	ds := daemonStatus{PID: os.Getpid(), Started: time.Now(), Interval: interval.String(), Configs: configs}
+	noticef("Checking links every %s (pid %d)\n", interval, ds.PID)
+	ticker := time.NewTicker(*interval)
+	defer ticker.Stop()
+	for {
+		reconcile(configs, opts, &ds)
+		select {
+		case <-ctx.Done():
+			ds.PID = 0
+			if err := saveDaemonStatus(ds); err != nil {
+				return err
+			}
+			infof("Daemon stopped.\n")
+			return nil
+		case <-ticker.C:
+		}
+	}
+}
+
+// reconcile finds the managed links that drifted, applies the configs to put them (and
+// any new entries) in place and records the outcome in ds and the state file
+func reconcile(configs []string, opts applyOptions, ds *daemonStatus) {
+	ds.LastCheck = time.Now()
+	ds.LastError = ""
+	drifted, err := driftedLinks(configs)
+	if len(drifted) > 0 {
+		noticef("Drift found in %d links: %s\n", len(drifted), strings.Join(drifted, ", "))
+	}
+
+	if err == nil {
+		if opts, err = opts.nextRun(); err == nil {
+			err = setupSymlinks(configs, opts)
+		}
+		if err != nil {
+			errorf("%s\n", err)
+		}
+	}
+	if err != nil {
+		ds.LastError = err.Error()
+	} else if len(drifted) > 0 {
+		ds.LastRepair, ds.Repaired = ds.LastCheck, drifted
+	}
+	if err := saveDaemonStatus(*ds); err != nil {
+		errorf("%s\n", err)
+	}
+}
+
+// driftedLinks returns the links made from configs that no longer hold what symlinker
+// put there
+func driftedLinks(configs []string) ([]string, error) {
+	st, err := loadState()
+	if err != nil {
+		return nil, err
+	}
+	var drifted []string
+	for _, l := range st.Links {
+		for _, config := range configs {
+			if l.Config == config && !isManaged(l.Link, l) {
+				drifted = append(drifted, l.Link)
+			}
+		}
+	}
+	return drifted, nil
+}
+
+// saveDaemonStatus records ds in the state file, under the lock so it can't race a run
+func saveDaemonStatus(ds daemonStatus) error {
+	if *dryRun {
+		return nil
+	}
+	unlock, err := acquireLock()
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	st, err := loadState()
+	if err != nil {
+		return err
+	}
+	st.Daemon = &ds
+	st.dirty = true
+	return st.save()
+}
+
+// printDaemonStatus prints the daemon status recorded in the state file
+func printDaemonStatus() error {
+	st, err := loadState()
+	if err != nil {
+		return err
+	}
+	ds := st.Daemon
+	if ds == nil {
+		fmt.Println("The daemon has not run.")
+		return nil
+	}
+	if ds.PID != 0 {
+		fmt.Printf("Running as pid %d since %s, checking every %s\n", ds.PID, ds.Started.Format(time.DateTime), ds.Interval)
+	} else {
+		fmt.Printf("Stopped; last started %s\n", ds.Started.Format(time.DateTime))
+	}
+	fmt.Printf("Configs: %s\n", strings.Join(ds.Configs, ", "))
+	fmt.Printf("Last check: %s\n", ds.LastCheck.Format(time.DateTime))
+	if !ds.LastRepair.IsZero() {
+		fmt.Printf("Last repair: %s (%s)\n", ds.LastRepair.Format(time.DateTime), strings.Join(ds.Repaired, ", "))
+	}
+	if ds.LastError != "" {
+		fmt.Printf("Last error: %s\n", ds.LastError)
+	}
+	return nil
+}
@@ -0,0 +1,92 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+)
+
+// Tools mode=decrypt entries are decrypted with
+const (
+	decryptAge = "age"
+	decryptGPG = "gpg"
+)
+
+// decryptTool tells from its name, or else its first line, whether an encrypted file is
+// for age or GPG
+func decryptTool(path string) string {
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".age":
+		return decryptAge
+	case ".gpg", ".pgp", ".asc":
+		return decryptGPG
+	}
+	if file, err := os.Open(path); err == nil {
+		defer file.Close()
+		line, _ := bufio.NewReader(file).ReadString('\n')
+		if strings.HasPrefix(line, "age-encryption.org/") || strings.HasPrefix(line, "-----BEGIN AGE ENCRYPTED FILE-----") {
+			return decryptAge
+		}
+	}
+	return decryptGPG
+}
+
+// checkDecrypt reports why target can't be decrypted, before anything in the way is removed
+func checkDecrypt(target string) error {
+	info, err := os.Stat(target)
+	if err != nil {
+		return fmt.Errorf("cannot decrypt %s: %w", target, err)
+	}
+	if !info.Mode().IsRegular() {
+		return fmt.Errorf("cannot decrypt %s: only regular files can be decrypted", target)
+	}
+	tool := decryptTool(target)
+	if _, err := exec.LookPath(tool); err != nil {
+		return fmt.Errorf("cannot decrypt %s: %s is not installed", target, tool)
+	}
+	if tool == decryptAge {
+		if _, err := os.Stat(expandTilde(*ageIdentity)); err != nil {
+			return fmt.Errorf("cannot decrypt %s: no age identity (use --age-identity): %w", target, err)
+		}
+	}
+	return nil
+}
+
+// decryptFile decrypts target into a new file at path, readable by its owner only. The
+// plaintext is written next to path and renamed into place, so it never lands anywhere
+// else and a failed decryption leaves nothing behind.
+func decryptFile(target, path string) error {
+	tool := decryptTool(target)
+	var cmd *exec.Cmd
+	if tool == decryptAge {
+		cmd = exec.Command("age", "--decrypt", "--identity", expandTilde(*ageIdentity), target)
+	} else {
+		cmd = exec.Command("gpg", "--quiet", "--decrypt", target)
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(path), "."+filepath.Base(path)+".symlinker-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	if err := tmp.Chmod(0600); err != nil {
+		tmp.Close()
+		return err
+	}
+
+	// Passphrase prompts need the terminal
+	cmd.Stdin, cmd.Stdout, cmd.Stderr = os.Stdin, tmp, os.Stderr
+	activeProgress.clear()
+	flushConsole()
+	err = cmd.Run()
+	if closeErr := tmp.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return fmt.Errorf("error decrypting %s with %s: %w", target, tool, err)
+	}
+	return os.Rename(tmp.Name(), path)
+}
@@ -0,0 +1,231 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"runtime"
+	"sort"
+)
+
+// Severity levels for doctor findings
+const (
+	levelOK    = "OK"
+	levelWarn  = "WARN"
+	levelError = "ERROR"
+)
+
+// finding is a single diagnostic result with an optional suggested fix
+type finding struct {
+	level   string
+	message string
+	hint    string
+}
+
+// runDoctor checks the config and environment and prints actionable findings
+func runDoctor(args []string, configFilePath string) error {
+	flags := flag.NewFlagSet("doctor", flag.ExitOnError)
+	flags.Usage = func() {
+		fmt.Println("Usage: symlinker doctor [config-file]")
+		fmt.Println("\nCheck that the config parses, variables resolve, targets exist and links can be created.")
+	}
+	rest := parseArgs(flags, args)
+	if len(rest) > 0 {
+		configFilePath = rest[0]
+	}
+
+	fmt.Printf("Checking config: %s\n\n", configFilePath)
+	errorCount, warnCount := printFindings(diagnose(configFilePath))
+
+	fmt.Printf("\n%d errors, %d warnings\n", errorCount, warnCount)
+	if errorCount > 0 {
+		return fmt.Errorf("doctor found %d errors", errorCount)
+	}
+	return nil
+}
+
+// printFindings prints findings with their hints and counts errors and warnings
+func printFindings(findings []finding) (errorCount, warnCount int) {
+	for _, f := range findings {
+		switch f.level {
+		case levelError:
+			errorCount++
+		case levelWarn:
+			warnCount++
+		}
+		fmt.Printf("[%s] %s\n", f.level, f.message)
+		if f.hint != "" {
+			fmt.Printf("       -> %s\n", f.hint)
+		}
+	}
+	return errorCount, warnCount
+}
+
+// diagnose runs every doctor check against a config file
+func diagnose(configFilePath string) []finding {
+	entries, warnings, err := readConfig(configFilePath)
+	if err != nil {
+		return []finding{{levelError, err.Error(), "check the config path or pass it explicitly: symlinker doctor <config-file>"}}
+	}
+
+	var findings []finding
+	if len(warnings) == 0 {
+		findings = append(findings, finding{levelOK, fmt.Sprintf("Config parses (%d entries)", len(entries)), ""})
+	}
+	for _, w := range warnings {
+		findings = append(findings, finding{levelWarn, w, "each line needs <symlink_path> <actual_path>"})
+	}
+
+	findings = append(findings, checkVariables(entries)...)
+	findings = append(findings, checkConflicts(entries)...)
+	findings = append(findings, checkCycles(entries)...)
+	findings = append(findings, checkTargets(entries)...)
+	findings = append(findings, checkLinkDirs(entries)...)
+	return findings
+}
+
+// checkVariables reports environment variables referenced by the config that are not set
+func checkVariables(entries []entry) []finding {
+	lines := map[string][]int{}
+	for _, e := range entries {
+		for _, name := range append(unsetVars(e.rawLink), unsetVars(e.rawTarget)...) {
+			lines[name] = append(lines[name], e.line)
+		}
+	}
+	if len(lines) == 0 {
+		return []finding{{levelOK, "All environment variables resolve", ""}}
+	}
+
+	names := make([]string, 0, len(lines))
+	for name := range lines {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var findings []finding
+	for _, name := range names {
+		findings = append(findings, finding{
+			levelError,
+			fmt.Sprintf("$%s is not set (used on lines %v)", name, lines[name]),
+			fmt.Sprintf("export %s=... before running symlinker", name),
+		})
+	}
+	return findings
+}
+
+// checkConflicts reports entries that would clobber each other
+func checkConflicts(entries []entry) []finding {
+	var findings []finding
+	for _, p := range conflictingEntries(entries) {
+		findings = append(findings, finding{levelError, p, "remove or change one of the entries"})
+	}
+	if len(findings) == 0 {
+		return []finding{{levelOK, "No duplicate or nested link paths", ""}}
+	}
+	return findings
+}
+
+// checkCycles reports entries whose link would resolve to itself
+func checkCycles(entries []entry) []finding {
+	var findings []finding
+	for _, e := range entries {
+		// A plugin entry's actual path means what its plugin makes of it
+		if isPluginMode(e.options["mode"]) {
+			continue
+		}
+		if reason := linkCycle(e.link, e.target); reason != "" {
+			findings = append(findings, finding{levelError, fmt.Sprintf("Line %d: %s -> %s would be a symlink loop: %s", e.line, e.link, e.target, reason), "check the paths for a typo"})
+		}
+	}
+	if len(findings) == 0 {
+		return []finding{{levelOK, "No entry links to itself", ""}}
+	}
+	return findings
+}
+
+// checkTargets reports entries whose actual path doesn't exist
+func checkTargets(entries []entry) []finding {
+	var findings []finding
+	for _, e := range entries {
+		if isPluginMode(e.options["mode"]) {
+			continue
+		}
+		if _, err := os.Stat(e.target); err != nil {
+			findings = append(findings, finding{
+				levelWarn,
+				fmt.Sprintf("Line %d: target does not exist: %s", e.line, e.target),
+				"fix the path in the config or create the target; otherwise a dangling link is created",
+			})
+		}
+	}
+	if len(findings) == 0 {
+		return []finding{{levelOK, "All targets exist", ""}}
+	}
+	return findings
+}
+
+// checkLinkDirs verifies symlinks can be created in every directory links will be placed in
+func checkLinkDirs(entries []entry) []finding {
+	checked := map[string]bool{}
+	var findings []finding
+	for _, e := range entries {
+		dir := existingAncestor(filepath.Dir(e.link))
+		if checked[dir] {
+			continue
+		}
+		checked[dir] = true
+
+		if err := probeSymlink(dir); err != nil {
+			level := levelError
+			hint := "this filesystem may not support symlinks; use --copy-fallback or mode=copy"
+			switch {
+			case !errors.Is(err, errNoSymlinks):
+				hint = "the directory is not writable; run as a user that can write there, or change the link path"
+			case runtime.GOOS == "windows":
+				// Directories get junctions and files get copies instead
+				level = levelWarn
+				hint = "enable Developer Mode or run as administrator to get real symlinks; junctions and copies are used until then"
+			}
+			findings = append(findings, finding{level, fmt.Sprintf("Cannot create symlinks in %s: %s", dir, err), hint})
+		}
+	}
+	if len(findings) == 0 {
+		return []finding{{levelOK, fmt.Sprintf("Symlinks can be created in all %d link directories", len(checked)), ""}}
+	}
+	return findings
+}
+
+// existingAncestor returns the closest directory at or above dir that exists
+func existingAncestor(dir string) string {
+	for {
+		if _, err := os.Stat(dir); err == nil {
+			return dir
+		}
+		parent := filepath.Dir(dir)
+		if parent == dir {
+			return dir
+		}
+		dir = parent
+	}
+}
+
+// errNoSymlinks is returned by probeSymlink when a directory is writable but rejects symlinks
+var errNoSymlinks = errors.New("symlinks not supported")
+
+// probeSymlink creates and removes a temporary symlink in dir
+func probeSymlink(dir string) error {
+	tmp, err := os.CreateTemp(dir, ".symlinker-doctor-*")
+	if err != nil {
+		return err
+	}
+	tmp.Close()
+	defer os.Remove(tmp.Name())
+
+	link := tmp.Name() + ".link"
+	if err := os.Symlink(tmp.Name(), link); err != nil {
+		return fmt.Errorf("%w: %v", errNoSymlinks, err)
+	}
+	return os.Remove(link)
+}
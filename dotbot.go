@@ -0,0 +1,189 @@
+package main
+
+import (
+	"fmt"
+	"maps"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// Dotbot link options symlinker can't reproduce
+var unsupportedDotbotLinkOptions = []string{"glob", "if", "canonicalize", "exclude", "prefix", "ignore-missing"}
+
+// dotbotSchema is checked before a Dotbot config is read, so misspelled options aren't silently ignored
+var dotbotSchema = loadSchema(dotbotSchemaJSON)
+
+// isDotbotConfig reports whether a config path looks like a Dotbot config file
+func isDotbotConfig(configFilePath string) bool {
+	switch strings.ToLower(filepath.Ext(configFilePath)) {
+	case ".yaml", ".yml", ".json":
+		return true
+	}
+	return false
+}
+
+// parseDotbotConfig reads the link directives of a Dotbot install.conf.yaml, or of an
+// install.conf.json if isJSON is set, resolving relative targets against baseDir
+func parseDotbotConfig(data []byte, isJSON bool, baseDir string) ([]entry, []string, error) {
+	var root *node
+	var err error
+	if isJSON {
+		root, err = parseJSONNode(data)
+	} else {
+		root, err = parseYAML(data)
+	}
+	if err != nil {
+		return nil, nil, fmt.Errorf("error parsing Dotbot config: %w", err)
+	}
+	if err := validateSchema(root, dotbotSchema); err != nil {
+		return nil, nil, fmt.Errorf("error in Dotbot config: %w", err)
+	}
+
+	var entries []entry
+	var warnings []string
+	var defaults *node
+	for _, directive := range root.items {
+		if directive.kind != mappingNode {
+			return nil, nil, fmt.Errorf("error parsing Dotbot config: line %d: expected a directive mapping", directive.line)
+		}
+		for i, key := range directive.keys {
+			val := directive.vals[i]
+			switch key.value {
+			case "defaults":
+				defaults = val.get("link")
+				if defaults != nil {
+					warnings = append(warnings, dotbotOptionWarnings(defaults, "defaults")...)
+				}
+			case "link":
+				linkEntries, linkWarnings, err := dotbotLinks(val, defaults, baseDir)
+				if err != nil {
+					return nil, nil, fmt.Errorf("error parsing Dotbot config: %w", err)
+				}
+				entries = append(entries, linkEntries...)
+				warnings = append(warnings, linkWarnings...)
+			default:
+				warnings = append(warnings, fmt.Sprintf("Dotbot directive '%s' at line %d is not supported and was ignored", key.value, key.line))
+			}
+		}
+	}
+	return entries, warnings, nil
+}
+
+// dotbotLinks converts the body of a Dotbot link directive into entries
+func dotbotLinks(links, defaults *node, baseDir string) ([]entry, []string, error) {
+	if links.kind != mappingNode {
+		return nil, nil, fmt.Errorf("line %d: link directive must be a mapping", links.line)
+	}
+
+	var entries []entry
+	var warnings []string
+	for i, key := range links.keys {
+		val := links.vals[i]
+
+		rawTarget := ""
+		switch val.kind {
+		case scalarNode:
+			rawTarget = val.value
+		case mappingNode:
+			if path := val.get("path"); path != nil {
+				rawTarget = path.value
+			}
+			warnings = append(warnings, dotbotOptionWarnings(val, key.value)...)
+		default:
+			return nil, nil, fmt.Errorf("line %d: invalid link value for %s", val.line, key.value)
+		}
+
+		// An empty path means the link's basename without its leading dot
+		if rawTarget == "" {
+			rawTarget = strings.TrimPrefix(filepath.Base(key.value), ".")
+		}
+
+		target := expandTilde(expandPath(rawTarget))
+		if !filepath.IsAbs(target) {
+			target = filepath.Join(baseDir, target)
+		}
+
+		// force decides whether existing files are replaced, like on-conflict; Dotbot
+		// leaves them alone unless it is set
+		options := map[string]string{"on-conflict": conflictSkip}
+		force := val.get("force")
+		if force == nil {
+			force = defaults.get("force")
+		}
+		if on, err := force.bool(); err == nil && on {
+			options["on-conflict"] = conflictOverwrite
+		}
+
+		relative := val.get("relative")
+		if relative == nil {
+			relative = defaults.get("relative")
+		}
+		if on, err := relative.bool(); err == nil {
+			options["relative"] = strconv.FormatBool(on)
+		}
+
+		// Options of symlinker's own, which Dotbot ignores, override the ones above
+		own := dotbotOwnOptions(defaults.get("symlinker"))
+		maps.Copy(own, dotbotOwnOptions(val.get("symlinker")))
+		ownOptions, ownWarnings := validateOptions(own, key.line)
+		warnings = append(warnings, ownWarnings...)
+		maps.Copy(options, ownOptions)
+
+		entries = append(entries, entry{
+			line:      key.line,
+			rawLink:   key.value,
+			rawTarget: rawTarget,
+			link:      expandTilde(expandPath(key.value)),
+			target:    target,
+			options:   options,
+		})
+	}
+	return entries, warnings, nil
+}
+
+// dotbotOwnOptions reads the options under a link's symlinker key, written as they are
+// after the paths of a line-based entry
+func dotbotOwnOptions(n *node) map[string]string {
+	options := map[string]string{}
+	if n == nil || n.kind != mappingNode {
+		return options
+	}
+	for i, key := range n.keys {
+		options[key.value] = n.vals[i].value
+	}
+	return options
+}
+
+// dotbotOptionWarnings reports link options that symlinker can't honor
+func dotbotOptionWarnings(options *node, name string) []string {
+	var warnings []string
+	for i, key := range options.keys {
+		for _, unsupported := range unsupportedDotbotLinkOptions {
+			if key.value == unsupported {
+				warnings = append(warnings, fmt.Sprintf("Dotbot link option '%s' for %s at line %d is not supported and was ignored", key.value, name, key.line))
+			}
+		}
+
+		// Conflicts are governed by force alone, so without it existing symlinks are kept too
+		if key.value == "relink" {
+			if on, err := options.vals[i].bool(); err == nil && on {
+				warnings = append(warnings, fmt.Sprintf("Dotbot link option 'relink: true' for %s at line %d is not supported; use force or on-conflict under symlinker instead", name, key.line))
+			}
+		}
+	}
+	return warnings
+}
+
+// expandTilde expands a leading ~ to the current user's home directory
+func expandTilde(path string) string {
+	if path != "~" && !strings.HasPrefix(path, "~/") {
+		return path
+	}
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return path
+	}
+	return filepath.Join(home, strings.TrimPrefix(path, "~"))
+}
@@ -0,0 +1,99 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// varUse is an environment variable a config refers to, and the entries that refer to it
+type varUse struct {
+	name    string
+	entries []entry
+}
+
+// varUses returns the variables the entries' paths refer to, by name, with the entries
+// that use each
+func varUses(entries []entry) []varUse {
+	names := configVars(entries)
+	uses := make([]varUse, len(names))
+	for i, name := range names {
+		uses[i].name = name
+		for _, e := range entries {
+			if slices.Contains(referencedVars(e.rawLink+" "+e.rawTarget), name) {
+				uses[i].entries = append(uses[i].entries, e)
+			}
+		}
+	}
+	return uses
+}
+
+// lines lists where a variable is used, as "a.conf line 1, 4; b.conf line 2"
+func (u varUse) lines() string {
+	var parts []string
+	var config string
+	var nums []string
+	flush := func() {
+		if len(nums) > 0 {
+			parts = append(parts, fmt.Sprintf("%s line %s", contractPath(config), strings.Join(nums, ", ")))
+		}
+	}
+	for _, e := range u.entries {
+		if e.config != config {
+			flush()
+			config, nums = e.config, nil
+		}
+		nums = append(nums, strconv.Itoa(e.line))
+	}
+	flush()
+	return strings.Join(parts, "; ")
+}
+
+// runEnv lists the environment variables the configs refer to, with their values
+func runEnv(args []string, configFilePath string) error {
+	flags := flag.NewFlagSet("env", flag.ExitOnError)
+	flags.Usage = func() {
+		fmt.Println("Usage: symlinker env [config-file...]")
+		fmt.Println("\nList every environment variable the configs' paths refer to, its value and the")
+		fmt.Println("lines that use it. Exits 1 if any of them is not set.")
+	}
+	configs := parseArgs(flags, args)
+	if len(configs) == 0 {
+		configs = []string{configFilePath}
+	}
+
+	// Each config is read on its own, so lines that another config overrides are listed too
+	var entries []entry
+	for _, config := range configs {
+		configEntries, err := parseConfig(config)
+		if err != nil {
+			return err
+		}
+		entries = append(entries, configEntries...)
+	}
+
+	uses := varUses(entries)
+	width := 0
+	for _, u := range uses {
+		width = max(width, len(u.name))
+	}
+	missing := 0
+	for _, u := range uses {
+		value, ok := os.LookupEnv(u.name)
+		if !ok {
+			value = paint(styleError, "MISSING")
+			missing++
+		}
+		fmt.Printf("%-*s  %s\n%*s  used at %s\n", width, u.name, value, width, "", u.lines())
+	}
+	if len(uses) == 0 {
+		fmt.Println("The configs refer to no environment variables.")
+	}
+	if missing > 0 {
+		return withExitCode(exitEnv, fmt.Errorf("%d variables are not set", missing))
+	}
+	return nil
+}
@@ -0,0 +1,61 @@
+package main
+
+import "errors"
+
+// Exit codes, so wrapper scripts can tell kinds of failure apart
+const (
+	exitOK       = 0
+	exitFailure  = 1 // one or more entries couldn't be applied
+	exitConfig   = 2 // the config or the command line is missing or invalid
+	exitEnv      = 3 // the environment is missing something: an actual path, the home directory
+	exitConflict = 4 // something in the way was refused rather than removed
+	exitLocked   = 5 // another run holds the lock and --no-wait was given
+	exitChanged  = 6 // with --changed-exit-code: the run changed something, or a dry run would
+)
+
+// exitCodes documents the exit codes in --help
+var exitCodes = []struct {
+	code int
+	text string
+}{
+	{exitOK, "success"},
+	{exitFailure, "one or more entries failed"},
+	{exitConfig, "config missing or invalid, or bad command line"},
+	{exitEnv, "environment problem, such as a missing actual path with --require-target"},
+	{exitConflict, "refused to remove something in the way (see --force, --backup)"},
+	{exitLocked, "another run is in progress (with --no-wait)"},
+	{exitChanged, "links were changed, or with --dry-run would be (with --changed-exit-code)"},
+}
+
+// errChanged is what an apply run returns under --changed-exit-code when it changed
+// something; main exits with exitChanged without reporting it as an error
+var errChanged = withExitCode(exitChanged, errors.New("links changed"))
+
+// exitError attaches an exit code to an error
+type exitError struct {
+	code int
+	err  error
+}
+
+func (e *exitError) Error() string { return e.err.Error() }
+func (e *exitError) Unwrap() error { return e.err }
+
+// withExitCode marks err to make symlinker exit with code; a nil err stays nil
+func withExitCode(code int, err error) error {
+	if err == nil {
+		return nil
+	}
+	return &exitError{code, err}
+}
+
+// exitCodeOf returns the exit code for an error returned by a command
+func exitCodeOf(err error) int {
+	var e *exitError
+	if errors.As(err, &e) {
+		return e.code
+	}
+	if err != nil {
+		return exitFailure
+	}
+	return exitOK
+}
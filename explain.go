@@ -0,0 +1,163 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// runExplain shows how one link path is defined and what applying the configs would do to it
+func runExplain(args []string, configFilePath string) error {
+	flags := flag.NewFlagSet("explain", flag.ExitOnError)
+	flags.Usage = func() {
+		fmt.Println("Usage: symlinker explain <link-path> [config-file...]")
+		fmt.Println("\nShow which config line defines a link path, how its variables expand, what is")
+		fmt.Println("there now and what applying the configs would do, without changing anything.")
+	}
+	rest := parseArgs(flags, args)
+	if len(rest) == 0 {
+		flags.Usage()
+		return withExitCode(exitConfig, fmt.Errorf("explain needs a link path"))
+	}
+	link := absPath(expandTilde(expandPath(rest[0])))
+	configs := rest[1:]
+	if len(configs) == 0 {
+		configs = []string{configFilePath}
+	}
+
+	// Every definition is found, so overridden ones can be shown too
+	var defs []entry
+	for _, config := range configs {
+		entries, err := parseConfig(config)
+		if err != nil {
+			return err
+		}
+		for _, e := range entries {
+			if absPath(e.link) == link {
+				defs = append(defs, e)
+			}
+		}
+	}
+	st, err := loadState()
+	if err != nil {
+		return err
+	}
+	if len(defs) == 0 {
+		if l, ok := st.lookup(link); ok {
+			fmt.Printf("%s is not in the configs, but symlinker made it from %s; clean would remove it.\n", contractPath(link), contractPath(l.Config))
+		} else {
+			fmt.Printf("%s is not in the configs.\n", contractPath(link))
+		}
+		return withExitCode(exitConfig, fmt.Errorf("no entry for %s", link))
+	}
+
+	printExplanation(link, defs, st)
+	return nil
+}
+
+// printExplanation shows how a link path is defined and applied. The last of defs is the
+// one applied, as later configs override earlier ones.
+func printExplanation(link string, defs []entry, st *state) {
+	e := defs[len(defs)-1]
+	fmt.Println(contractPath(link))
+	fmt.Printf("  Defined at:  %s line %d: %s %s%s\n", contractPath(e.config), e.line, e.rawLink, e.rawTarget, formatOptions(e.options))
+	for _, d := range defs[:len(defs)-1] {
+		fmt.Printf("  Overrides:   %s line %d: %s %s%s\n", contractPath(d.config), d.line, d.rawLink, d.rawTarget, formatOptions(d.options))
+	}
+	for _, name := range referencedVars(e.rawLink + " " + e.rawTarget) {
+		if value, ok := os.LookupEnv(name); ok {
+			fmt.Printf("  Variable:    $%s = %s\n", name, value)
+		} else {
+			fmt.Printf("  Variable:    $%s is not set\n", name)
+		}
+	}
+
+	mode := e.options["mode"]
+	actual := underRoot(*rootDir, e.target)
+	e.link = underRoot(*rootDir, e.link)
+	fmt.Printf("  Link path:   %s\n", e.link)
+	fmt.Printf("  Actual path: %s (%s)\n", actual, describeForAudit(actual))
+	if mode != "" {
+		fmt.Printf("  Mode:        %s\n", mode)
+	}
+
+	if isPluginMode(mode) {
+		status, detail := pluginStatus(e)
+		fmt.Printf("  Status:      %s%s\n", status, detail)
+		if status == entryPending {
+			fmt.Printf("  Apply would: run %s%s\n", pluginPrefix, mode)
+		}
+		return
+	}
+
+	status, detail := entryStatus(e, e.link, st)
+	if detail == "" && status != entryMissing {
+		detail = " (" + describeForAudit(e.link) + ")"
+	}
+	fmt.Printf("  Now:         %s%s\n", status, detail)
+	if l, ok := st.lookup(e.link); ok {
+		fmt.Printf("  Managed:     made %s from %s\n", l.Created.Format("2006-01-02 15:04"), contractPath(l.Config))
+	}
+	fmt.Printf("  Apply would: %s\n", explainApply(e, actual, st))
+}
+
+// explainApply says what applying an entry would do, as applyEntries decides it
+func explainApply(e entry, actual string, st *state) string {
+	target := e.target
+	mode := e.options["mode"]
+	switch {
+	case mode != "" && mode != modeSymlink:
+		target = actual
+	case entryFlag(e, "relative", *relative):
+		if rel, err := filepath.Rel(filepath.Dir(e.link), e.target); err == nil {
+			target = rel
+		}
+	}
+
+	var notes []string
+	if _, err := os.Stat(actual); os.IsNotExist(err) {
+		switch {
+		case entryFlag(e, "create", *createTargets):
+			notes = append(notes, "creating the missing actual path first")
+		case *requireTarget:
+			return "fail, as the actual path does not exist (--require-target)"
+		default:
+			notes = append(notes, "leaving a dangling link, as the actual path does not exist")
+		}
+	}
+
+	pending := pendingAction(e, target, mode, st)
+	if pending == "" {
+		return "nothing, it is already in place"
+	}
+	// Something in the way that symlinker didn't make is subject to the conflict policy
+	info, err := os.Lstat(e.link)
+	l, managed := st.lookup(e.link)
+	if err == nil && !(managed && isManaged(e.link, l)) {
+		policy := *onConflict
+		if p := e.options["on-conflict"]; p != "" {
+			policy = p
+		}
+		kind := fileKind(info)
+		switch {
+		case policy == conflictSkip:
+			return fmt.Sprintf("skip it, leaving the existing %s alone (on-conflict=skip)", kind)
+		case policy == conflictAsk:
+			notes = append(notes, fmt.Sprintf("asking first what to do with the existing %s", kind))
+		case policy == conflictBackup || backup.enabled:
+			notes = append(notes, fmt.Sprintf("backing up the existing %s", kind))
+		case *trash:
+			notes = append(notes, fmt.Sprintf("moving the existing %s to the trash", kind))
+		case !*force && wasEdited(e.link, st):
+			return fmt.Sprintf("refuse to overwrite the %s, as it was edited since symlinker wrote it (use --force, --backup or --trash)", linkNoun(l.Mode))
+		case !*force && preciousReason(e.link, info) != "":
+			return fmt.Sprintf("refuse to remove the existing %s: %s (use --force, --backup or --trash)", kind, preciousReason(e.link, info))
+		}
+	}
+	if len(notes) > 0 {
+		pending += ", " + strings.Join(notes, ", ")
+	}
+	return pending
+}
@@ -0,0 +1,379 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"maps"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// Config formats export converts between
+const (
+	formatText = "text" // the line-based <symlink_path> <actual_path> format
+	formatYAML = "yaml" // a Dotbot install.conf.yaml
+	formatJSON = "json" // a Dotbot install.conf.json
+)
+
+// runExport converts a config between the line-based format and Dotbot's YAML and JSON
+func runExport(args []string, configFilePath string) error {
+	flags := flag.NewFlagSet("export", flag.ExitOnError)
+	to := flags.String("to", "", "Format to convert to: text, yaml or json (default yaml for a line-based config, text for a Dotbot one)")
+	output := flags.String("output", "", "Write the converted config to this file instead of stdout")
+	flags.Usage = func() {
+		fmt.Println("Usage: symlinker export [flags] [config-file]")
+		fmt.Println("\nConvert a config between the line-based format and Dotbot's install.conf.yaml or")
+		fmt.Println("install.conf.json. Options Dotbot has no equivalent for are kept under a symlinker")
+		fmt.Println("key, which Dotbot ignores, and comments are carried over except into JSON.")
+		fmt.Println("\nFlags:")
+		flags.PrintDefaults()
+	}
+	configs := parseArgs(flags, args)
+	if len(configs) > 1 {
+		flags.Usage()
+		return withExitCode(exitConfig, fmt.Errorf("export converts one config at a time"))
+	}
+	config := configFilePath
+	if len(configs) == 1 {
+		config = configs[0]
+	}
+
+	// Messages go to stderr when the config is written to stdout
+	out := os.Stdout
+	if *output == "" {
+		os.Stdout = os.Stderr
+	}
+
+	entries, err := parseConfig(config)
+	if err != nil {
+		return err
+	}
+
+	// Comments are read from the config as written; a remote config's are left behind
+	var data []byte
+	switch {
+	case config == stdinConfig:
+		data, _ = stdinData()
+	case !isRemoteConfig(config):
+		data, _ = os.ReadFile(config)
+	}
+	from := configFormat(config, data)
+	if *to == "" {
+		*to = formatYAML
+		if from != formatText {
+			*to = formatText
+		}
+	}
+	comments := configComments(data, entries)
+
+	var converted []byte
+	switch *to {
+	case formatText:
+		converted = exportText(entries, comments)
+	case formatYAML:
+		converted = exportYAML(entries, comments)
+	case formatJSON:
+		if comments.any() {
+			warnf("JSON has no comments; the config's comments were left out\n")
+		}
+		converted = exportJSON(entries)
+	default:
+		return withExitCode(exitConfig, fmt.Errorf("invalid --to %q (want text, yaml or json)", *to))
+	}
+
+	if *output == "" {
+		flushConsole()
+		_, err := out.Write(converted)
+		return err
+	}
+	if err := os.WriteFile(*output, converted, 0644); err != nil {
+		return fmt.Errorf("error writing output file: %w", err)
+	}
+	infof("Wrote %d entries to %s\n", len(entries), *output)
+	return nil
+}
+
+// configFormat tells which format a config is in, from its name or, for stdin, its content
+func configFormat(config string, data []byte) string {
+	if config == stdinConfig {
+		first := firstConfigLine(data)
+		switch {
+		case strings.HasPrefix(first, "{") || strings.HasPrefix(first, "["):
+			return formatJSON
+		case strings.HasPrefix(first, "- ") || first == "-":
+			return formatYAML
+		}
+		return formatText
+	}
+	if !isDotbotConfig(config) {
+		return formatText
+	}
+	if strings.EqualFold(filepath.Ext(config), ".json") {
+		return formatJSON
+	}
+	return formatYAML
+}
+
+// exportComments are the comment lines of a config, each kept with the entry it comes
+// before. An empty string stands for one or more blank lines.
+type exportComments struct {
+	before   map[int][]string // by entry line
+	trailing []string         // after the last entry
+}
+
+// any reports whether the config has comments
+func (c exportComments) any() bool {
+	for _, block := range append(slices.Collect(maps.Values(c.before)), c.trailing) {
+		for _, line := range block {
+			if line != "" {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// configComments collects the comments of a config between its entries. Comments at
+// the end of a line are not kept.
+func configComments(data []byte, entries []entry) exportComments {
+	c := exportComments{before: map[int][]string{}}
+	if len(data) == 0 {
+		return c
+	}
+	lines := strings.Split(strings.ReplaceAll(string(data), "\r\n", "\n"), "\n")
+	block := func(from, to int) []string {
+		var b []string
+		for n := from; n < to && n <= len(lines); n++ {
+			switch line := strings.TrimSpace(lines[n-1]); {
+			case strings.HasPrefix(line, "#"):
+				b = append(b, line)
+			case line == "" && (len(b) == 0 || b[len(b)-1] != ""):
+				b = append(b, "")
+			}
+		}
+		return b
+	}
+
+	prev := 0
+	for _, e := range entries {
+		if e.line <= prev {
+			continue
+		}
+		b := block(prev+1, e.line)
+		if prev == 0 && len(b) > 0 && b[0] == "" {
+			b = b[1:]
+		}
+		c.before[e.line] = b
+		prev = e.line
+	}
+	c.trailing = block(prev+1, len(lines)+1)
+	for len(c.trailing) > 0 && c.trailing[len(c.trailing)-1] == "" {
+		c.trailing = c.trailing[:len(c.trailing)-1]
+	}
+	if prev == 0 && len(c.trailing) > 0 && c.trailing[0] == "" {
+		c.trailing = c.trailing[1:]
+	}
+	return c
+}
+
+// exportText writes entries in the line-based format
+func exportText(entries []entry, comments exportComments) []byte {
+	var b bytes.Buffer
+	for _, e := range entries {
+		writeComments(&b, comments.before[e.line], "")
+		fmt.Fprintf(&b, "%s %s%s\n", quoteField(textPath(e.rawLink)), quoteField(textPath(exportTarget(e))), formatOptions(e.options))
+	}
+	writeComments(&b, comments.trailing, "")
+	return b.Bytes()
+}
+
+// exportYAML writes entries as a Dotbot install.conf.yaml. A link path that comes up
+// twice starts a new link directive, as one mapping can't hold it twice.
+func exportYAML(entries []entry, comments exportComments) []byte {
+	var b bytes.Buffer
+	var seen map[string]bool
+	for i, e := range entries {
+		if i == 0 || seen[e.rawLink] {
+			writeComments(&b, comments.before[e.line], "")
+			b.WriteString("- link:\n")
+			seen = map[string]bool{}
+		} else {
+			writeComments(&b, comments.before[e.line], "    ")
+		}
+		seen[e.rawLink] = true
+
+		link, own := dotbotLink(e)
+		if len(link) == 1 && len(own) == 0 {
+			fmt.Fprintf(&b, "    %s: %s\n", yamlScalar(e.rawLink), yamlScalar(link[0].value))
+			continue
+		}
+		fmt.Fprintf(&b, "    %s:\n", yamlScalar(e.rawLink))
+		for _, kv := range link {
+			fmt.Fprintf(&b, "      %s: %s\n", kv.key, kv.yaml())
+		}
+		if len(own) > 0 {
+			b.WriteString("      symlinker:\n")
+			for _, kv := range own {
+				fmt.Fprintf(&b, "        %s: %s\n", kv.key, kv.yaml())
+			}
+		}
+	}
+	writeComments(&b, comments.trailing, "")
+	return b.Bytes()
+}
+
+// exportJSON writes entries as a Dotbot install.conf.json
+func exportJSON(entries []entry) []byte {
+	var b bytes.Buffer
+	b.WriteString("[\n")
+	var seen map[string]bool
+	for i, e := range entries {
+		if i == 0 || seen[e.rawLink] {
+			if i > 0 {
+				b.WriteString("\n    }\n  },\n")
+			}
+			b.WriteString("  {\n    \"link\": {\n")
+			seen = map[string]bool{}
+		} else {
+			b.WriteString(",\n")
+		}
+		seen[e.rawLink] = true
+
+		link, own := dotbotLink(e)
+		if len(link) == 1 && len(own) == 0 {
+			fmt.Fprintf(&b, "      %s: %s", jsonString(e.rawLink), jsonString(link[0].value))
+			continue
+		}
+		fmt.Fprintf(&b, "      %s: {", jsonString(e.rawLink))
+		for j, kv := range link {
+			if j > 0 {
+				b.WriteString(",")
+			}
+			fmt.Fprintf(&b, "\n        %s: %s", jsonString(kv.key), kv.json())
+		}
+		if len(own) > 0 {
+			b.WriteString(",\n        \"symlinker\": {")
+			for j, kv := range own {
+				if j > 0 {
+					b.WriteString(",")
+				}
+				fmt.Fprintf(&b, "\n          %s: %s", jsonString(kv.key), kv.json())
+			}
+			b.WriteString("\n        }")
+		}
+		b.WriteString("\n      }")
+	}
+	if len(entries) > 0 {
+		b.WriteString("\n    }\n  }\n")
+	}
+	b.WriteString("]\n")
+	return b.Bytes()
+}
+
+// dotbotOption is one key of a Dotbot link, in the order it is written
+type dotbotOption struct {
+	key   string
+	value string
+	bool  bool // written as a boolean rather than a string
+}
+
+// yaml renders the option's value for YAML
+func (o dotbotOption) yaml() string {
+	if o.bool {
+		return o.value
+	}
+	return yamlScalar(o.value)
+}
+
+// json renders the option's value for JSON
+func (o dotbotOption) json() string {
+	if o.bool {
+		return o.value
+	}
+	return jsonString(o.value)
+}
+
+// dotbotLink returns the keys of an entry's Dotbot link, path first, and the options
+// Dotbot has no equivalent for. A link with nothing but a path is written as the path alone.
+func dotbotLink(e entry) (link, own []dotbotOption) {
+	link = []dotbotOption{{key: "path", value: exportTarget(e)}}
+	for _, key := range slices.Sorted(maps.Keys(e.options)) {
+		value := e.options[key]
+		switch on, err := strconv.ParseBool(value); {
+		case key == "on-conflict" && value == conflictOverwrite:
+			link = append(link, dotbotOption{key: "force", value: "true", bool: true})
+		case key == "on-conflict" && value == conflictSkip:
+			link = append(link, dotbotOption{key: "force", value: "false", bool: true})
+		case key == "relative" && err == nil:
+			link = append(link, dotbotOption{key: "relative", value: strconv.FormatBool(on), bool: true})
+		case key == "create" && err == nil:
+			own = append(own, dotbotOption{key: key, value: strconv.FormatBool(on), bool: true})
+		default:
+			own = append(own, dotbotOption{key: key, value: value})
+		}
+	}
+	return link, own
+}
+
+// exportTarget returns an entry's actual path to write. A relative path is written as
+// the absolute path it meant, as the formats resolve relative paths differently: the
+// line-based format against the working directory, Dotbot against the config's.
+func exportTarget(e entry) string {
+	raw := e.rawTarget
+	if filepath.IsAbs(raw) || strings.HasPrefix(raw, "$") || strings.HasPrefix(raw, "~") || strings.HasPrefix(raw, "%") {
+		return raw
+	}
+	return contractPath(absPath(e.target))
+}
+
+// textPath writes a leading ~, which Dotbot expands but the line-based format doesn't, as $HOME
+func textPath(path string) string {
+	if path == "~" || strings.HasPrefix(path, "~/") {
+		return "$HOME" + strings.TrimPrefix(path, "~")
+	}
+	return path
+}
+
+// writeComments writes a block of comment lines at the given indent
+func writeComments(b *bytes.Buffer, block []string, indent string) {
+	for _, line := range block {
+		if line == "" {
+			b.WriteString("\n")
+			continue
+		}
+		b.WriteString(indent + line + "\n")
+	}
+}
+
+// yamlScalar writes s as a plain YAML scalar when it reads back as the same string,
+// and double-quoted otherwise
+func yamlScalar(s string) string {
+	plain := s != "" && s == strings.TrimSpace(s) &&
+		!strings.ContainsAny(s[:1], "!&*{}[],#|>@`\"'%?:-") &&
+		!strings.Contains(s, ": ") && !strings.Contains(s, " #") && !strings.HasSuffix(s, ":")
+	switch strings.ToLower(s) {
+	case "~", "null", "true", "false", "yes", "no", "on", "off":
+		plain = false
+	}
+	if _, err := strconv.ParseFloat(s, 64); err == nil {
+		plain = false
+	}
+	if plain {
+		return s
+	}
+	return jsonString(s)
+}
+
+// jsonString encodes s as a JSON string, which is also a valid double-quoted YAML scalar
+func jsonString(s string) string {
+	var b bytes.Buffer
+	enc := json.NewEncoder(&b)
+	enc.SetEscapeHTML(false)
+	enc.Encode(s)
+	return strings.TrimSuffix(b.String(), "\n")
+}
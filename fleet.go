@@ -0,0 +1,221 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// fleetReport is what a run sends to --report-url, and what --metrics-file is written from,
+// so runs across many machines can be watched from one place
+type fleetReport struct {
+	Host     string         `json:"host"`
+	Command  string         `json:"command"` // apply or status
+	Time     time.Time      `json:"time"`
+	Configs  []string       `json:"configs"`
+	Entries  int            `json:"entries"`
+	Failed   int            `json:"failed"`
+	Drifted  int            `json:"drifted"`            // entries that weren't in place
+	Summary  *runSummary    `json:"summary,omitempty"`  // apply's counts
+	Statuses map[string]int `json:"statuses,omitempty"` // status's count of each entry status
+	Error    string         `json:"error,omitempty"`
+	Build    buildInfo      `json:"symlinker"`
+}
+
+// newFleetReport starts the report of a run of command over entries
+func newFleetReport(command string, entries []entry, err error) fleetReport {
+	r := fleetReport{Command: command, Time: time.Now(), Entries: len(entries), Build: currentBuild()}
+	r.Host, _ = os.Hostname()
+	r.Configs = []string{}
+	for _, e := range entries {
+		if !slices.Contains(r.Configs, e.config) {
+			r.Configs = append(r.Configs, e.config)
+		}
+	}
+	if err != nil {
+		r.Error = err.Error()
+	}
+	return r
+}
+
+// applyReport is the report of an apply run
+func applyReport(entries []entry, s runSummary, err error) fleetReport {
+	r := newFleetReport("apply", entries, err)
+	r.Summary = &s
+	r.Failed = s.Failed
+	r.Drifted = s.Created + s.Relinked + s.Replaced + s.Skipped + s.Sudo + s.Failed
+	return r
+}
+
+// statusReport is the report of a status run. Dangling links and plugin entries that
+// couldn't be checked count as failed.
+func statusReport(entries []entry, counts map[string]int) fleetReport {
+	r := newFleetReport("status", entries, nil)
+	r.Statuses = map[string]int{}
+	for status, n := range counts {
+		if status != entryOrphaned {
+			r.Statuses[status] = n
+		}
+	}
+	r.Failed = counts[entryFailed] + counts[entryDangling]
+	r.Drifted = len(entries) - counts[entryLinked]
+	return r
+}
+
+// reportRun sends a run's report to --report-url and writes --metrics-file. Neither
+// failing fails the run, which has already done its work; they are warned about. An
+// apply run's report is also kept in the state directory, for serve's /last-run.
+func reportRun(r fleetReport) {
+	if r.Command == "apply" {
+		if err := saveLastRun(r); err != nil {
+			warnf("can't record the run: %s\n", err)
+		}
+	}
+	if *reportURL != "" {
+		if err := postReport(*reportURL, r); err != nil {
+			warnf("can't send report to %s: %s\n", *reportURL, err)
+		} else {
+			verbosef("Sent report to %s\n", *reportURL)
+		}
+	}
+	if *metricsFile != "" {
+		if err := writeMetrics(expandTilde(*metricsFile), r); err != nil {
+			warnf("can't write metrics: %s\n", err)
+		} else {
+			verbosef("Wrote metrics to %s\n", *metricsFile)
+		}
+	}
+}
+
+// lastRunPath returns the file the last apply run's report is kept in
+func lastRunPath() (string, error) {
+	dir, err := stateDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, "last-run.json"), nil
+}
+
+// saveLastRun keeps the report of an apply run as the last one
+func saveLastRun(r fleetReport) error {
+	path, err := lastRunPath()
+	if err != nil {
+		return err
+	}
+	data, err := json.MarshalIndent(r, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, append(data, '\n'), 0644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, path)
+}
+
+// loadLastRun reads the last apply run's report; it is nil if no run was recorded
+func loadLastRun() (*fleetReport, error) {
+	path, err := lastRunPath()
+	if err != nil {
+		return nil, err
+	}
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	}
+	var r fleetReport
+	if err := json.Unmarshal(data, &r); err != nil {
+		return nil, fmt.Errorf("error reading %s: %w", path, err)
+	}
+	return &r, nil
+}
+
+// postReport POSTs a report as JSON to a webhook
+func postReport(rawURL string, r fleetReport) error {
+	if u, err := url.Parse(rawURL); err != nil || (u.Scheme != "https" && u.Scheme != "http") {
+		return fmt.Errorf("invalid --report-url %q: want an http:// or https:// URL", rawURL)
+	}
+	data, err := json.Marshal(r)
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequest(http.MethodPost, rawURL, bytes.NewReader(data))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "symlinker")
+
+	client := &http.Client{Timeout: remoteTimeout}
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return fmt.Errorf("server answered %s", resp.Status)
+	}
+	return nil
+}
+
+// fleetMetrics are the gauges written to --metrics-file, each labelled with the command
+var fleetMetrics = []struct {
+	name, help string
+	value      func(r fleetReport) float64
+}{
+	{"symlinker_entries_total", "Entries in the configs of the last run.", func(r fleetReport) float64 { return float64(r.Entries) }},
+	{"symlinker_entries_failed", "Entries that failed to apply, or were found broken, in the last run.", func(r fleetReport) float64 { return float64(r.Failed) }},
+	{"symlinker_entries_drifted", "Entries that weren't in place when the last run looked.", func(r fleetReport) float64 { return float64(r.Drifted) }},
+	{"symlinker_last_run_success", "Whether the last run finished without error (1) or not (0).", func(r fleetReport) float64 {
+		if r.Error != "" || r.Failed > 0 {
+			return 0
+		}
+		return 1
+	}},
+	{"symlinker_last_run_timestamp_seconds", "When the last run finished, in seconds since the epoch.", func(r fleetReport) float64 { return float64(r.Time.Unix()) }},
+}
+
+// writeMetrics writes a report as Prometheus metrics for node_exporter's textfile
+// collector. The other command's samples already in the file are kept, so apply and
+// status runs can share one, and the file is replaced in one go so the collector never
+// reads half of it.
+func writeMetrics(path string, r fleetReport) error {
+	label := fmt.Sprintf("{command=%q}", r.Command)
+	samples := map[string][]string{}
+	if data, err := os.ReadFile(path); err == nil {
+		for _, line := range strings.Split(string(data), "\n") {
+			if name, rest, ok := strings.Cut(line, "{"); ok && !strings.HasPrefix(line, "#") && !strings.HasPrefix("{"+rest, label) {
+				samples[name] = append(samples[name], line)
+			}
+		}
+	}
+
+	var b strings.Builder
+	for _, m := range fleetMetrics {
+		lines := append(samples[m.name], m.name+label+" "+strconv.FormatFloat(m.value(r), 'f', -1, 64))
+		slices.Sort(lines)
+		fmt.Fprintf(&b, "# HELP %s %s\n# TYPE %s gauge\n%s\n", m.name, m.help, m.name, strings.Join(lines, "\n"))
+	}
+
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, []byte(b.String()), 0644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, path)
+}
@@ -0,0 +1,294 @@
+package main
+
+import (
+	"bytes"
+	"flag"
+	"fmt"
+	"os"
+	"slices"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/frizadiga/symlinker/pkg/symlinker"
+)
+
+// fmtLine is one line of a config being formatted: a blank line, a comment or an entry
+type fmtLine struct {
+	comment string // the comment, trimmed; "" for a blank line or an entry
+	entry   bool
+	link    string // as it is written out
+	target  string
+	options string // formatted as formatOptions does
+}
+
+// key identifies an entry, so exact duplicates can be found
+func (l fmtLine) key() string {
+	return l.link + "\x00" + l.target + "\x00" + l.options
+}
+
+// runFmt rewrites configs in a canonical layout, as gofmt does Go code
+func runFmt(args []string, configFilePath string) error {
+	flags := flag.NewFlagSet("fmt", flag.ExitOnError)
+	sortLinks := flags.Bool("sort", false, "Sort the entries by link path within each block of lines between blank lines")
+	check := flags.Bool("check", false, "List the configs that aren't formatted, and exit 1 if there are any, without rewriting them")
+	toStdout := flags.Bool("stdout", false, "Print the formatted configs instead of rewriting them")
+	flags.Usage = func() {
+		fmt.Println("Usage: symlinker fmt [flags] [config-file...]")
+		fmt.Println("\nRewrite line-based configs with aligned columns, sorted options, one blank line")
+		fmt.Println("between blocks and continuation lines joined, keeping the comments. An entry that")
+		fmt.Println("comes again later with the same paths and options is dropped, as the later one")
+		fmt.Println("is the one applied. Lines that can't be parsed stop the config being formatted.")
+		fmt.Println("\nFlags:")
+		flags.PrintDefaults()
+	}
+	configs := parseArgs(flags, args)
+	if len(configs) == 0 {
+		configs = []string{configFilePath}
+	}
+
+	// Messages go to stderr when configs are printed
+	out := os.Stdout
+	if *toStdout || slices.Contains(configs, stdinConfig) {
+		os.Stdout = os.Stderr
+	}
+
+	var unformatted []string
+	for _, config := range configs {
+		var data []byte
+		var err error
+		switch {
+		case isRemoteConfig(config):
+			return withExitCode(exitConfig, fmt.Errorf("fmt can't rewrite a remote config: %s", config))
+		case isDotbotConfig(config):
+			return withExitCode(exitConfig, fmt.Errorf("fmt formats line-based configs; %s is a Dotbot config", config))
+		case config == stdinConfig:
+			data, err = stdinData()
+		default:
+			data, err = os.ReadFile(config)
+		}
+		if err != nil {
+			return withExitCode(exitConfig, fmt.Errorf("error reading config file: %w", err))
+		}
+
+		formatted, dropped, err := formatConfig(data, *sortLinks)
+		if err != nil {
+			return withExitCode(exitConfig, fmt.Errorf("can't format %s: %w", config, err))
+		}
+		changed := !bytes.Equal(formatted, data)
+		switch {
+		case *check:
+			if changed {
+				fmt.Fprintln(out, config)
+				unformatted = append(unformatted, config)
+			}
+		case *toStdout || config == stdinConfig:
+			flushConsole()
+			if _, err := out.Write(formatted); err != nil {
+				return err
+			}
+		case !changed:
+			verbosef("Already formatted: %s\n", config)
+		default:
+			info, err := os.Stat(config)
+			if err != nil {
+				return err
+			}
+			if err := os.WriteFile(config, formatted, info.Mode().Perm()); err != nil {
+				return fmt.Errorf("error writing config file: %w", err)
+			}
+			infof("Formatted %s\n", config)
+		}
+		if dropped > 0 && !*check {
+			noun := "entries"
+			if dropped == 1 {
+				noun = "entry"
+			}
+			infof("Dropped %d duplicate %s from %s\n", dropped, noun, config)
+		}
+	}
+	if len(unformatted) > 0 {
+		return fmt.Errorf("%d configs aren't formatted; run symlinker fmt", len(unformatted))
+	}
+	return nil
+}
+
+// formatConfig lays out a line-based config: comments trimmed, runs of blank lines
+// made one, entries with their paths quoted only where needed, options sorted and the
+// columns of consecutive entries aligned. It returns how many duplicate entries it
+// dropped, along with any block of lines they leave with only comments. CRLF line
+// endings are kept.
+func formatConfig(data []byte, sortLinks bool) ([]byte, int, error) {
+	lines, err := symlinker.ReadLines(bytes.NewReader(data))
+	if err != nil {
+		return nil, 0, err
+	}
+
+	var parsed []fmtLine
+	for _, line := range lines {
+		text := strings.TrimSpace(line.Text)
+		switch {
+		case text == "":
+			// Blank lines at the start, and more than one in a row, are dropped
+			if len(parsed) > 0 && parsed[len(parsed)-1] != (fmtLine{}) {
+				parsed = append(parsed, fmtLine{})
+			}
+			continue
+		case strings.HasPrefix(text, "#"):
+			parsed = append(parsed, fmtLine{comment: text})
+			continue
+		}
+
+		fields, quoted, err := symlinker.SplitFieldsQuoted(text)
+		if err != nil {
+			return nil, 0, fmt.Errorf("%s: %w", line.Pos, err)
+		}
+		if len(fields) < 2 {
+			return nil, 0, fmt.Errorf("%s: want <symlink_path> <actual_path> [option=value ...]: %s", line.Pos, text)
+		}
+		options := map[string]string{}
+		for _, field := range fields[2:] {
+			key, value, found := strings.Cut(field, "=")
+			if !found {
+				return nil, 0, fmt.Errorf("%s: invalid option (want option=value): %s", line.Pos, field)
+			}
+			options[key] = value
+		}
+		parsed = append(parsed, fmtLine{entry: true, link: fmtField(fields[0], quoted[0]), target: fmtField(fields[1], quoted[1]), options: formatOptions(options)})
+	}
+	for len(parsed) > 0 && parsed[len(parsed)-1] == (fmtLine{}) {
+		parsed = parsed[:len(parsed)-1]
+	}
+
+	// The last of the same entry is kept, so the entry applied for each link path stays the same
+	dropped := 0
+	duplicate := make([]bool, len(parsed))
+	seen := map[string]bool{}
+	for i := len(parsed) - 1; i >= 0; i-- {
+		if !parsed[i].entry {
+			continue
+		}
+		if seen[parsed[i].key()] {
+			duplicate[i] = true
+			dropped++
+			continue
+		}
+		seen[parsed[i].key()] = true
+	}
+	if dropped > 0 {
+		parsed = dropDuplicates(parsed, duplicate)
+	}
+	if sortLinks {
+		parsed = sortBlocks(parsed)
+	}
+
+	// Comments and blank lines have no tabs, so they end a block of aligned columns
+	var b bytes.Buffer
+	w := tabwriter.NewWriter(&b, 0, 0, 1, ' ', tabwriter.StripEscape)
+	for _, l := range parsed {
+		if !l.entry {
+			fmt.Fprintln(w, l.comment)
+			continue
+		}
+		// Fields are escaped so tabs in quoted ones don't start a column
+		cells := []string{l.link, l.target}
+		if l.options != "" {
+			cells = append(cells, strings.TrimPrefix(l.options, " "))
+		}
+		for i, c := range cells {
+			cells[i] = "\xff" + c + "\xff"
+		}
+		fmt.Fprintln(w, strings.Join(cells, "\t"))
+	}
+	w.Flush()
+
+	formatted := b.Bytes()
+	if bytes.Contains(data, []byte("\r\n")) {
+		formatted = bytes.ReplaceAll(formatted, []byte("\n"), []byte("\r\n"))
+	}
+	return formatted, dropped, nil
+}
+
+// dropDuplicates removes the lines marked duplicate, and the blocks between blank lines
+// left with no entries, so a heading over only duplicates doesn't stay behind. Blocks
+// that never had entries, such as a comment at the top of the config, are kept.
+func dropDuplicates(lines []fmtLine, duplicate []bool) []fmtLine {
+	var kept []fmtLine
+	for start := 0; start < len(lines); {
+		end := start
+		for end < len(lines) && lines[end] != (fmtLine{}) {
+			end++
+		}
+		var block []fmtLine
+		hadEntries, hasEntries := false, false
+		for i := start; i < end; i++ {
+			hadEntries = hadEntries || lines[i].entry
+			if !duplicate[i] {
+				block = append(block, lines[i])
+				hasEntries = hasEntries || lines[i].entry
+			}
+		}
+		if len(block) > 0 && (hasEntries || !hadEntries) {
+			if len(kept) > 0 {
+				kept = append(kept, fmtLine{})
+			}
+			kept = append(kept, block...)
+		}
+		start = end + 1
+	}
+	return kept
+}
+
+// fmtField writes a path for a formatted config. One written without quotes stays as it
+// was, braces and all; quotes are only kept where they are needed.
+func fmtField(value string, quoted bool) string {
+	if !quoted {
+		return value
+	}
+	return quoteField(value)
+}
+
+// sortBlocks sorts the entries in each block between blank lines by link path, each
+// with the comments right above it. Entries with the same link path keep their order,
+// so the one applied stays the same. Comments opening a block, such as a heading,
+// stay first; comments after its last entry stay last.
+func sortBlocks(lines []fmtLine) []fmtLine {
+	var sorted []fmtLine
+	start := 0
+	for i := 0; i <= len(lines); i++ {
+		if i < len(lines) && lines[i] != (fmtLine{}) {
+			continue
+		}
+		block := lines[start:i]
+		heading := 0
+		for heading < len(block) && !block[heading].entry {
+			heading++
+		}
+		if heading == len(block) {
+			heading = 0
+		}
+		sorted = append(sorted, block[:heading]...)
+
+		// Group each entry with the comments before it
+		var groups [][]fmtLine
+		var pending []fmtLine
+		for _, l := range block[heading:] {
+			pending = append(pending, l)
+			if l.entry {
+				groups = append(groups, pending)
+				pending = nil
+			}
+		}
+		slices.SortStableFunc(groups, func(a, b []fmtLine) int {
+			return strings.Compare(a[len(a)-1].link, b[len(b)-1].link)
+		})
+		for _, g := range groups {
+			sorted = append(sorted, g...)
+		}
+		sorted = append(sorted, pending...)
+		if i < len(lines) {
+			sorted = append(sorted, lines[i])
+		}
+		start = i + 1
+	}
+	return sorted
+}
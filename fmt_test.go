@@ -0,0 +1,107 @@
+package main
+
+import (
+	"bytes"
+	"flag"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+var update = flag.Bool("update", false, "Rewrite the golden files of the tests with what they got")
+
+// TestFormatConfigGolden formats each testdata/fmt/*.in and compares the result with
+// the .golden file next to it. Configs named sort*.in are formatted with --sort.
+func TestFormatConfigGolden(t *testing.T) {
+	inputs, err := filepath.Glob(filepath.Join("testdata", "fmt", "*.in"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(inputs) == 0 {
+		t.Fatal("no testdata/fmt/*.in files")
+	}
+	for _, input := range inputs {
+		name := strings.TrimSuffix(filepath.Base(input), ".in")
+		t.Run(name, func(t *testing.T) {
+			data, err := os.ReadFile(input)
+			if err != nil {
+				t.Fatal(err)
+			}
+			got, _, err := formatConfig(data, strings.HasPrefix(name, "sort"))
+			if err != nil {
+				t.Fatalf("formatConfig: %v", err)
+			}
+			golden := strings.TrimSuffix(input, ".in") + ".golden"
+			if *update {
+				if err := os.WriteFile(golden, got, 0644); err != nil {
+					t.Fatal(err)
+				}
+			}
+			want, err := os.ReadFile(golden)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !bytes.Equal(got, want) {
+				t.Errorf("formatted %s:\n%s\nwant:\n%s", input, got, want)
+			}
+
+			// Formatting a formatted config changes nothing
+			again, dropped, err := formatConfig(got, strings.HasPrefix(name, "sort"))
+			if err != nil {
+				t.Fatalf("formatConfig of the output: %v", err)
+			}
+			if !bytes.Equal(again, got) || dropped != 0 {
+				t.Errorf("formatting the output again dropped %d entries and gave:\n%s", dropped, again)
+			}
+		})
+	}
+}
+
+func TestFormatConfigDropped(t *testing.T) {
+	tests := []struct {
+		name  string
+		input string
+		want  string
+		n     int
+	}{
+		{"none", "a b\nc d\n", "a b\nc d\n", 0},
+		{"later kept", "a b\nc d\na b\n", "c d\na b\n", 1},
+		{"options differ", "a b\na b mode=copy\n", "a b\na b mode=copy\n", 0},
+		{"emptied block", "# old\na b\n\n# new\na b\n", "# new\na b\n", 1},
+		{"emptied block in the middle", "x y\n\n# old\na b\nc d\n\nc d\na b\n", "x y\n\nc d\na b\n", 2},
+		{"comment block kept", "# top\n\na b\n\n# note\n\na b\n", "# top\n\n# note\n\na b\n", 1},
+		{"block with entries left", "# both\na b\nc d\n\na b\n", "# both\nc d\n\na b\n", 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, n, err := formatConfig([]byte(tt.input), false)
+			if err != nil {
+				t.Fatalf("formatConfig: %v", err)
+			}
+			if string(got) != tt.want || n != tt.n {
+				t.Errorf("got %q, dropped %d; want %q, dropped %d", got, n, tt.want, tt.n)
+			}
+		})
+	}
+}
+
+func TestFormatConfigErrors(t *testing.T) {
+	tests := []struct {
+		name  string
+		input string
+		want  string
+	}{
+		{"one field", "a b\nlonely\n", "line 2 (byte 4): want <symlink_path> <actual_path> [option=value ...]: lonely"},
+		{"bad option", "a b mode\n", "line 1 (byte 0): invalid option (want option=value): mode"},
+		{"unterminated quote", "\"a b\n", "line 1 (byte 0): unterminated quote"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, _, err := formatConfig([]byte(tt.input), false)
+			if err == nil || err.Error() != tt.want {
+				t.Errorf("got error %v, want %s", err, tt.want)
+			}
+		})
+	}
+}
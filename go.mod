@@ -1,3 +1,3 @@
-module symlinker
+module github.com/frizadiga/symlinker
 
 go 1.24.3
@@ -0,0 +1,52 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"runtime"
+)
+
+// validateCommand checks a pre= or post= hook command
+func validateCommand(value string) error {
+	if value == "" {
+		return fmt.Errorf("empty command")
+	}
+	return nil
+}
+
+// runHookCommand runs a pre or post hook through the shell, with what describing it in
+// messages and env added to its environment. A dry run only says what would run.
+func runHookCommand(what, command string, env []string, dryRun bool) error {
+	if dryRun {
+		infof("[DRY RUN] Would run %s: %s\n", what, command)
+		return nil
+	}
+	infof("Running %s: %s\n", what, command)
+
+	cmd := shellCommand(command)
+	cmd.Env = append(os.Environ(), env...)
+	cmd.Stdin, cmd.Stdout, cmd.Stderr = os.Stdin, os.Stdout, os.Stderr
+
+	// The command writes to the terminal itself
+	activeProgress.clear()
+	defer activeProgress.draw()
+	flushConsole()
+	if err := cmd.Run(); err != nil {
+		return fmt.Errorf("%s failed: %s: %w", what, command, err)
+	}
+	return nil
+}
+
+// shellCommand returns a command that runs command line through the shell
+func shellCommand(command string) *exec.Cmd {
+	if runtime.GOOS == "windows" {
+		return exec.Command("cmd", "/C", command)
+	}
+	return exec.Command("sh", "-c", command)
+}
+
+// entryHookEnv tells an entry's hooks which entry they run for
+func entryHookEnv(e entry) []string {
+	return []string{"SYMLINKER_LINK=" + e.link, "SYMLINKER_TARGET=" + e.target}
+}
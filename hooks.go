@@ -0,0 +1,143 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+)
+
+// hookMarker identifies hooks written by symlinker, so they can be replaced and removed
+const hookMarker = "# Installed by symlinker hooks install"
+
+// gitHooks are the hooks that run after git changes the work tree
+var gitHooks = []string{"post-merge", "post-checkout"}
+
+// runHooks installs or removes git hooks that apply a dotfiles repo's config after a
+// pull or checkout
+func runHooks(args []string, configFilePath string) error {
+	flags := flag.NewFlagSet("hooks", flag.ExitOnError)
+	config := flags.String("config", "", "Config the hooks apply (default <repo>/symlinker.conf)")
+	force := flags.Bool("force", false, "Replace existing hooks that symlinker didn't write")
+	flags.BoolVar(dryRun, "dry-run", *dryRun, "Show what would be done without making changes")
+	flags.Usage = func() {
+		fmt.Println("Usage: symlinker hooks install|uninstall [flags] [repo]")
+		fmt.Println("\nInstall post-merge and post-checkout hooks in a dotfiles repo (default $DOTFILES_HOME,")
+		fmt.Println("or the current directory) that apply its config, so a git pull is all it takes to")
+		fmt.Println("bring the links up to date. uninstall removes them again.")
+		fmt.Println("\nFlags:")
+		flags.PrintDefaults()
+	}
+	rest := parseArgs(flags, args)
+	if len(rest) == 0 || (rest[0] != "install" && rest[0] != "uninstall") {
+		flags.Usage()
+		return withExitCode(exitConfig, fmt.Errorf("hooks needs install or uninstall"))
+	}
+
+	repo := os.Getenv("DOTFILES_HOME")
+	if len(rest) > 1 {
+		repo = rest[1]
+	}
+	if repo == "" {
+		repo = "."
+	}
+	repo = absPath(expandTilde(repo))
+	hooksDir, err := gitHooksDir(repo)
+	if err != nil {
+		return err
+	}
+
+	if rest[0] == "uninstall" {
+		return uninstallHooks(hooksDir)
+	}
+	if *config == "" {
+		*config = filepath.Join(repo, "symlinker.conf")
+	}
+	return installHooks(hooksDir, absPath(*config), *force)
+}
+
+// gitHooksDir asks git where a repo's hooks go, which honors core.hooksPath
+func gitHooksDir(repo string) (string, error) {
+	out, err := exec.Command("git", "-C", repo, "rev-parse", "--path-format=absolute", "--git-path", "hooks").Output()
+	if err != nil {
+		return "", withExitCode(exitEnv, fmt.Errorf("%s is not a git repository: %w", repo, err))
+	}
+	return strings.TrimSpace(string(out)), nil
+}
+
+// installHooks writes the hooks applying config, leaving foreign hooks alone unless force is set
+func installHooks(hooksDir, config string, force bool) error {
+	if _, err := os.Stat(config); err != nil {
+		return withExitCode(exitConfig, fmt.Errorf("error: Config file not found: %s", config))
+	}
+	exe, err := os.Executable()
+	if err != nil {
+		return err
+	}
+	if exe, err = filepath.EvalSymlinks(exe); err != nil {
+		return err
+	}
+
+	for _, name := range gitHooks {
+		path := filepath.Join(hooksDir, name)
+		if data, err := os.ReadFile(path); err == nil && !strings.Contains(string(data), hookMarker) && !force {
+			return withExitCode(exitConflict, fmt.Errorf("refusing to replace existing hook %s (use --force)", path))
+		}
+
+		script := "#!/bin/sh\n" + hookMarker + "\n"
+		if name == "post-checkout" {
+			// The third argument is 0 when only files were checked out, not a branch
+			script += "[ \"$3\" = 0 ] && exit 0\n"
+		}
+		script += "exec " + shellQuote(exe) + " -q " + shellQuote(config) + "\n"
+
+		if *dryRun {
+			infof("[DRY RUN] Would write hook: %s\n", path)
+			continue
+		}
+		if err := os.MkdirAll(hooksDir, 0755); err != nil {
+			return fmt.Errorf("error creating hooks directory: %w", err)
+		}
+		if err := os.WriteFile(path, []byte(script), 0755); err != nil {
+			return fmt.Errorf("error writing hook: %w", err)
+		}
+		// WriteFile keeps the mode of a hook that was already there
+		if err := os.Chmod(path, 0755); err != nil {
+			return err
+		}
+		infof("Installed hook: %s\n", path)
+	}
+	return nil
+}
+
+// uninstallHooks removes the hooks symlinker wrote
+func uninstallHooks(hooksDir string) error {
+	removed := 0
+	for _, name := range gitHooks {
+		path := filepath.Join(hooksDir, name)
+		data, err := os.ReadFile(path)
+		if err != nil || !strings.Contains(string(data), hookMarker) {
+			continue
+		}
+		removed++
+		if *dryRun {
+			infof("[DRY RUN] Would remove hook: %s\n", path)
+			continue
+		}
+		if err := os.Remove(path); err != nil {
+			return fmt.Errorf("error removing hook: %w", err)
+		}
+		infof("Removed hook: %s\n", path)
+	}
+	if removed == 0 {
+		infof("No symlinker hooks installed in %s\n", hooksDir)
+	}
+	return nil
+}
+
+// shellQuote quotes s as a single word for sh
+func shellQuote(s string) string {
+	return "'" + strings.ReplaceAll(s, "'", `'\''`) + "'"
+}
@@ -0,0 +1,172 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// runImport scans directories for symlinks into the dotfiles repo and prints config lines for them
+func runImport(args []string, configFilePath string) error {
+	flags := flag.NewFlagSet("import", flag.ExitOnError)
+	prefix := flags.String("prefix", os.Getenv("DOTFILES_HOME"), "Only import symlinks pointing inside this directory")
+	maxDepth := flags.Int("max-depth", 4, "Maximum directory depth to scan (0 for unlimited)")
+	output := flags.String("output", "", "Write config lines to this file instead of stdout")
+	flags.Usage = func() {
+		fmt.Println("Usage: symlinker import [flags] <dir>...")
+		fmt.Println("\nScan directories for existing symlinks into the dotfiles repo and print config lines for them.")
+		fmt.Println("\nFlags:")
+		flags.PrintDefaults()
+	}
+	dirs := parseArgs(flags, args)
+
+	if len(dirs) == 0 {
+		flags.Usage()
+		return fmt.Errorf("import requires at least one directory")
+	}
+	if *prefix == "" {
+		return fmt.Errorf("import requires --prefix (or DOTFILES_HOME to be set)")
+	}
+
+	prefixPath, err := filepath.Abs(expandPath(*prefix))
+	if err != nil {
+		return fmt.Errorf("error resolving prefix %s: %w", *prefix, err)
+	}
+
+	var found []entry
+	for _, dir := range dirs {
+		links, err := findLinksInto(expandPath(dir), prefixPath, *maxDepth)
+		if err != nil {
+			return err
+		}
+		found = append(found, links...)
+	}
+	sort.Slice(found, func(i, j int) bool { return found[i].link < found[j].link })
+
+	header := fmt.Sprintf("Imported from existing symlinks into %s", contractPath(prefixPath))
+	if err := writeConfigLines(*output, header, found); err != nil {
+		return err
+	}
+	fmt.Fprintf(os.Stderr, "Found %d symlinks into %s\n", len(found), prefixPath)
+	return nil
+}
+
+// findLinksInto walks root and returns the symlinks whose targets are inside prefix
+func findLinksInto(root, prefix string, maxDepth int) ([]entry, error) {
+	root, err := filepath.Abs(root)
+	if err != nil {
+		return nil, fmt.Errorf("error resolving %s: %w", root, err)
+	}
+
+	var found []entry
+	err = filepath.WalkDir(root, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			// Unreadable directories are skipped rather than aborting the scan
+			warnf("%s\n", err)
+			if d != nil && d.IsDir() {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+
+		if d.IsDir() {
+			// Don't scan the dotfiles repo itself
+			if path == prefix {
+				return filepath.SkipDir
+			}
+			if maxDepth > 0 && path != root && depth(root, path) >= maxDepth {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+
+		if d.Type()&fs.ModeSymlink == 0 {
+			return nil
+		}
+
+		target, err := os.Readlink(path)
+		if err != nil {
+			warnf("%s\n", err)
+			return nil
+		}
+		if !filepath.IsAbs(target) {
+			target = filepath.Join(filepath.Dir(path), target)
+		}
+		target = filepath.Clean(target)
+
+		if !isWithin(prefix, target) {
+			return nil
+		}
+		if strings.ContainsAny(path+target, "\r\n") {
+			warnf("skipping path containing a line break: %q -> %q\n", path, target)
+			return nil
+		}
+
+		found = append(found, entry{link: path, target: target})
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("error scanning %s: %w", root, err)
+	}
+	return found, nil
+}
+
+// depth returns how many directories path is below root
+func depth(root, path string) int {
+	rel, err := filepath.Rel(root, path)
+	if err != nil || rel == "." {
+		return 0
+	}
+	return strings.Count(rel, string(filepath.Separator)) + 1
+}
+
+// isWithin reports whether path is dir or inside it
+func isWithin(dir, path string) bool {
+	rel, err := filepath.Rel(dir, path)
+	if err != nil {
+		return false
+	}
+	return rel == "." || (rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator)))
+}
+
+// contractPath replaces the longest matching environment variable prefix with its $VAR form
+func contractPath(path string) string {
+	best, bestValue := "", ""
+	for _, name := range commonVars {
+		value := filepath.Clean(os.Getenv(name))
+		if value == "." || value == string(filepath.Separator) || len(value) <= len(bestValue) {
+			continue
+		}
+		if isWithin(value, path) {
+			best, bestValue = name, value
+		}
+	}
+	if best == "" {
+		return path
+	}
+	return "$" + best + strings.TrimPrefix(path, bestValue)
+}
+
+// writeConfigLines writes entries as config lines to a file, or to stdout when path is empty
+func writeConfigLines(path, header string, entries []entry) error {
+	out := io.Writer(os.Stdout)
+	if path != "" {
+		file, err := os.Create(path)
+		if err != nil {
+			return fmt.Errorf("error creating output file: %w", err)
+		}
+		defer file.Close()
+		out = file
+	}
+
+	fmt.Fprintf(out, "# %s\n", header)
+	for _, e := range entries {
+		fmt.Fprintf(out, "%s %s\n", quoteField(contractPath(e.link)), quoteField(contractPath(e.target)))
+	}
+	return nil
+}
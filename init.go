@@ -0,0 +1,110 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// Dotfiles commonly found in $HOME, relative to it
+var commonDotfiles = []string{
+	".zshrc", ".zprofile", ".bashrc", ".bash_profile", ".profile", ".inputrc",
+	".gitconfig", ".gitignore_global", ".vimrc", ".tmux.conf", ".editorconfig",
+	".ssh/config", ".config/nvim", ".config/fish", ".config/git", ".config/alacritty",
+	".config/kitty", ".config/wezterm", ".config/starship.toml", ".config/helix",
+}
+
+// runInit detects existing dotfiles and writes a starter config for them
+func runInit(args []string, configFilePath string) error {
+	flags := flag.NewFlagSet("init", flag.ExitOnError)
+	repo := flags.String("repo", "", "Dotfiles repo directory the config should point at")
+	output := flags.String("output", configFilePath, "Config file to write")
+	yes := flags.Bool("yes", false, "Manage every detected dotfile without asking")
+	force := flags.Bool("force", false, "Overwrite an existing config file")
+	flags.Usage = func() {
+		fmt.Println("Usage: symlinker init [flags]")
+		fmt.Println("\nDetect common dotfiles in $HOME and write a starter config for them.")
+		fmt.Println("\nFlags:")
+		flags.PrintDefaults()
+	}
+	parseArgs(flags, args)
+
+	if _, err := os.Stat(*output); err == nil && !*force {
+		return fmt.Errorf("config file already exists: %s (use --force to overwrite)", *output)
+	}
+
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return fmt.Errorf("error finding home directory: %w", err)
+	}
+
+	// Choose the repo directory
+	repoDir := *repo
+	if repoDir == "" {
+		def := os.Getenv("DOTFILES_HOME")
+		if def == "" {
+			def = filepath.Join(home, "dotfiles")
+		}
+		repoDir = def
+		if !*yes {
+			repoDir = prompt("Dotfiles repo directory", def)
+		}
+	}
+	repoDir, err = filepath.Abs(expandPath(repoDir))
+	if err != nil {
+		return fmt.Errorf("error resolving repo directory: %w", err)
+	}
+
+	// Pick which dotfiles to manage
+	var selected []string
+	for _, name := range detectDotfiles(home) {
+		if *yes || confirm("Manage ~/"+name+"?", true) {
+			selected = append(selected, name)
+		}
+	}
+	if len(selected) == 0 {
+		fmt.Println("No dotfiles selected, nothing to write.")
+		return nil
+	}
+
+	// Write the starter config
+	var b strings.Builder
+	b.WriteString("# Symlinker config generated by `symlinker init`\n")
+	b.WriteString("# Format: <symlink_path> <actual_path>\n")
+	for _, name := range selected {
+		link := filepath.Join(home, name)
+		target := filepath.Join(repoDir, strings.TrimPrefix(filepath.ToSlash(name), "."))
+		fmt.Fprintf(&b, "%s %s\n", contractPath(link), contractPath(target))
+	}
+
+	if err := ensureDirExists(filepath.Dir(*output), defaultOwnership(), false, nil); err != nil {
+		return fmt.Errorf("error creating directory for %s: %w", *output, err)
+	}
+	if err := os.WriteFile(*output, []byte(b.String()), 0644); err != nil {
+		return fmt.Errorf("error writing config file: %w", err)
+	}
+
+	fmt.Printf("Wrote %d entries to %s\n", len(selected), *output)
+	fmt.Println("\nNext, move the files into the repo and link them back:")
+	fmt.Printf("  symlinker adopt --config %s", *output)
+	for _, name := range selected {
+		fmt.Printf(" ~/%s", name)
+	}
+	fmt.Println()
+	return nil
+}
+
+// detectDotfiles returns the common dotfiles present in home that are not already symlinks
+func detectDotfiles(home string) []string {
+	var found []string
+	for _, name := range commonDotfiles {
+		info, err := os.Lstat(filepath.Join(home, name))
+		if err != nil || info.Mode()&os.ModeSymlink != 0 {
+			continue
+		}
+		found = append(found, name)
+	}
+	return found
+}
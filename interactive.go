@@ -0,0 +1,60 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/frizadiga/symlinker/pkg/symlinker"
+)
+
+// confirmer asks before each action in interactive mode, remembering "all" and "quit" answers
+type confirmer struct {
+	all  bool
+	quit bool
+}
+
+// approve asks whether to carry out an action: y(es), N(o), a(ll) or q(uit)
+func (c *confirmer) approve(action string) bool {
+	if c.all {
+		return true
+	}
+	for {
+		answer, err := ask(action + "? [y/N/a/q]")
+		if err != nil {
+			c.quit = true
+			return false
+		}
+		switch strings.ToLower(answer) {
+		case "y", "yes":
+			return true
+		case "", "n", "no":
+			return false
+		case "a", "all":
+			c.all = true
+			return true
+		case "q", "quit":
+			c.quit = true
+			return false
+		}
+	}
+}
+
+// pendingAction describes what applying an entry would do, or "" if it is already in place
+func pendingAction(e entry, target, mode string, st *state) string {
+	existing, err := inspectLink(e.link, target)
+	if err != nil || existing.Kind == symlinker.ActionCreate {
+		return fmt.Sprintf("Create %s -> %s", e.link, target)
+	}
+	if isOwnLink(e.link, target, mode, st) {
+		return ""
+	}
+
+	switch {
+	case existing.Kind == symlinker.ActionUnchanged:
+		// A symlink to the actual path, where a copy or another kind of link goes
+		return fmt.Sprintf("Replace existing symlink %s with -> %s", e.link, target)
+	case existing.Existing == "symlink":
+		return fmt.Sprintf("Relink %s from %s to %s", e.link, existing.Previous, target)
+	}
+	return fmt.Sprintf("Replace existing %s %s with -> %s", existing.Existing, e.link, target)
+}
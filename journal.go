@@ -0,0 +1,182 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+// historyLimit is how many run journals are kept
+const historyLimit = 50
+
+// Journal operation types
+const (
+	opMkdir   = "mkdir"   // created a directory
+	opCreate  = "create"  // created a symlink where nothing existed
+	opReplace = "replace" // removed something and put a symlink in its place
+	opMove    = "move"    // moved a file or directory (adopt)
+	opRemove  = "remove"  // removed a symlink (uninstall)
+	opTouch   = "touch"   // created an empty actual file
+)
+
+// journalOp is a single filesystem change made during a run
+type journalOp struct {
+	Op       string       `json:"op"`
+	Path     string       `json:"path"`
+	Target   string       `json:"target,omitempty"`   // symlink target created, or move destination
+	Mode     string       `json:"mode,omitempty"`     // link mode of the created or removed link; empty means symlink
+	Kind     string       `json:"kind,omitempty"`     // what was replaced: symlink, file or dir
+	Previous string       `json:"previous,omitempty"` // target of the replaced or removed symlink
+	Backup   string       `json:"backup,omitempty"`   // where the replaced original was moved to
+	Trashed  string       `json:"trashed,omitempty"`  // where in the trash the replaced original went
+	Hash     string       `json:"hash,omitempty"`     // content digest of a created copy
+	Source   string       `json:"source,omitempty"`   // content digest of the actual path a file was decrypted from
+	Managed  *managedLink `json:"managed,omitempty"`  // state record for the path before the change
+}
+
+// journal is the record of every change made by one run, used by undo
+type journal struct {
+	ID      string      `json:"id"`
+	Command string      `json:"command"`
+	Started time.Time   `json:"started"`
+	Ops     []journalOp `json:"ops"`
+	Undone  bool        `json:"undone,omitempty"`
+
+	path string
+	st   *state
+}
+
+// historyDir returns the directory run journals are kept in
+func historyDir() (string, error) {
+	dir, err := stateDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, "history"), nil
+}
+
+// newJournal starts a journal for a run; st is consulted to remember prior state records
+func newJournal(command string, st *state) *journal {
+	now := time.Now()
+	return &journal{ID: now.Format("20060102T150405.000000000"), Command: command, Started: now, st: st}
+}
+
+// add records an operation. It is safe to call on a nil journal.
+func (j *journal) add(op journalOp) {
+	if j == nil {
+		return
+	}
+	op.Path = absPath(op.Path)
+	if j.st != nil {
+		if l, ok := j.st.lookup(op.Path); ok {
+			op.Managed = &l
+		}
+	}
+	j.Ops = append(j.Ops, op)
+}
+
+// save writes the journal if anything was recorded, pruning old history
+func (j *journal) save() error {
+	if j == nil || len(j.Ops) == 0 {
+		return nil
+	}
+
+	dir, err := historyDir()
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return fmt.Errorf("error creating history directory: %w", err)
+	}
+	if j.path == "" {
+		j.path = filepath.Join(dir, j.ID+".json")
+	}
+
+	data, err := json.MarshalIndent(j, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.WriteFile(j.path, append(data, '\n'), 0644); err != nil {
+		return fmt.Errorf("error writing run journal: %w", err)
+	}
+	return pruneHistory(dir)
+}
+
+// loadHistory returns all run journals, newest first
+func loadHistory() ([]*journal, error) {
+	dir, err := historyDir()
+	if err != nil {
+		return nil, err
+	}
+
+	files, err := filepath.Glob(filepath.Join(dir, "*.json"))
+	if err != nil {
+		return nil, err
+	}
+	sort.Sort(sort.Reverse(sort.StringSlice(files)))
+
+	var journals []*journal
+	for _, file := range files {
+		data, err := os.ReadFile(file)
+		if err != nil {
+			return nil, fmt.Errorf("error reading run journal: %w", err)
+		}
+		j := &journal{path: file}
+		if err := json.Unmarshal(data, j); err != nil {
+			return nil, fmt.Errorf("error parsing run journal %s: %w", file, err)
+		}
+		journals = append(journals, j)
+	}
+	return journals, nil
+}
+
+// pruneHistory removes the oldest journals beyond historyLimit
+func pruneHistory(dir string) error {
+	files, err := filepath.Glob(filepath.Join(dir, "*.json"))
+	if err != nil || len(files) <= historyLimit {
+		return err
+	}
+	sort.Strings(files)
+	for _, file := range files[:len(files)-historyLimit] {
+		os.Remove(file)
+	}
+	return nil
+}
+
+// fileKind describes what kind of filesystem object info is
+func fileKind(info os.FileInfo) string {
+	switch {
+	case info.Mode()&os.ModeSymlink != 0:
+		return "symlink"
+	case info.IsDir():
+		return "dir"
+	default:
+		return "file"
+	}
+}
+
+// describe returns a one-line human description of an operation
+func (op journalOp) describe() string {
+	switch op.Op {
+	case opMkdir:
+		return fmt.Sprintf("created directory %s", op.Path)
+	case opTouch:
+		return fmt.Sprintf("created empty file %s", op.Path)
+	case opCreate:
+		return fmt.Sprintf("created %s -> %s", op.Path, op.Target)
+	case opReplace:
+		if op.Kind == "symlink" {
+			return fmt.Sprintf("replaced %s (was -> %s) with -> %s", op.Path, op.Previous, op.Target)
+		}
+		return fmt.Sprintf("replaced %s %s with -> %s", op.Kind, op.Path, op.Target)
+	case opMove:
+		return fmt.Sprintf("moved %s to %s", op.Path, op.Target)
+	case opRemove:
+		return fmt.Sprintf("removed %s (was -> %s)", op.Path, op.Previous)
+	}
+	return strings.TrimSpace(op.Op + " " + op.Path)
+}
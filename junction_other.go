@@ -0,0 +1,10 @@
+//go:build !windows
+
+package main
+
+import "errors"
+
+// createJunction is only possible on Windows
+func createJunction(target, path string) error {
+	return errors.New("directory junctions are only available on Windows")
+}
@@ -0,0 +1,21 @@
+package main
+
+import (
+	"fmt"
+	"os/exec"
+	"path/filepath"
+)
+
+// createJunction makes path a directory junction to target, which needs no privilege
+func createJunction(target, path string) error {
+	// Junctions can only hold absolute paths
+	target, err := filepath.Abs(target)
+	if err != nil {
+		return err
+	}
+	out, err := exec.Command("cmd", "/c", "mklink", "/J", path, target).CombinedOutput()
+	if err != nil {
+		return fmt.Errorf("error creating junction: %s", out)
+	}
+	return nil
+}
@@ -0,0 +1,265 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"syscall"
+
+	"github.com/frizadiga/symlinker/pkg/symlinker"
+)
+
+// Link modes: how an entry's link path is tied to its actual path
+const (
+	modeSymlink  = "symlink"
+	modeHardlink = "hardlink"
+	modeCopy     = "copy"
+	modeJunction = "junction" // Windows directory junction
+	modeDecrypt  = "decrypt"  // the actual path decrypted with age or GPG
+	modeTemplate = "template" // the actual path rendered as a Go template
+)
+
+// validateLinkMode checks a mode= value
+func validateLinkMode(mode string) error {
+	switch mode {
+	case modeSymlink, modeHardlink, modeCopy, modeJunction, modeDecrypt, modeTemplate:
+		return nil
+	}
+	if mode != "" && !strings.ContainsAny(mode, `/\`) {
+		if _, err := exec.LookPath(pluginPrefix + mode); err == nil {
+			return nil
+		}
+	}
+	return fmt.Errorf("invalid link mode %q (want symlink, hardlink, copy, junction, decrypt or template, or a plugin %s%s on PATH)", mode, pluginPrefix, mode)
+}
+
+// writesFile reports whether a mode writes a file of its own at the link path, which is
+// told apart from an edited one by its content digest
+func writesFile(mode string) bool {
+	return mode == modeCopy || mode == modeDecrypt || mode == modeTemplate
+}
+
+// linkNoun names the kind of link a mode creates, for messages
+func linkNoun(mode string) string {
+	switch mode {
+	case modeHardlink:
+		return "hard link"
+	case modeCopy:
+		return "copy"
+	case modeJunction:
+		return "junction"
+	case modeDecrypt:
+		return "decrypted file"
+	case modeTemplate:
+		return "rendered template"
+	}
+	if isPluginMode(mode) {
+		return mode + " entry"
+	}
+	return "symlink"
+}
+
+// isOwnLink reports whether path is already a link of the given mode to target.
+// Decrypted files are told from the digests recorded in st when they were written.
+func isOwnLink(path, target, mode string, st *state) bool {
+	switch mode {
+	case modeHardlink, modeJunction:
+		linkInfo, err := os.Lstat(path)
+		if err != nil {
+			return false
+		}
+		targetInfo, err := os.Stat(target)
+		return err == nil && os.SameFile(linkInfo, targetInfo)
+	case modeCopy:
+		have, err := copyHash(path)
+		if err != nil {
+			return false
+		}
+		want, err := targetHash(target)
+		return err == nil && have == want
+	case modeDecrypt:
+		if st == nil {
+			return false
+		}
+		l, ok := st.lookup(path)
+		if !ok || l.Mode != modeDecrypt || !isManaged(path, l) {
+			return false
+		}
+		source, err := targetHash(target)
+		return err == nil && source == l.Source
+	case modeTemplate:
+		have, err := copyHash(path)
+		if err != nil {
+			return false
+		}
+		want, err := renderedHash(target)
+		return err == nil && have == want
+	}
+	existing, err := inspectLink(path, target)
+	return err == nil && existing.Kind == symlinker.ActionUnchanged
+}
+
+// linkFS is the filesystem link paths are inspected and plain symlinks made in, through
+// the library's Plan and Apply, as in a program embedding pkg/symlinker
+var linkFS symlinker.FS = symlinker.OSFS{}
+
+// inspectLink plans linking path to target with the library, which says what is at
+// path now: nothing (ActionCreate), a symlink to target already (ActionUnchanged), or
+// what would be replaced
+func inspectLink(path, target string) (symlinker.Action, error) {
+	actions, err := symlinker.Plan(linkFS, []symlinker.Entry{{Link: path, Target: target}}, symlinker.Options{})
+	if err != nil {
+		return symlinker.Action{}, err
+	}
+	return actions[0], nil
+}
+
+// isManaged reports whether path still holds what symlinker put there for l. Copies,
+// decrypted files and rendered templates are compared with the digest taken when they were made, so a changed
+// actual path doesn't make an earlier copy look foreign.
+func isManaged(path string, l managedLink) bool {
+	if writesFile(l.Mode) {
+		hash, err := copyHash(path)
+		return err == nil && l.Hash != "" && hash == l.Hash
+	}
+	return isOwnLink(path, l.Target, l.Mode, nil)
+}
+
+// wasEdited reports whether path is a file symlinker wrote, such as a copy or a
+// rendered template, whose content changed there since
+func wasEdited(path string, st *state) bool {
+	if st == nil {
+		return false
+	}
+	l, ok := st.lookup(path)
+	if !ok || !writesFile(l.Mode) || l.Hash == "" {
+		return false
+	}
+	hash, err := copyHash(path)
+	return err == nil && hash != l.Hash
+}
+
+// copyHash returns the content digest of a copy at path, which must not be a symlink
+func copyHash(path string) (string, error) {
+	info, err := os.Lstat(path)
+	if err != nil {
+		return "", err
+	}
+	if info.Mode()&os.ModeSymlink != 0 {
+		return "", fmt.Errorf("%s is a symlink", path)
+	}
+	return contentHash(path)
+}
+
+// targetHash returns the content digest of an actual path, following symlinks to it
+func targetHash(target string) (string, error) {
+	src, err := filepath.EvalSymlinks(target)
+	if err != nil {
+		return "", err
+	}
+	return contentHash(src)
+}
+
+// makeLink creates a link of the given mode at path pointing at target
+func makeLink(target, path, mode string) error {
+	switch mode {
+	case modeHardlink:
+		err := os.Link(target, path)
+		if errors.Is(err, syscall.EXDEV) {
+			return fmt.Errorf("cannot hard link %s to %s: they are on different filesystems", path, target)
+		}
+		return err
+	case modeCopy:
+		src, err := filepath.EvalSymlinks(target)
+		if err != nil {
+			return err
+		}
+		if err := copyPath(src, path); err != nil {
+			os.RemoveAll(path)
+			return err
+		}
+		return nil
+	case modeJunction:
+		return createJunction(target, path)
+	case modeDecrypt:
+		return decryptFile(target, path)
+	case modeTemplate:
+		return renderTemplateFile(target, path)
+	}
+	// The library's message names the entry's line, which callers add themselves
+	create := symlinker.Action{Entry: symlinker.Entry{Link: path, Target: target}, Kind: symlinker.ActionCreate}
+	if err := symlinker.Apply(linkFS, []symlinker.Action{create}, symlinker.Options{}); err != nil {
+		return errors.Unwrap(err)
+	}
+	return nil
+}
+
+// removeLink removes a link of the given mode; copies of directories are removed whole
+func removeLink(path, mode string) error {
+	if mode == modeCopy {
+		return auditRemove(path, os.RemoveAll)
+	}
+	return auditRemove(path, os.Remove)
+}
+
+// checkLink reports why a link of the given mode can't be made from linkDir to target,
+// before anything in the way is removed
+func checkLink(target, linkDir, mode string) error {
+	switch mode {
+	case modeHardlink:
+		return checkHardlink(target, linkDir)
+	case modeCopy:
+		if _, err := os.Stat(target); err != nil {
+			return fmt.Errorf("cannot copy %s: %w", target, err)
+		}
+	case modeJunction:
+		if info, err := os.Stat(target); err != nil || !info.IsDir() {
+			return fmt.Errorf("cannot create a junction to %s: only directories can be junction targets", target)
+		}
+	case modeDecrypt:
+		return checkDecrypt(target)
+	case modeTemplate:
+		if _, err := renderTemplate(target); err != nil {
+			return fmt.Errorf("cannot render %s: %w", target, err)
+		}
+	}
+	return nil
+}
+
+// fallbackMode picks how to link an entry whose link directory doesn't support symlinks:
+// a copy with --copy-fallback, and on Windows, where creating symlinks needs a privilege
+// or Developer Mode, a junction for directories or a copy for files. It returns "" if
+// there is no fallback.
+func fallbackMode(target string, copyFallback bool) string {
+	if runtime.GOOS == "windows" {
+		if info, err := os.Stat(target); err == nil && info.IsDir() {
+			return modeJunction
+		}
+		return modeCopy
+	}
+	if copyFallback {
+		return modeCopy
+	}
+	return ""
+}
+
+// checkHardlink reports why target can't be hard linked from a link in linkDir, before
+// anything in the way is removed
+func checkHardlink(target, linkDir string) error {
+	info, err := os.Stat(target)
+	if err != nil {
+		return fmt.Errorf("cannot hard link to %s: %w", target, err)
+	}
+	if !info.Mode().IsRegular() {
+		return fmt.Errorf("cannot hard link to %s: only regular files can be hard linked", target)
+	}
+	same, err := sameDevice(target, existingAncestor(filepath.Clean(linkDir)))
+	if err == nil && !same {
+		return fmt.Errorf("cannot hard link to %s from %s: they are on different filesystems (use mode=symlink)", target, linkDir)
+	}
+	return nil
+}
@@ -0,0 +1,238 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+)
+
+// runLint checks configs for mistakes without touching the filesystem
+func runLint(args []string, configFilePath string) error {
+	flags := flag.NewFlagSet("lint", flag.ExitOnError)
+	repo := flags.String("repo", os.Getenv("DOTFILES_HOME"), "Dotfiles repo actual paths are expected in (default $DOTFILES_HOME)")
+	staged := flags.String("staged-against", "", "Also fail on actual paths the commit staged in git repo `DIR` deletes or renames, as a pre-commit hook")
+	flags.BoolVar(strict, "strict", *strict, "Fail on warnings too")
+	flags.Usage = func() {
+		fmt.Println("Usage: symlinker lint [flags] [config-file...]")
+		fmt.Println("\nCheck configs for syntax errors, unset variables, duplicate link paths, missing or")
+		fmt.Println("suspicious targets and misspelled options. Nothing is created or changed.")
+		fmt.Println("\nFlags:")
+		flags.PrintDefaults()
+	}
+	configs := parseArgs(flags, args)
+	if len(configs) == 0 {
+		configs = []string{configFilePath}
+	}
+
+	var changes *stagedChanges
+	if *staged != "" {
+		var err error
+		if changes, err = readStagedChanges(*staged); err != nil {
+			return err
+		}
+		// A hook doesn't see the login shell's setup; configs point into the repo through $DOTFILES_HOME
+		if os.Getenv("DOTFILES_HOME") == "" {
+			os.Setenv("DOTFILES_HOME", changes.top)
+		}
+		if *repo == "" {
+			*repo = changes.top
+		}
+	}
+
+	errorCount, warnCount := 0, 0
+	for _, config := range configs {
+		fmt.Printf("%s:\n", config)
+		errs, warns := printFindings(lint(config, *repo, changes))
+		errorCount += errs
+		warnCount += warns
+	}
+
+	fmt.Printf("\n%d errors, %d warnings\n", errorCount, warnCount)
+	if errorCount > 0 || (*strict && warnCount > 0) {
+		return fmt.Errorf("lint found %d errors and %d warnings", errorCount, warnCount)
+	}
+	return nil
+}
+
+// lint runs the read-only checks against one config file, and with changes, checks
+// the entries against the commit staged in a repo
+func lint(configFilePath, repo string, changes *stagedChanges) []finding {
+	entries, warnings, err := readConfig(configFilePath)
+	if err != nil {
+		return []finding{{levelError, err.Error(), ""}}
+	}
+
+	var findings []finding
+	for _, w := range warnings {
+		findings = append(findings, finding{levelWarn, w, ""})
+	}
+	findings = append(findings, checkVariables(entries)...)
+	findings = append(findings, checkConflicts(entries)...)
+	findings = append(findings, checkCycles(entries)...)
+	findings = append(findings, checkTargets(entries)...)
+	findings = append(findings, checkTargetLocations(entries, repo)...)
+	if changes != nil {
+		findings = append(findings, checkStaged(entries, changes)...)
+	}
+
+	// Only problems are worth a line in a pre-commit hook
+	var problems []finding
+	for _, f := range findings {
+		if f.level != levelOK {
+			problems = append(problems, f)
+		}
+	}
+	return problems
+}
+
+// checkTargetLocations reports actual paths that are relative, or absolute but outside the repo
+func checkTargetLocations(entries []entry, repo string) []finding {
+	if repo != "" {
+		repo = absPath(expandTilde(repo))
+	}
+
+	var findings []finding
+	for _, e := range entries {
+		// A plugin entry's actual path means what its plugin makes of it
+		if isPluginMode(e.options["mode"]) {
+			continue
+		}
+		switch {
+		case !filepath.IsAbs(e.target):
+			findings = append(findings, finding{
+				levelWarn,
+				fmt.Sprintf("Line %d: actual path is relative and depends on the working directory: %s", e.line, e.rawTarget),
+				"start it with a variable such as $DOTFILES_HOME",
+			})
+		case repo != "" && !isWithin(repo, e.target):
+			findings = append(findings, finding{
+				levelWarn,
+				fmt.Sprintf("Line %d: actual path is outside the repo %s: %s", e.line, repo, e.target),
+				"keep targets in the dotfiles repo, or pass --repo",
+			})
+		}
+	}
+	return findings
+}
+
+// stagedChanges is what the commit staged in a git repo would leave of the files in HEAD
+type stagedChanges struct {
+	top     string            // the repo's top directory
+	head    map[string]bool   // files in HEAD and the directories holding them, relative to top
+	index   map[string]bool   // the same for the index, as the commit would leave them
+	renamed map[string]string // staged renames, from the old path to the new, with forward slashes
+}
+
+// readStagedChanges asks git which files in repo the staged commit keeps, removes and renames
+func readStagedChanges(repo string) (*stagedChanges, error) {
+	out, err := exec.Command("git", "-C", repo, "rev-parse", "--show-toplevel").Output()
+	if err != nil {
+		return nil, withExitCode(exitEnv, fmt.Errorf("%s is not a git repository: %w", repo, err))
+	}
+	c := &stagedChanges{top: strings.TrimSpace(string(out)), head: map[string]bool{}, index: map[string]bool{}, renamed: map[string]string{}}
+
+	// A repo with no commits yet has nothing a commit could remove
+	if head, err := gitLines(c.top, "ls-tree", "-r", "-z", "--name-only", "HEAD"); err == nil {
+		addTracked(c.head, head)
+	}
+	index, err := gitLines(c.top, "ls-files", "-z")
+	if err != nil {
+		return nil, err
+	}
+	addTracked(c.index, index)
+
+	diff, err := gitLines(c.top, "diff", "--cached", "--name-status", "-M", "-z")
+	if err != nil {
+		return nil, err
+	}
+	for i := 0; i < len(diff); i++ {
+		if strings.HasPrefix(diff[i], "R") && i+2 < len(diff) {
+			c.renamed[diff[i+1]] = diff[i+2]
+			i += 2
+		} else if strings.HasPrefix(diff[i], "C") {
+			i += 2
+		} else {
+			i++
+		}
+	}
+	return c, nil
+}
+
+// gitLines runs a git command in dir and splits its NUL-separated output
+func gitLines(dir string, args ...string) ([]string, error) {
+	out, err := exec.Command("git", append([]string{"-C", dir}, args...)...).Output()
+	if err != nil {
+		return nil, withExitCode(exitEnv, fmt.Errorf("error running git %s: %w", strings.Join(args, " "), err))
+	}
+	return strings.FieldsFunc(string(out), func(r rune) bool { return r == 0 }), nil
+}
+
+// addTracked adds files, and every directory holding them, to set
+func addTracked(set map[string]bool, files []string) {
+	for _, f := range files {
+		for p := f; p != "."; p = filepath.Dir(p) {
+			set[filepath.FromSlash(p)] = true
+		}
+	}
+}
+
+// removal reports whether the commit removes a path tracked in HEAD, and where it moves
+// it to if it is a rename
+func (c *stagedChanges) removal(rel string) (removed bool, to string) {
+	if !c.head[rel] || c.index[rel] {
+		return false, ""
+	}
+	slash := filepath.ToSlash(rel)
+	if to, ok := c.renamed[slash]; ok {
+		return true, filepath.FromSlash(to)
+	}
+	// A directory is renamed when the files that were in it were
+	for from, to := range c.renamed {
+		if rest, ok := strings.CutPrefix(from, slash+"/"); ok {
+			if dir, ok := strings.CutSuffix(to, "/"+rest); ok {
+				return true, filepath.FromSlash(dir)
+			}
+		}
+	}
+	return true, ""
+}
+
+// checkStaged reports entries whose actual paths the staged commit deletes or renames,
+// which would break their links on every machine that pulls it
+func checkStaged(entries []entry, c *stagedChanges) []finding {
+	var findings []finding
+	for _, e := range entries {
+		if isPluginMode(e.options["mode"]) {
+			continue
+		}
+		// Paths are compared with symlinks resolved, as git reports the top directory
+		target := absPath(e.target)
+		if dir, err := filepath.EvalSymlinks(existingAncestor(target)); err == nil {
+			if rest, err := filepath.Rel(existingAncestor(target), target); err == nil {
+				target = filepath.Join(dir, rest)
+			}
+		}
+		rel, err := filepath.Rel(c.top, target)
+		if err != nil || rel == "." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) || rel == ".." {
+			continue
+		}
+		switch removed, to := c.removal(rel); {
+		case removed && to != "":
+			findings = append(findings, finding{
+				levelError,
+				fmt.Sprintf("Line %d: the staged commit renames the actual path %s to %s", e.line, rel, to),
+				"point the entry at the new path before committing",
+			})
+		case removed:
+			findings = append(findings, finding{
+				levelError,
+				fmt.Sprintf("Line %d: the staged commit deletes the actual path %s", e.line, rel),
+				"restore it, or update or remove the entry",
+			})
+		}
+	}
+	return findings
+}
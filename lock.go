@@ -0,0 +1,42 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// acquireLock takes the advisory lock that serializes runs which change the filesystem.
+// If another run holds it, this waits for it unless --no-wait was given.
+func acquireLock() (func(), error) {
+	dir, err := stateDir()
+	if err != nil {
+		return nil, err
+	}
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, fmt.Errorf("error creating state directory: %w", err)
+	}
+
+	unlock, err := lockFile(filepath.Join(dir, "lock"), !*noWait)
+	if err != nil {
+		return nil, fmt.Errorf("error acquiring lock: %w", err)
+	}
+	return unlock, nil
+}
+
+// errLocked builds the error reported when another run holds the lock
+func errLocked(path string) error {
+	data, _ := os.ReadFile(path)
+	if pid := strings.TrimSpace(string(data)); pid != "" {
+		return withExitCode(exitLocked, fmt.Errorf("another symlinker run is in progress (pid %s)", pid))
+	}
+	return withExitCode(exitLocked, fmt.Errorf("another symlinker run is in progress"))
+}
+
+// writePID records the current process in the lock file for diagnostics
+func writePID(file *os.File) {
+	file.Truncate(0)
+	file.WriteAt([]byte(strconv.Itoa(os.Getpid())+"\n"), 0)
+}
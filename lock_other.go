@@ -0,0 +1,56 @@
+//go:build !(darwin || dragonfly || freebsd || linux || netbsd || openbsd)
+
+package main
+
+import (
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// lockFile takes the lock by exclusively creating path, blocking until it is free if wait is set.
+// A lock left behind by a process that no longer exists is taken over.
+func lockFile(path string, wait bool) (func(), error) {
+	announced := false
+	for {
+		file, err := os.OpenFile(path, os.O_RDWR|os.O_CREATE|os.O_EXCL, 0644)
+		if err == nil {
+			writePID(file)
+			return func() {
+				file.Close()
+				os.Remove(path)
+			}, nil
+		}
+		if !os.IsExist(err) {
+			return nil, err
+		}
+
+		if staleLock(path) {
+			os.Remove(path)
+			continue
+		}
+		if !wait {
+			return nil, errLocked(path)
+		}
+		if !announced {
+			infof("Waiting for another symlinker run to finish...\n")
+			announced = true
+		}
+		time.Sleep(100 * time.Millisecond)
+	}
+}
+
+// staleLock reports whether the process recorded in a lock file has exited
+func staleLock(path string) bool {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return false
+	}
+	pid, err := strconv.Atoi(strings.TrimSpace(string(data)))
+	if err != nil {
+		return false
+	}
+	_, err = os.FindProcess(pid)
+	return err != nil
+}
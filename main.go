@@ -1,239 +1,329 @@
 package main
 
 import (
-	"bufio"
 	"flag"
 	"fmt"
 	"os"
-	"path/filepath"
 	"regexp"
+	"runtime"
 	"strings"
 )
 
 // Command line flags
 var (
-	dryRun = flag.Bool("dry-run", false, "Show what would be done without making changes")
-	help   = flag.Bool("help", false, "Show help message")
+	dryRun      = flag.Bool("dry-run", false, "Show what would be done without making changes")
+	help        = flag.Bool("help", false, "Show help message")
+	showVersion = flag.Bool("version", false, "Print the version, commit and build date and exit")
+	noWait      = flag.Bool("no-wait", false, "Fail immediately if another symlinker run is in progress")
+	trash       = flag.Bool("trash", false, "Move existing files and directories to the trash instead of deleting them")
+	force       = flag.Bool("force", false, "Delete non-empty directories and large files that are in the way")
+	sudo        = flag.Bool("sudo", false, "Re-run entries that fail with permission denied as root via sudo, without asking")
+
+	percentVars     = flag.Bool("percent-vars", runtime.GOOS == "windows", "Also expand Windows-style %VAR% references in paths (default on Windows)")
+	rootDir         = flag.String("root", "", "Place link paths under `DIR`, DESTDIR-style, to populate a chroot or image without touching the host")
+	rootTargets     = flag.Bool("root-targets", false, "With --root, place actual paths under it too")
+	continueOnError = flag.Bool("continue-on-error", false, "Keep applying the remaining entries when one fails, and list the failures at the end")
+	strict          = flag.Bool("strict", false, "Refuse to apply a config with duplicate or nested link paths instead of warning")
+	requireTarget   = flag.Bool("require-target", false, "Fail instead of warning when an actual path doesn't exist")
+	relative        = flag.Bool("relative", false, "Create links with targets relative to the link's directory")
+	copyFallback    = flag.Bool("copy-fallback", false, "Copy actual paths into place where the filesystem doesn't support symlinks")
+	resolveTargets  = flag.Bool("resolve-targets", false, "Link to the real file at the end of any symlinks along an actual path, not to the first of them")
+	createTargets   = flag.Bool("create-missing-targets", false, "Create an empty file (or directory, for targets ending in /) when an actual path doesn't exist")
+
+	onConflict    = flag.String("on-conflict", conflictOverwrite, "What to do when something already exists at a link path: overwrite, skip, backup or ask")
+	outputFormat  = flag.String("output", outputText, "Report format: text, json for a list of actions on stdout, or porcelain (messages go to stderr)")
+	colorSetting  = flag.String("color", colorAuto, "Colorize output: auto (on a terminal, unless NO_COLOR is set), always or never")
+	logFile       = flag.String("log-file", "", "Also record every message, with its level and time, to `FILE` (appended to)")
+	logFormat     = flag.String("log-format", logFormatText, "Format of the --log-file records: text or json")
+	porcelain     = flag.Bool("porcelain", false, "Shorthand for --output=porcelain: one stable tab-separated line per entry, for scripts")
+	configSHA256  = flag.String("sha256", "", "Refuse to apply the config unless its SHA-256 digest is `HEX`")
+	signingKey    = flag.String("signing-key", "", "Refuse to apply the config unless it has a good SSH signature by `KEY` (a .pub file or the key itself)")
+	signatureFile = flag.String("signature", "", "The config's SSH signature `FILE` or URL (default the config's path or URL with .sig added)")
+	noProgress    = flag.Bool("no-progress", false, "Don't show a progress counter on the terminal during long runs")
+	ageIdentity   = flag.String("age-identity", "~/.config/age/keys.txt", "Identity `FILE` age decrypts mode=decrypt entries with")
+	allowExec     = flag.Bool("allow-exec", false, "Run the commands in $(command) substitutions in config paths")
+	noExpand      = flag.Bool("no-expand", false, "Take config paths as written, without expanding variables or command substitutions, for configs of paths expanded already")
+	stateDirFlag  = flag.String("state-dir", "", "Keep the state, run history and lock in `DIR` instead of $XDG_STATE_HOME/symlinker")
+	preCommand    = flag.String("pre", "", "Run `COMMAND` through the shell before applying the config")
+	postCommand   = flag.String("post", "", "Run `COMMAND` through the shell after applying the config")
+	notifyWhen    = flag.String("notify", notifyNever, "Send a desktop notification when a run ends: never, changes (when something changed or failed), failures or always")
+	reportURL     = flag.String("report-url", "", "POST a JSON report of each apply or status run to `URL`")
+	metricsFile   = flag.String("metrics-file", "", "Write Prometheus metrics of each apply or status run to `FILE`, for node_exporter's textfile collector")
+	changedExit   = flag.Bool("changed-exit-code", false, "Exit 6 when the run changed something, or a dry run would, and 0 when everything was in place")
+	userFlag      = flag.String("user", "", "Apply for the user `NAME` as root: expand $HOME and ~ against their home, keep state there and give them the links and directories created")
+	configFlag    = flag.String("config", "", "Use `FILE` as the config for commands not given one, instead of looking for $SYMLINKER_CONFIG and the default paths")
+	backup        backupFlag
+
+	interactive bool
+	dirMode     dirModeFlag
 )
 
-// ensureDirExists creates a directory if it doesn't exist
-func ensureDirExists(path string, dryRun bool) error {
-	if _, err := os.Stat(path); os.IsNotExist(err) {
-		if dryRun {
-			fmt.Printf("[DRY RUN] Would create directory: %s\n", path)
-			return nil
-		}
-		fmt.Printf("Creating directory: %s\n", path)
-		return os.MkdirAll(path, 0755)
-	}
-	return nil
+func init() {
+	flag.Var(&backup, "backup", "Move existing files and directories to `DIR` instead of deleting them (default ~/.local/share/symlinker/backups)")
+	flag.BoolVar(&interactive, "interactive", false, "Ask before each change: y(es), N(o), a(ll remaining) or q(uit)")
+	flag.BoolVar(&interactive, "i", false, "Shorthand for --interactive")
+	flag.BoolFunc("q", "Quiet: print only errors and a final summary", func(string) error {
+		verbosity = levelQuiet
+		return nil
+	})
+	flag.BoolFunc("v", "Verbose: also show unchanged and skipped entries and how paths were expanded (repeat for more)", func(string) error {
+		verbosity = max(verbosity, levelNormal) + 1
+		return nil
+	})
+	flag.BoolFunc("vv", "Debug: also show how existing links compare with the config", func(string) error {
+		verbosity = levelDebug
+		return nil
+	})
+	flag.Var(&dirMode, "dir-mode", "Octal permissions for directories symlinker creates, such as 0700 (default 0755, limited by the parent directory and umask)")
 }
 
-// expandPath expands ALL environment variables in a string
-func expandPath(path string) string {
-	// Use os.ExpandEnv to expand all environment variables
-	// This handles $VAR and ${VAR} syntax automatically
-	return os.ExpandEnv(path)
+// Subcommands, keyed by name. Each receives its own arguments and the default config path.
+var commands = map[string]func(args []string, configFilePath string) error{
+	"adopt":          runAdopt,
+	"bootstrap":      runBootstrap,
+	"clean":          runClean,
+	"daemon":         runDaemon,
+	"doctor":         runDoctor,
+	"env":            runEnv,
+	"explain":        runExplain,
+	"export":         runExport,
+	"fmt":            runFmt,
+	"hooks":          runHooks,
+	"import":         runImport,
+	"import-chezmoi": runImportChezmoi,
+	"import-stow":    runImportStow,
+	"init":           runInit,
+	"lint":           runLint,
+	"restore":        runRestore,
+	"serve":          runServe,
+	"service":        runService,
+	"status":         runStatus,
+	"tui":            runTUI,
+	"undo":           runUndo,
+	"uninstall":      runUninstall,
+	"watch":          runWatch,
 }
 
-// createSymlink creates a symbolic link
-func createSymlink(targetPath, symlinkPath string, dryRun bool) error {
-	// Check if existing symlink or file exists
-	if _, err := os.Lstat(symlinkPath); err == nil {
-		if dryRun {
-			fmt.Printf("[DRY RUN] Would remove existing: %s\n", symlinkPath)
-		} else {
-			fmt.Printf("Removing existing: %s\n", symlinkPath)
-			if err := os.RemoveAll(symlinkPath); err != nil {
-				return fmt.Errorf("error removing existing path: %w", err)
-			}
-		}
-	}
-
-	// Create the symlink
-	if dryRun {
-		fmt.Printf("[DRY RUN] Would create symlink: %s -> %s\n", symlinkPath, targetPath)
-		return nil
-	}
-
-	fmt.Printf("Creating symlink: %s -> %s\n", symlinkPath, targetPath)
-	return os.Symlink(targetPath, symlinkPath)
-}
+// Commonly used environment variables in configs, which contractPath writes paths in terms of
+var commonVars = []string{"HOME", "USER", "XDG_CONFIG_HOME", "DOTFILES_HOME", "TOOLS_DIR", "NOTES_DIR", "WIN_HOME"}
 
-// setupSymlinks reads a configuration file and creates symlinks
-func setupSymlinks(configFilePath string, dryRun bool) error {
-	// Check if config file exists
-	if _, err := os.Stat(configFilePath); os.IsNotExist(err) {
-		return fmt.Errorf("error: Config file not found: %s", configFilePath)
+// expandPath expands ALL environment variables in a string
+func expandPath(path string) string {
+	if *noExpand {
+		return path
 	}
-
-	if dryRun {
-		fmt.Printf("[DRY RUN] Would set up symlinks from config: %s\n", configFilePath)
+	if *allowExec && strings.Contains(path, "$(") {
+		path = substituteCommands(path, expandVars)
 	} else {
-		fmt.Printf("Setting up symlinks from config: %s\n", configFilePath)
+		path = expandVars(path)
 	}
 
-	// Open the config file
-	file, err := os.Open(configFilePath)
-	if err != nil {
-		return fmt.Errorf("error opening config file: %w", err)
-	}
-	defer file.Close()
-
-	// Read the file line by line
-	scanner := bufio.NewScanner(file)
-	lineNumber := 0
-
-	commentRegex := regexp.MustCompile(`^\s*#`)
-
-	for scanner.Scan() {
-		lineNumber++
-		line := scanner.Text()
-
-		// Skip empty lines and comments
-		if line == "" || commentRegex.MatchString(line) {
-			continue
-		}
-
-		// Split line into symlink_path and actual_path
-		fields := strings.Fields(line)
-		if len(fields) < 2 {
-			fmt.Printf("Warning: Invalid line %d in config file: %s\n", lineNumber, line)
-			continue
-		}
-
-		// Expand environment variables in both paths
-		symlinkPath := expandPath(fields[0])
-		actualPath := expandPath(fields[1])
-
-		// Skip if either path is empty after expansion
-		if symlinkPath == "" || actualPath == "" {
-			fmt.Printf("Warning: Invalid paths at line %d in config file: %s\n", lineNumber, line)
-			continue
-		}
-
-		// Check if expansion actually happened (detect unexpanded variables)
-		if strings.Contains(symlinkPath, "$") || strings.Contains(actualPath, "$") {
-			fmt.Printf("Warning: Unexpanded environment variables at line %d: %s\n", lineNumber, line)
-		}
-
-		// Get the directory of the symlink
-		symlinkDir := filepath.Dir(symlinkPath)
-
-		if dryRun {
-			// new line for dry run output
-			fmt.Printf("\n")
-			fmt.Printf("[DRY RUN] Line %d: %s -> %s\n", lineNumber, fields[0], fields[1])
-			fmt.Printf("[DRY RUN] Expanded: %s -> %s (dir: %s)\n", symlinkPath, actualPath, symlinkDir)
-		}
-
-		// Create symlink directory if it doesn't exist
-		if err := ensureDirExists(symlinkDir, dryRun); err != nil {
-			return fmt.Errorf("error creating directory %s: %w", symlinkDir, err)
-		}
-
-		// Create the symlink
-		if err := createSymlink(actualPath, symlinkPath, dryRun); err != nil {
-			return fmt.Errorf("error creating symlink at line %d: %w", lineNumber, err)
-		}
+	// Under WSL, Windows paths like C:\Users\me refer to the mounted drive
+	if isWSL() {
+		path, _ = wslPath(path)
 	}
+	return path
+}
 
-	if err := scanner.Err(); err != nil {
-		return fmt.Errorf("error reading config file: %w", err)
+// expandVars expands the environment variables in a string
+func expandVars(path string) string {
+	if *percentVars {
+		path = expandPercentVars(path)
 	}
 
-	if dryRun {
-		fmt.Println("[DRY RUN] Symlink setup complete! (No changes made)")
-	} else {
-		fmt.Println("Symlink setup complete!")
-	}
-	return nil
+	// Use os.ExpandEnv to expand all environment variables
+	// This handles $VAR and ${VAR} syntax automatically
+	return os.ExpandEnv(path)
 }
 
-func getExecutablePath() (string, error) {
-	// Get the executable path
-	exe, err := os.Executable()
-	if err != nil {
-		return "", err
-	}
-
-	// Resolve symlinks
-	realPath, err := filepath.EvalSymlinks(exe)
-	if err != nil {
-		return "", err
-	}
+// Windows-style %VAR% references; names may contain parentheses, as in %ProgramFiles(x86)%
+var percentVarRegex = regexp.MustCompile(`%([A-Za-z_][A-Za-z0-9_()]*)%`)
 
-	return filepath.Dir(realPath), nil
+// expandPercentVars expands %VAR% references, leaving unset ones as written like cmd.exe does
+func expandPercentVars(path string) string {
+	return percentVarRegex.ReplaceAllStringFunc(path, func(ref string) string {
+		if value, ok := os.LookupEnv(ref[1 : len(ref)-1]); ok {
+			return value
+		}
+		return ref
+	})
 }
 
 func showHelp() {
 	fmt.Println("Symlink Manager - Create and manage symlinks from configuration files")
 	fmt.Println("\nUsage:")
-	fmt.Println("  symlinker [flags] [config-file]")
+	fmt.Println("  symlinker [flags] [config-file...]")
+	fmt.Println("  symlinker [flags] <command> [args]")
+	fmt.Println("  symlinker apply [flags] [config-file...]")
 	fmt.Println("\nFlags:")
 	flag.PrintDefaults()
+	fmt.Println("\nCommands:")
+	fmt.Println("  apply [config]   Create the links in the configs (the default; takes the flags above)")
+	fmt.Println("  status [config]  Show which entries are linked, missing or in the way")
+	fmt.Println("  clean [config]   Remove managed links the configs dropped or that now dangle")
+	fmt.Println("  tui [config]     Pick the entries to apply from a checklist of their state")
+	fmt.Println("  explain <path>   Show where a link path is defined and what apply would do to it")
+	fmt.Println("  adopt <path>...  Move existing files into the dotfiles repo and link them back")
+	fmt.Println("  import <dir>...  Print config lines for existing symlinks into the dotfiles repo")
+	fmt.Println("  import-stow <dir> Convert a GNU Stow directory into config lines")
+	fmt.Println("  import-chezmoi   Convert a chezmoi source directory into config lines")
+	fmt.Println("  export [config]  Convert a config to or from Dotbot YAML or JSON (--to)")
+	fmt.Println("  fmt [config]...  Align, dedupe and optionally sort (--sort) a config's entries")
+	fmt.Println("  init             Detect existing dotfiles and write a starter config")
+	fmt.Println("  bootstrap <url>  Clone (or pull) a dotfiles repo and apply its config")
+	fmt.Println("  doctor           Check the config, variables, targets and permissions")
+	fmt.Println("  env [config]     List the variables the configs use, their values and lines")
+	fmt.Println("  lint [config]... Check configs for mistakes without touching the filesystem")
+	fmt.Println("  uninstall        Remove every link recorded in the state manifest")
+	fmt.Println("  undo             Revert the changes made by the most recent run")
+	fmt.Println("  restore [path]   Put backed up originals back in place of their symlinks")
+	fmt.Println("  watch [config]   Apply again whenever the config or the files it links change")
+	fmt.Println("  daemon [config]  Apply every --interval, repairing links changed by other tools")
+	fmt.Println("  serve [config]   Answer HTTP requests for link status, the last run and managed links")
+	fmt.Println("  service install|uninstall --systemd  Run symlinker from a systemd user service")
+	fmt.Println("  hooks install|uninstall [repo]      Apply the repo's config after every git pull")
+	fmt.Println("\nDefault Config:")
+	fmt.Println("  $SYMLINKER_CONFIG, else the first that exists of")
+	fmt.Println("  $XDG_CONFIG_HOME/symlinker/symlinker.conf and ~/.symlinker.conf")
 	fmt.Println("\nEnvironment Variable Expansion:")
 	fmt.Println("  Supports all environment variables in format $VAR or ${VAR}")
 	fmt.Println("  Examples: $HOME, $USER, $DOTFILES_HOME, ${XDG_CONFIG_HOME}")
 	fmt.Println("\nRequired Environment Variables:")
 	fmt.Println("  Make sure to set the environment variables used in your config file")
 	fmt.Println("  Example: export DOTFILES_HOME=\"$HOME/dotfiles\"")
+	fmt.Println("\nExit Codes:")
+	for _, c := range exitCodes {
+		fmt.Printf("  %d  %s\n", c.code, c.text)
+	}
 	fmt.Println("\nExamples:")
 	fmt.Println("  symlinker                    # Use default config file")
 	fmt.Println("  symlinker custom.conf        # Use custom config file")
 	fmt.Println("  symlinker --dry-run          # Preview changes without applying")
 	fmt.Println("  symlinker --dry-run my.conf  # Preview with custom config")
+	fmt.Println("  symlinker adopt ~/.zshrc     # Move ~/.zshrc to its config target and link it")
+	fmt.Println("  symlinker import ~/ --prefix $DOTFILES_HOME > symlinker.conf")
 }
 
-func printEnvironmentInfo(dryRun bool) {
-	if dryRun {
+// printEnvironmentInfo shows the variables the entries refer to before a dry run
+func printEnvironmentInfo(entries []entry, dryRun bool) {
+	if dryRun && verbosity >= levelNormal {
 		fmt.Println("🔍 DRY RUN MODE - No changes will be made")
 		fmt.Println("=" + strings.Repeat("=", 50))
-		fmt.Printf("Current environment variables:\n")
-		
-		// Show commonly used environment variables
-		commonVars := []string{"HOME", "USER", "XDG_CONFIG_HOME", "DOTFILES_HOME", "TOOLS_DIR", "NOTES_DIR"}
-		for _, varName := range commonVars {
-			if value := os.Getenv(varName); value != "" {
-				fmt.Printf("  %s: %s\n", varName, value)
+		fmt.Printf("Environment variables used by the config:\n")
+
+		for _, u := range varUses(entries) {
+			value, ok := os.LookupEnv(u.name)
+			if !ok {
+				value = "MISSING"
 			}
+			fmt.Printf("  %s: %s\n", u.name, value)
 		}
 		fmt.Println("=" + strings.Repeat("=", 50))
 	}
 }
 
 func main() {
-	// CLI args
+	// CLI args; apply takes the global flags after its name too
 	flag.Parse()
+	args := flag.Args()
+	apply := len(args) > 0 && args[0] == "apply"
+	if apply {
+		args = parseArgs(flag.CommandLine, args[1:])
+	}
 
 	// Show help if requested
 	if *help {
 		showHelp()
 		return
 	}
+	if *showVersion {
+		printVersion()
+		return
+	}
 
-	// Get the executable directory
-	execDir, err := getExecutablePath()
-	if err != nil {
-		fmt.Printf("Error getting executable path: %s\n", err)
-		os.Exit(1)
+	// Keep stdout for the machine-readable report alone
+	if *porcelain {
+		if *outputFormat != outputText && *outputFormat != outputPorcelain {
+			errorf("--porcelain and --output=%s can't be used together\n", *outputFormat)
+			os.Exit(exitConfig)
+		}
+		*outputFormat = outputPorcelain
+	}
+	if err := validateOutputFormat(*outputFormat); err != nil {
+		errorf("%s\n", err)
+		os.Exit(exitConfig)
+	}
+	if *outputFormat != outputText {
+		os.Stdout = os.Stderr
+	}
+	if err := setupColor(*colorSetting); err != nil {
+		errorf("%s\n", err)
+		os.Exit(exitConfig)
+	}
+	if *logFile != "" {
+		if err := openLogFile(*logFile, *logFormat); err != nil {
+			errorf("%s\n", err)
+			os.Exit(exitEnv)
+		}
+		verbosef("Running: %s (symlinker %s)\n", strings.Join(os.Args, " "), currentBuild())
+	}
+
+	// Resolve configs, ~ and the state against the --user account's home
+	if *userFlag != "" {
+		if err := switchUser(*userFlag); err != nil {
+			errorf("%s\n", err)
+			os.Exit(exitCodeOf(err))
+		}
 	}
 
+	// Make $WIN_HOME available to configs under WSL
+	setWSLEnv()
+
 	// Default config file location
-	defaultConfigFile := filepath.Join(execDir, "symlinker.conf")
+	defaultConfigFile, found := *configFlag, true
+	if defaultConfigFile == "" {
+		defaultConfigFile, found = findDefaultConfig()
+	}
 
-	// Get config file path from remaining arguments
-	configFilePath := defaultConfigFile
-	if flag.NArg() > 0 {
-		configFilePath = flag.Arg(0)
+	// Run a subcommand if one was given
+	if len(args) > 0 && !apply {
+		if run, ok := commands[args[0]]; ok {
+			err := run(args[1:], defaultConfigFile)
+			handOverUserDirs()
+			if err != nil {
+				errorf("%s\n", err)
+				os.Exit(exitCodeOf(err))
+			}
+			return
+		}
 	}
 
-	// Print environment info if dry run
-	printEnvironmentInfo(*dryRun)
+	// Get config file paths from remaining arguments
+	configs := args
+	if len(configs) == 0 {
+		if !found {
+			errorf("No config file given, and none found at %s\n", strings.Join(configSearchPaths(), ", "))
+			os.Exit(exitConfig)
+		}
+		configs = []string{defaultConfigFile}
+	}
+
+	opts, err := optionsFromFlags()
+	if err != nil {
+		errorf("%s\n", err)
+		os.Exit(exitCodeOf(err))
+	}
+	// Only a run of its own says whether it changed anything; watch and the like go on
+	opts.changedExitCode = *changedExit
 
 	// Setup symlinks
-	if err := setupSymlinks(configFilePath, *dryRun); err != nil {
-		fmt.Printf("Error: %s\n", err)
-		os.Exit(1)
+	err = setupSymlinks(configs, opts)
+	handStateBack()
+	handOverUserDirs()
+	if err == errChanged {
+		os.Exit(exitChanged)
+	} else if err != nil {
+		errorf("%s\n", err)
+		os.Exit(exitCodeOf(err))
 	}
 }
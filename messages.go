@@ -0,0 +1,247 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"regexp"
+	"strings"
+)
+
+// Verbosity levels, set with -q, -v and -vv
+const (
+	levelQuiet   = -1 // errors and the final summary only
+	levelNormal  = 0
+	levelVerbose = 1 // also unchanged and skipped entries and path expansion
+	levelDebug   = 2 // also how existing links compare with the config
+)
+
+// verbosity is the current verbosity level
+var verbosity = levelNormal
+
+// Log levels beyond slog's own: trace for -vv detail, notice for what -q still shows
+const (
+	slogTrace  = slog.LevelDebug - 4
+	slogNotice = slog.LevelError - 1
+)
+
+// logger receives every message: it prints them to stdout, or warnings and errors to
+// stderr, and with --log-file records them
+var logger = slog.New(consoleHandler{})
+
+// infof logs a progress message, shown unless -q was given
+func infof(format string, args ...any) { logf(slog.LevelInfo, format, args...) }
+
+// verbosef logs a message shown with -v
+func verbosef(format string, args ...any) { logf(slog.LevelDebug, format, args...) }
+
+// debugf logs a message shown with -vv
+func debugf(format string, args ...any) { logf(slogTrace, format, args...) }
+
+// warnf logs a warning, shown unless -q was given
+func warnf(format string, args ...any) { logf(slog.LevelWarn, format, args...) }
+
+// errorf logs an error; errors are shown at every verbosity
+func errorf(format string, args ...any) { logf(slog.LevelError, format, args...) }
+
+// noticef logs a message that is shown even with -q, such as the final summary
+func noticef(format string, args ...any) { logf(slogNotice, format, args...) }
+
+// logf formats a message and hands it to the logger
+func logf(level slog.Level, format string, args ...any) {
+	ctx := context.Background()
+	if logger.Enabled(ctx, level) {
+		logger.Log(ctx, level, strings.TrimSuffix(fmt.Sprintf(format, args...), "\n"))
+	}
+}
+
+// consoleLevel is the lowest level printed to stdout at the current verbosity
+func consoleLevel() slog.Level {
+	switch {
+	case verbosity <= levelQuiet:
+		return slogNotice
+	case verbosity == levelNormal:
+		return slog.LevelInfo
+	case verbosity == levelVerbose:
+		return slog.LevelDebug
+	}
+	return slogTrace
+}
+
+// consoleBuffer holds messages for stdout while bufferConsole is in effect
+var consoleBuffer *bufio.Writer
+
+// console is where messages for stdout are written
+func console() io.Writer {
+	if consoleBuffer != nil {
+		return consoleBuffer
+	}
+	return os.Stdout
+}
+
+// bufferConsole buffers messages for stdout until the returned function is called, unless
+// stdout is a terminal, where each should show as it is made. Writing a line at a time
+// to a pipe or file is much of the cost of runs over tens of thousands of entries.
+func bufferConsole() (done func()) {
+	if consoleBuffer != nil || isTerminal(os.Stdout) {
+		return func() {}
+	}
+	consoleBuffer = bufio.NewWriterSize(os.Stdout, 64<<10)
+	return func() {
+		consoleBuffer.Flush()
+		consoleBuffer = nil
+	}
+}
+
+// flushConsole writes out buffered messages, before a prompt or another process writes
+func flushConsole() {
+	if consoleBuffer != nil {
+		consoleBuffer.Flush()
+	}
+}
+
+// consoleHandler prints messages to stdout the way symlinker always has, and warnings
+// and errors to stderr so they stay out of piped output
+type consoleHandler struct{}
+
+func (consoleHandler) Enabled(_ context.Context, level slog.Level) bool {
+	return level >= consoleLevel()
+}
+
+func (consoleHandler) Handle(_ context.Context, r slog.Record) error {
+	// Messages go above the progress line
+	activeProgress.clear()
+	defer activeProgress.draw()
+
+	// What was printed before a warning or error is flushed first, to keep them in order
+	switch r.Level {
+	case slog.LevelWarn:
+		flushConsole()
+		_, err := fmt.Fprintf(os.Stderr, "Warning: %s\n", r.Message)
+		return err
+	case slog.LevelError:
+		flushConsole()
+		_, err := fmt.Fprintf(os.Stderr, "%s %s\n", paint(styleError, "Error:"), r.Message)
+		return err
+	}
+	_, err := fmt.Fprintln(console(), r.Message)
+	return err
+}
+
+func (h consoleHandler) WithAttrs([]slog.Attr) slog.Handler { return h }
+func (h consoleHandler) WithGroup(string) slog.Handler      { return h }
+
+// Formats for --log-format
+const (
+	logFormatText = "text"
+	logFormatJSON = "json"
+)
+
+// openLogFile starts recording messages to path, in addition to printing them.
+// The file is appended to, so it builds up a record across unattended runs.
+func openLogFile(path, format string) error {
+	file, err := os.OpenFile(path, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0600)
+	if err != nil {
+		return fmt.Errorf("error opening log file: %w", err)
+	}
+
+	level := slog.LevelDebug
+	if verbosity >= levelDebug {
+		level = slogTrace
+	}
+	opts := &slog.HandlerOptions{Level: level, ReplaceAttr: nameLevels}
+	var fileHandler slog.Handler
+	switch format {
+	case logFormatText:
+		fileHandler = slog.NewTextHandler(file, opts)
+	case logFormatJSON:
+		fileHandler = slog.NewJSONHandler(file, opts)
+	default:
+		file.Close()
+		return fmt.Errorf("invalid log format %q (want text or json)", format)
+	}
+
+	logger = slog.New(teeHandler{consoleHandler{}, plainHandler{fileHandler}}).With("pid", os.Getpid())
+	return nil
+}
+
+// nameLevels gives the extra log levels readable names
+func nameLevels(_ []string, a slog.Attr) slog.Attr {
+	if a.Key == slog.LevelKey {
+		switch a.Value.Any().(slog.Level) {
+		case slogTrace:
+			a.Value = slog.StringValue("TRACE")
+		case slogNotice:
+			a.Value = slog.StringValue("NOTICE")
+		}
+	}
+	return a
+}
+
+// ansiEscape matches the color codes added by paint
+var ansiEscape = regexp.MustCompile("\x1b\\[[0-9;]*m")
+
+// plainHandler strips colors and surrounding blank lines from messages before passing them on
+type plainHandler struct {
+	slog.Handler
+}
+
+func (h plainHandler) Handle(ctx context.Context, r slog.Record) error {
+	plain := slog.NewRecord(r.Time, r.Level, strings.TrimSpace(ansiEscape.ReplaceAllString(r.Message, "")), r.PC)
+	r.Attrs(func(a slog.Attr) bool {
+		plain.AddAttrs(a)
+		return true
+	})
+	return h.Handler.Handle(ctx, plain)
+}
+
+func (h plainHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	return plainHandler{h.Handler.WithAttrs(attrs)}
+}
+
+func (h plainHandler) WithGroup(name string) slog.Handler {
+	return plainHandler{h.Handler.WithGroup(name)}
+}
+
+// teeHandler sends each record to every handler that wants it
+type teeHandler []slog.Handler
+
+func (t teeHandler) Enabled(ctx context.Context, level slog.Level) bool {
+	for _, h := range t {
+		if h.Enabled(ctx, level) {
+			return true
+		}
+	}
+	return false
+}
+
+func (t teeHandler) Handle(ctx context.Context, r slog.Record) error {
+	var firstErr error
+	for _, h := range t {
+		if h.Enabled(ctx, r.Level) {
+			if err := h.Handle(ctx, r.Clone()); err != nil && firstErr == nil {
+				firstErr = err
+			}
+		}
+	}
+	return firstErr
+}
+
+func (t teeHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	handlers := make(teeHandler, len(t))
+	for i, h := range t {
+		handlers[i] = h.WithAttrs(attrs)
+	}
+	return handlers
+}
+
+func (t teeHandler) WithGroup(name string) slog.Handler {
+	handlers := make(teeHandler, len(t))
+	for i, h := range t {
+		handlers[i] = h.WithGroup(name)
+	}
+	return handlers
+}
@@ -0,0 +1,141 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strconv"
+	"unicode/utf8"
+)
+
+// nodeKind identifies the shape of a structured config node
+type nodeKind int
+
+const (
+	scalarNode nodeKind = iota
+	sequenceNode
+	mappingNode
+)
+
+// node is a parsed YAML or JSON value that remembers key order and source positions
+type node struct {
+	kind  nodeKind
+	value string  // scalar value
+	null  bool    // scalar was null, ~ or empty
+	items []*node // sequence items
+	keys  []*node // mapping keys, in source order
+	vals  []*node // mapping values, parallel to keys
+	line  int
+	col   int // 1-based column, or 0 if unknown
+}
+
+// get returns the value for a mapping key
+func (n *node) get(key string) *node {
+	if n == nil || n.kind != mappingNode {
+		return nil
+	}
+	for i, k := range n.keys {
+		if k.value == key {
+			return n.vals[i]
+		}
+	}
+	return nil
+}
+
+// bool interprets a scalar as a YAML boolean
+func (n *node) bool() (bool, error) {
+	if n == nil {
+		return false, fmt.Errorf("expected a boolean")
+	}
+	if n.kind != scalarNode {
+		return false, fmt.Errorf("line %d: expected a boolean", n.line)
+	}
+	switch n.value {
+	case "true", "True", "TRUE", "yes", "Yes", "on", "On":
+		return true, nil
+	case "false", "False", "FALSE", "no", "No", "off", "Off":
+		return false, nil
+	}
+	return false, fmt.Errorf("line %d: expected a boolean, got %q", n.line, n.value)
+}
+
+// parseJSONNode decodes JSON into a node tree, preserving key order and source positions
+func parseJSONNode(data []byte) (*node, error) {
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.UseNumber()
+	n, err := decodeJSONNode(dec, data)
+	if err != nil {
+		line, _ := positionAt(data, dec.InputOffset())
+		return nil, fmt.Errorf("line %d: %w", line, err)
+	}
+	if _, err := dec.Token(); err != io.EOF {
+		line, _ := positionAt(data, dec.InputOffset())
+		return nil, fmt.Errorf("line %d: unexpected data after top-level value", line)
+	}
+	return n, nil
+}
+
+// decodeJSONNode reads the next JSON value from dec
+func decodeJSONNode(dec *json.Decoder, data []byte) (*node, error) {
+	line, col := positionAt(data, dec.InputOffset())
+	tok, err := dec.Token()
+	if err != nil {
+		return nil, err
+	}
+
+	switch t := tok.(type) {
+	case json.Delim:
+		switch t {
+		case '[':
+			n := &node{kind: sequenceNode, line: line, col: col}
+			for dec.More() {
+				item, err := decodeJSONNode(dec, data)
+				if err != nil {
+					return nil, err
+				}
+				n.items = append(n.items, item)
+			}
+			_, err := dec.Token()
+			return n, err
+		case '{':
+			n := &node{kind: mappingNode, line: line, col: col}
+			for dec.More() {
+				key, err := decodeJSONNode(dec, data)
+				if err != nil {
+					return nil, err
+				}
+				val, err := decodeJSONNode(dec, data)
+				if err != nil {
+					return nil, err
+				}
+				n.keys = append(n.keys, key)
+				n.vals = append(n.vals, val)
+			}
+			_, err := dec.Token()
+			return n, err
+		}
+		return nil, fmt.Errorf("unexpected %q", t)
+	case nil:
+		return &node{kind: scalarNode, null: true, line: line, col: col}, nil
+	case string:
+		return &node{kind: scalarNode, value: t, line: line, col: col}, nil
+	case bool:
+		return &node{kind: scalarNode, value: strconv.FormatBool(t), line: line, col: col}, nil
+	case json.Number:
+		return &node{kind: scalarNode, value: t.String(), line: line, col: col}, nil
+	}
+	return nil, fmt.Errorf("unexpected token %v", tok)
+}
+
+// positionAt returns the 1-based line and column of a byte offset, skipping leading whitespace
+func positionAt(data []byte, offset int64) (line, col int) {
+	if offset > int64(len(data)) {
+		offset = int64(len(data))
+	}
+	for offset < int64(len(data)) && (data[offset] == ' ' || data[offset] == '\t' || data[offset] == '\n' || data[offset] == '\r' || data[offset] == ',' || data[offset] == ':') {
+		offset++
+	}
+	start := bytes.LastIndexByte(data[:offset], '\n') + 1
+	return bytes.Count(data[:offset], []byte("\n")) + 1, utf8.RuneCount(data[start:offset]) + 1
+}
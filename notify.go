@@ -0,0 +1,91 @@
+package main
+
+import (
+	"fmt"
+	"os/exec"
+	"runtime"
+	"strings"
+)
+
+// Settings for --notify: which runs end with a desktop notification
+const (
+	notifyNever    = "never"
+	notifyChanges  = "changes"  // runs that changed or failed to change something
+	notifyFailures = "failures" // runs with a failed entry or an error
+	notifyAlways   = "always"
+)
+
+// validateNotify checks a --notify setting
+func validateNotify(when string) error {
+	switch when {
+	case notifyNever, notifyChanges, notifyFailures, notifyAlways:
+		return nil
+	}
+	return fmt.Errorf("invalid --notify %q (want never, changes, failures or always)", when)
+}
+
+// notifyRun sends a desktop notification summarizing a run, if when asks for one. Runs
+// in the background, such as from watch, daemon or a git hook, would otherwise fail
+// without anyone noticing.
+func notifyRun(when string, s runSummary, err error) {
+	failed := err != nil || s.Failed > 0
+	changed := s.changes() > 0
+	switch {
+	case when == notifyAlways:
+	case when == notifyChanges && (changed || failed):
+	case when == notifyFailures && failed:
+	default:
+		return
+	}
+
+	title := "symlinker"
+	body := s.String()
+	if failed {
+		title = "symlinker: setup failed"
+	}
+	if err != nil {
+		msg, _, _ := strings.Cut(err.Error(), "\n")
+		body += "\n" + msg
+	}
+	if err := sendNotification(title, body); err != nil {
+		warnf("can't send desktop notification: %s\n", err)
+	}
+}
+
+// sendNotification shows a desktop notification with the tools each system has:
+// notify-send, osascript, or PowerShell's toast notifications on Windows
+func sendNotification(title, body string) error {
+	var cmd *exec.Cmd
+	switch runtime.GOOS {
+	case "darwin":
+		script := fmt.Sprintf("display notification %s with title %s", appleScriptQuote(body), appleScriptQuote(title))
+		cmd = exec.Command("osascript", "-e", script)
+	case "windows":
+		script := `[Windows.UI.Notifications.ToastNotificationManager, Windows.UI.Notifications, ContentType = WindowsRuntime] > $null
+$xml = [Windows.UI.Notifications.ToastNotificationManager]::GetTemplateContent([Windows.UI.Notifications.ToastTemplateType]::ToastText02)
+$text = $xml.GetElementsByTagName('text')
+$text[0].AppendChild($xml.CreateTextNode(` + powerShellQuote(title) + `)) > $null
+$text[1].AppendChild($xml.CreateTextNode(` + powerShellQuote(body) + `)) > $null
+[Windows.UI.Notifications.ToastNotificationManager]::CreateToastNotifier('symlinker').Show([Windows.UI.Notifications.ToastNotification]::new($xml))`
+		cmd = exec.Command("powershell", "-NoProfile", "-NonInteractive", "-Command", script)
+	default:
+		cmd = exec.Command("notify-send", "--app-name=symlinker", title, body)
+	}
+	if out, err := cmd.CombinedOutput(); err != nil {
+		if msg := strings.TrimSpace(string(out)); msg != "" {
+			return fmt.Errorf("%s: %s", cmd.Args[0], msg)
+		}
+		return fmt.Errorf("%s: %w", cmd.Args[0], err)
+	}
+	return nil
+}
+
+// appleScriptQuote quotes s as an AppleScript string
+func appleScriptQuote(s string) string {
+	return `"` + strings.NewReplacer(`\`, `\\`, `"`, `\"`).Replace(s) + `"`
+}
+
+// powerShellQuote quotes s as a literal PowerShell string
+func powerShellQuote(s string) string {
+	return "'" + strings.ReplaceAll(s, "'", "''") + "'"
+}
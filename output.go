@@ -0,0 +1,348 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Formats for --output
+const (
+	outputText      = "text"
+	outputJSON      = "json"
+	outputPorcelain = "porcelain"
+)
+
+// validateOutputFormat checks an --output value
+func validateOutputFormat(format string) error {
+	switch format {
+	case outputText, outputJSON, outputPorcelain:
+		return nil
+	}
+	return fmt.Errorf("invalid output format %q (want text, json or porcelain)", format)
+}
+
+// machineOut is where machine-readable output goes. main points os.Stdout at stderr
+// when one is requested, so progress messages can't get mixed into it.
+var machineOut io.Writer = os.Stdout
+
+// What was done, or would be done, for an entry
+const (
+	actionCreate    = "create"    // make a link where nothing exists
+	actionReplace   = "replace"   // remove what is at the link path and link in its place
+	actionUnchanged = "unchanged" // the link is already in place
+	actionSkip      = "skip"      // leave the link path alone
+)
+
+// How an action turned out
+const (
+	statusPlanned = "planned" // dry run: the action would be taken
+	statusDone    = "done"
+	statusSkipped = "skipped"
+	statusFailed  = "failed"
+	statusSudo    = "sudo" // handed to a run as root
+)
+
+// action is the outcome of applying one entry, as reported by --output json
+type action struct {
+	Line     int    `json:"line,omitempty"`
+	Link     string `json:"link"`
+	Target   string `json:"target"`
+	Mode     string `json:"mode,omitempty"`
+	Action   string `json:"action"`
+	Status   string `json:"status"`
+	Replaced string `json:"replaced,omitempty"` // kind of path that was replaced: symlink, file or dir
+	Previous string `json:"previous,omitempty"` // target of the replaced symlink
+	Backup   string `json:"backup,omitempty"`   // where the replaced path was moved to
+	Trashed  string `json:"trashed,omitempty"`  // where in the trash the replaced path went
+	Reason   string `json:"reason,omitempty"`   // why the entry was skipped
+	Error    string `json:"error,omitempty"`
+}
+
+// runReport collects the outcomes of a run. A machine-readable report is written as
+// actions are added rather than held until the end; only a text dry run keeps its
+// actions, for the plan it prints.
+type runReport struct {
+	format  string
+	dryRun  bool
+	actions []action
+	counts  runSummary
+	started time.Time
+	out     *bufio.Writer
+	written int   // actions written to out so far
+	err     error // the first error writing to out
+	// changedLine ends porcelain output with a CHANGED, OK or FAILED line, for --changed-exit-code
+	changedLine bool
+}
+
+// newRunReport starts the report for a run, in the given --output format
+func newRunReport(format string, dryRun bool) *runReport {
+	return &runReport{format: format, dryRun: dryRun, started: time.Now(), out: bufio.NewWriter(machineOut)}
+}
+
+// add records an action
+func (r *runReport) add(a action) {
+	r.counts.count(a)
+	switch {
+	case r.format == outputJSON:
+		r.writeJSONAction(a)
+	case r.format == outputPorcelain:
+		r.writePorcelainLine(a)
+	case r.dryRun:
+		r.actions = append(r.actions, a)
+	}
+}
+
+// fail records a failed action and returns err
+func (r *runReport) fail(a action, err error) error {
+	a.Status = statusFailed
+	a.Error = err.Error()
+	r.add(a)
+	return err
+}
+
+// finish completes the report once the run is over, with the error that stopped it if
+// any; text output has been printed as the run went
+func (r *runReport) finish(runErr error) error {
+	switch {
+	case r.format == outputJSON:
+		r.writeJSONSummary(r.summarize())
+	case r.format == outputPorcelain && r.changedLine:
+		r.writePorcelainChanged(runErr)
+	}
+	if err := r.out.Flush(); err != nil && r.err == nil {
+		r.err = fmt.Errorf("error writing %s output: %w", r.format, err)
+	}
+	return r.err
+}
+
+// writeJSONAction adds an action to the JSON object, which holds the actions and,
+// once the run is over, the summary. It is laid out as if encoded in one go.
+func (r *runReport) writeJSONAction(a action) {
+	data, err := json.MarshalIndent(a, "    ", "  ")
+	if err != nil {
+		r.failWrite(err)
+		return
+	}
+	sep := ",\n    "
+	if r.written == 0 {
+		sep = "{\n  \"actions\": [\n    "
+	}
+	r.out.WriteString(sep)
+	r.out.Write(data)
+	r.written++
+}
+
+// writeJSONSummary ends the JSON object with the summary
+func (r *runReport) writeJSONSummary(summary runSummary) {
+	data, err := json.MarshalIndent(summary, "  ", "  ")
+	if err != nil {
+		r.failWrite(err)
+		return
+	}
+	if r.written == 0 {
+		r.out.WriteString("{\n  \"actions\": [],\n")
+	} else {
+		r.out.WriteString("\n  ],\n")
+	}
+	r.out.WriteString("  \"summary\": ")
+	r.out.Write(data)
+	r.out.WriteString("\n}\n")
+}
+
+// failWrite remembers the first error writing the report
+func (r *runReport) failWrite(err error) {
+	if r.err == nil {
+		r.err = fmt.Errorf("error writing %s output: %w", r.format, err)
+	}
+}
+
+// runSummary counts the outcomes of a run
+type runSummary struct {
+	DryRun    bool      `json:"dry_run"`
+	Created   int       `json:"created"`
+	Relinked  int       `json:"relinked"` // existing symlinks pointed somewhere else
+	Replaced  int       `json:"replaced"` // files and directories replaced by links
+	Unchanged int       `json:"unchanged"`
+	Skipped   int       `json:"skipped"`
+	BackedUp  int       `json:"backed_up"` // replaced paths kept in a backup or the trash
+	Sudo      int       `json:"sudo"`
+	Failed    int       `json:"failed"`
+	Elapsed   float64   `json:"elapsed_seconds"`
+	Build     buildInfo `json:"symlinker"` // the build that did the run
+}
+
+// summarize returns the counts of the actions so far and the time taken
+func (r *runReport) summarize() runSummary {
+	s := r.counts
+	s.DryRun, s.Elapsed = r.dryRun, time.Since(r.started).Seconds()
+	s.Build = currentBuild()
+	return s
+}
+
+// count adds an action to the counts by its outcome
+func (s *runSummary) count(a action) {
+	switch {
+	case a.Status == statusFailed:
+		s.Failed++
+	case a.Status == statusSudo:
+		s.Sudo++
+	case a.Action == actionCreate:
+		s.Created++
+	case a.Action == actionReplace && a.Replaced == "symlink":
+		s.Relinked++
+	case a.Action == actionReplace:
+		s.Replaced++
+	case a.Action == actionUnchanged:
+		s.Unchanged++
+	case a.Action == actionSkip:
+		s.Skipped++
+	}
+	if (a.Backup != "" || a.Trashed != "") && a.Status != statusFailed {
+		s.BackedUp++
+	}
+}
+
+// String phrases the counts that aren't zero, such as "2 created, 5 unchanged in 0.03s",
+// or what a dry run would do
+func (s runSummary) String() string {
+	counts := []struct {
+		n          int
+		done, plan string
+	}{
+		{s.Created, "created", "to create"},
+		{s.Relinked, "relinked", "to relink"},
+		{s.Replaced, "replaced", "to replace"},
+		{s.BackedUp, "backed up", "to back up"},
+		{s.Unchanged, "unchanged", "unchanged"},
+		{s.Skipped, "skipped", "to skip"},
+		{s.Sudo, "via sudo", "via sudo"},
+		{s.Failed, "failed", "failing"},
+	}
+	var parts []string
+	for _, c := range counts {
+		if c.n > 0 {
+			word := c.done
+			if s.DryRun {
+				word = c.plan
+			}
+			parts = append(parts, fmt.Sprintf("%d %s", c.n, word))
+		}
+	}
+	if len(parts) == 0 {
+		parts = append(parts, "nothing to do")
+	}
+	return fmt.Sprintf("%s in %.2fs", strings.Join(parts, ", "), s.Elapsed)
+}
+
+// changes returns how many entries were changed, or would be: created, relinked,
+// replaced or handed to sudo
+func (s runSummary) changes() int {
+	return s.Created + s.Relinked + s.Replaced + s.Sudo
+}
+
+// writeDiff prints a dry run's plan grouped by action, showing what each link path holds
+// now and what it would hold, with unchanged entries summed up in a count
+func (r *runReport) writeDiff() {
+	groups := map[string][]string{}
+	unchanged := 0
+	for _, a := range r.actions {
+		link, target := contractPath(a.Link), contractPath(a.Target)
+		if a.Mode != "" && a.Mode != modeSymlink {
+			target += " (" + linkNoun(a.Mode) + ")"
+		}
+		switch {
+		case a.Status == statusFailed:
+			groups["error"] = append(groups["error"], fmt.Sprintf("%s: %s", link, a.Error))
+		case a.Action == actionUnchanged:
+			unchanged++
+		case a.Action == actionSkip:
+			groups[a.Action] = append(groups[a.Action], fmt.Sprintf("%s (%s)", link, a.Reason))
+		case a.Action == actionReplace:
+			current := a.Replaced
+			if a.Previous != "" {
+				current = contractPath(a.Previous)
+			}
+			groups[a.Action] = append(groups[a.Action], fmt.Sprintf("%s: %s -> %s", link, current, target))
+		default:
+			groups[a.Action] = append(groups[a.Action], fmt.Sprintf("%s -> %s", link, target))
+		}
+	}
+
+	w := console()
+	fmt.Fprintln(w)
+	for _, g := range []struct{ key, heading, style string }{
+		{actionCreate, "Would create", styleCreate},
+		{actionReplace, "Would replace", styleReplace},
+		{actionSkip, "Would skip", styleSkip},
+		{"error", "Would fail", styleError},
+	} {
+		if lines := groups[g.key]; len(lines) > 0 {
+			fmt.Fprintln(w, paint(g.style, fmt.Sprintf("%s (%d):", g.heading, len(lines))))
+			for _, line := range lines {
+				fmt.Fprintf(w, "  %s\n", paint(g.style, line))
+			}
+		}
+	}
+	if unchanged > 0 {
+		fmt.Fprintln(w, paint(styleSkip, fmt.Sprintf("Unchanged (%d)", unchanged)))
+	}
+}
+
+// porcelainField quotes a field that would break the line layout, like git does with
+// unusual paths; other fields, including Windows paths, are written as they are
+func porcelainField(f string) string {
+	if strings.ContainsAny(f, "\t\n\r") || strings.HasPrefix(f, `"`) {
+		return strconv.Quote(f)
+	}
+	return f
+}
+
+// writePorcelainChanged ends porcelain output with "CHANGED", tab, and how many entries
+// were changed (or would be), or with "OK" when everything was already in place, as
+// configuration management tools report a task. A run with failed entries ends with
+// "FAILED" and how many failed instead, and one stopped by anything else has no line.
+func (r *runReport) writePorcelainChanged(runErr error) {
+	var line string
+	switch {
+	case r.counts.Failed > 0:
+		line = fmt.Sprintf("FAILED\t%d\n", r.counts.Failed)
+	case runErr != nil:
+		return
+	case r.counts.changes() > 0:
+		line = fmt.Sprintf("CHANGED\t%d\n", r.counts.changes())
+	default:
+		line = "OK\n"
+	}
+	if _, err := r.out.WriteString(line); err != nil {
+		r.failWrite(err)
+	}
+}
+
+// writePorcelainLine prints a tab-separated line for an action: VERB, link, target, then
+// the reason or error for SKIP and ERROR lines. The layout is stable: fields are only
+// ever added at the end.
+func (r *runReport) writePorcelainLine(a action) {
+	verb := strings.ToUpper(a.Action)
+	fields := []string{a.Link, a.Target}
+	switch a.Status {
+	case statusFailed:
+		verb = "ERROR"
+		fields = append(fields, a.Error)
+	case statusSkipped:
+		fields = append(fields, a.Reason)
+	case statusSudo:
+		verb = "SUDO"
+	}
+	for i, f := range fields {
+		fields[i] = porcelainField(f)
+	}
+	if _, err := fmt.Fprintf(r.out, "%s\t%s\n", verb, strings.Join(fields, "\t")); err != nil {
+		r.failWrite(err)
+	}
+}
@@ -0,0 +1,56 @@
+package main
+
+import (
+	"bufio"
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestPorcelainChangedLine(t *testing.T) {
+	created := action{Link: "/home/me/.vimrc", Target: "/dots/vimrc", Action: actionCreate, Status: statusDone}
+	unchanged := action{Link: "/home/me/.zshrc", Target: "/dots/zshrc", Action: actionUnchanged, Status: statusDone}
+	failed := action{Link: "/home/me/.tmux.conf", Target: "/dots/tmux.conf", Action: actionCreate, Status: statusFailed, Error: "permission denied"}
+	stopped := errors.New("pre hook failed")
+
+	tests := []struct {
+		name    string
+		actions []action
+		runErr  error
+		want    string
+	}{
+		{"nothing to do", []action{unchanged}, nil, "UNCHANGED\t/home/me/.zshrc\t/dots/zshrc\nOK\n"},
+		{"changed", []action{created, unchanged}, nil, "CREATE\t/home/me/.vimrc\t/dots/vimrc\nUNCHANGED\t/home/me/.zshrc\t/dots/zshrc\nCHANGED\t1\n"},
+		{"failed", []action{created, failed}, stopped, "CREATE\t/home/me/.vimrc\t/dots/vimrc\nERROR\t/home/me/.tmux.conf\t/dots/tmux.conf\tpermission denied\nFAILED\t1\n"},
+		{"failed and continued", []action{failed, failed, unchanged}, nil, "ERROR\t/home/me/.tmux.conf\t/dots/tmux.conf\tpermission denied\nERROR\t/home/me/.tmux.conf\t/dots/tmux.conf\tpermission denied\nUNCHANGED\t/home/me/.zshrc\t/dots/zshrc\nFAILED\t2\n"},
+		{"stopped without failures", []action{created}, stopped, "CREATE\t/home/me/.vimrc\t/dots/vimrc\n"},
+		{"stopped before anything", nil, stopped, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var out strings.Builder
+			r := &runReport{format: outputPorcelain, changedLine: true, out: bufio.NewWriter(&out)}
+			for _, a := range tt.actions {
+				r.add(a)
+			}
+			if err := r.finish(tt.runErr); err != nil {
+				t.Fatalf("finish: %v", err)
+			}
+			if got := out.String(); got != tt.want {
+				t.Errorf("got %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestPorcelainWithoutChangedLine(t *testing.T) {
+	var out strings.Builder
+	r := &runReport{format: outputPorcelain, out: bufio.NewWriter(&out)}
+	r.add(action{Link: "/home/me/.vimrc", Target: "/dots/vimrc", Action: actionCreate, Status: statusDone})
+	if err := r.finish(nil); err != nil {
+		t.Fatalf("finish: %v", err)
+	}
+	if got, want := out.String(), "CREATE\t/home/me/.vimrc\t/dots/vimrc\n"; got != want {
+		t.Errorf("got %q, want %q", got, want)
+	}
+}
@@ -0,0 +1,135 @@
+package main
+
+import (
+	"fmt"
+	"io/fs"
+	"os"
+	"os/user"
+	"path/filepath"
+	"strconv"
+)
+
+// ownership says how created directories, copies and links are owned, and what mode
+// new directories get
+type ownership struct {
+	uid, gid int         // -1 leaves the owner or group as created
+	dirMode  os.FileMode // permissions for new directories
+	exact    bool        // set dirMode exactly instead of limiting it by the parent and umask
+}
+
+// dirModeFlag is the --dir-mode flag: octal permissions for new directories
+type dirModeFlag struct {
+	mode os.FileMode
+	set  bool
+}
+
+func (f *dirModeFlag) String() string {
+	if !f.set {
+		return ""
+	}
+	return fmt.Sprintf("%04o", f.mode)
+}
+
+func (f *dirModeFlag) Set(value string) error {
+	mode, err := parseDirMode(value)
+	if err != nil {
+		return err
+	}
+	f.mode, f.set = mode, true
+	return nil
+}
+
+// defaultOwnership leaves owners alone, or gives everything to the --user account, and
+// creates directories with --dir-mode, or else 0755 limited by the parent directory and the umask
+func defaultOwnership() ownership {
+	own := ownership{uid: -1, gid: -1, dirMode: 0755}
+	if switchedUser != nil {
+		own.uid, own.gid = switchedUser.uid, switchedUser.gid
+	}
+	if dirMode.set {
+		own.dirMode, own.exact = dirMode.mode, true
+	}
+	return own
+}
+
+// changesOwner reports whether an owner or group is to be set
+func (o ownership) changesOwner() bool {
+	return o.uid != -1 || o.gid != -1
+}
+
+// lookupUID resolves a user name or numeric ID
+func lookupUID(owner string) (int, error) {
+	if uid, err := strconv.Atoi(owner); err == nil {
+		return uid, nil
+	}
+	u, err := user.Lookup(owner)
+	if err != nil {
+		return 0, err
+	}
+	return strconv.Atoi(u.Uid)
+}
+
+// lookupGID resolves a group name or numeric ID
+func lookupGID(group string) (int, error) {
+	if gid, err := strconv.Atoi(group); err == nil {
+		return gid, nil
+	}
+	g, err := user.LookupGroup(group)
+	if err != nil {
+		return 0, err
+	}
+	return strconv.Atoi(g.Gid)
+}
+
+// parseDirMode parses an octal directory mode such as 0700
+func parseDirMode(value string) (os.FileMode, error) {
+	mode, err := strconv.ParseUint(value, 8, 32)
+	if err != nil || mode > 0777 {
+		return 0, fmt.Errorf("invalid mode %q (want octal permissions such as 0700)", value)
+	}
+	return os.FileMode(mode), nil
+}
+
+// validateOwner checks an owner= value
+func validateOwner(value string) error {
+	_, err := lookupUID(value)
+	return err
+}
+
+// validateGroup checks a group= value
+func validateGroup(value string) error {
+	_, err := lookupGID(value)
+	return err
+}
+
+// validateDirMode checks a dirmode= value
+func validateDirMode(value string) error {
+	_, err := parseDirMode(value)
+	return err
+}
+
+// entryOwnership applies an entry's owner=, group= and dirmode= options to base
+func entryOwnership(e entry, base ownership) ownership {
+	own := base
+	if v, ok := e.options["owner"]; ok {
+		own.uid, _ = lookupUID(v)
+	}
+	if v, ok := e.options["group"]; ok {
+		own.gid, _ = lookupGID(v)
+	}
+	if v, ok := e.options["dirmode"]; ok {
+		own.dirMode, _ = parseDirMode(v)
+		own.exact = true
+	}
+	return own
+}
+
+// chownTree sets the owner and group of path and everything below it, without following symlinks
+func chownTree(path string, own ownership) error {
+	return filepath.WalkDir(path, func(p string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		return os.Lchown(p, own.uid, own.gid)
+	})
+}
@@ -0,0 +1,143 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// Values of the pick option, which makes an entry's actual path a glob
+const (
+	pickLatest  = "latest"  // the match modified most recently
+	pickVersion = "version" // the match with the highest version in its name
+)
+
+// validatePick checks a pick option value
+func validatePick(value string) error {
+	switch value {
+	case pickLatest, pickVersion:
+		return nil
+	}
+	return fmt.Errorf("invalid pick %q (want latest or version)", value)
+}
+
+// resolveEntryTargets settles the actual path of each entry. One with a pick option is
+// pointed at the match of its glob that the option picks; entries whose glob matches
+// nothing are left out, with a warning. With --resolve-targets or resolve=true, the
+// symlinks along the actual path are then followed to the file at the end of them.
+func resolveEntryTargets(entries []entry) ([]entry, []string) {
+	var warnings []string
+	resolved := entries[:0]
+	for _, e := range entries {
+		if pick := e.options["pick"]; pick != "" {
+			target, err := pickMatch(e.target, pick)
+			if err != nil {
+				warnings = append(warnings, fmt.Sprintf("Line %d: %s", e.line, err))
+				continue
+			}
+			verbosef("Line %d: picked %s from %s\n", e.line, target, e.target)
+			e.target = target
+		}
+		if entryFlag(e, "resolve", *resolveTargets) && !isPluginMode(e.options["mode"]) {
+			if target := canonicalTarget(e.target); target != e.target {
+				verbosef("Line %d: resolved %s to %s\n", e.line, e.target, target)
+				e.target = target
+			}
+		}
+		resolved = append(resolved, e)
+	}
+	return resolved, warnings
+}
+
+// canonicalTarget follows the symlinks along an actual path, so a link made to it
+// doesn't go through a chain of them. With --root, the path is followed where it is in
+// the root now, and kept as it is if it leads out of the root. A path that can't be
+// followed, such as a missing one, is kept as it is too.
+func canonicalTarget(target string) string {
+	resolved, err := filepath.EvalSymlinks(underRoot(*rootDir, target))
+	if err != nil {
+		return target
+	}
+	resolved = absPath(resolved)
+	if *rootDir != "" {
+		root, err := filepath.EvalSymlinks(absPath(*rootDir))
+		if err != nil {
+			return target
+		}
+		rel, err := filepath.Rel(root, resolved)
+		if err != nil || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+			return target
+		}
+		return filepath.Join(string(filepath.Separator), rel)
+	}
+	return resolved
+}
+
+// pickMatch returns the match of pattern that pick picks
+func pickMatch(pattern, pick string) (string, error) {
+	matches, err := filepath.Glob(pattern)
+	if err != nil {
+		return "", fmt.Errorf("invalid glob %s: %w", pattern, err)
+	}
+	if len(matches) == 0 {
+		return "", fmt.Errorf("nothing matches %s to pick from", pattern)
+	}
+
+	best := matches[0]
+	var bestTime int64
+	if info, err := os.Stat(best); err == nil {
+		bestTime = info.ModTime().UnixNano()
+	}
+	for _, m := range matches[1:] {
+		switch pick {
+		case pickLatest:
+			// Matches modified at the same time are told apart by version
+			info, err := os.Stat(m)
+			if err != nil {
+				continue
+			}
+			if t := info.ModTime().UnixNano(); t > bestTime || (t == bestTime && compareVersions(filepath.Base(m), filepath.Base(best)) > 0) {
+				best, bestTime = m, t
+			}
+		case pickVersion:
+			if compareVersions(filepath.Base(m), filepath.Base(best)) > 0 {
+				best = m
+			}
+		}
+	}
+	return best, nil
+}
+
+// compareVersions orders names the way versions sort, comparing runs of digits as
+// numbers, so app-1.10.0 comes after app-1.9.2
+func compareVersions(a, b string) int {
+	for a != "" && b != "" {
+		ca, restA := versionChunk(a)
+		cb, restB := versionChunk(b)
+		na, errA := strconv.ParseUint(ca, 10, 64)
+		nb, errB := strconv.ParseUint(cb, 10, 64)
+		switch {
+		case errA == nil && errB == nil && na != nb:
+			if na < nb {
+				return -1
+			}
+			return 1
+		case (errA != nil || errB != nil) && ca != cb:
+			return strings.Compare(ca, cb)
+		}
+		a, b = restA, restB
+	}
+	return strings.Compare(a, b)
+}
+
+// versionChunk splits off the leading run of digits, or of other characters, of s
+func versionChunk(s string) (chunk, rest string) {
+	digit := s[0] >= '0' && s[0] <= '9'
+	i := 1
+	for i < len(s) && (s[i] >= '0' && s[i] <= '9') == digit {
+		i++
+	}
+	return s[:i], s[i:]
+}
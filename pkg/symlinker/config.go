@@ -0,0 +1,298 @@
+package symlinker
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"maps"
+	"os"
+	"slices"
+	"strings"
+)
+
+// ParseConfig reads a config in the line-based format, as ReadLines splits it. Paths
+// are expanded with expand, or os.ExpandEnv if it is nil. Lines that can't be used are
+// left out and described in the warnings; err is only set when r can't be read.
+func ParseConfig(r io.Reader, expand func(string) string) (entries []Entry, warnings []string, err error) {
+	lines, err := ReadLines(r)
+	if err != nil {
+		return nil, nil, fmt.Errorf("error reading config file: %w", err)
+	}
+	for _, line := range lines {
+		lineEntries, lineWarnings := ParseLineAt(line.Text, line.Pos, expand)
+		warnings = append(warnings, lineWarnings...)
+		entries = append(entries, lineEntries...)
+	}
+	return entries, warnings, nil
+}
+
+// Position is where a line of a config starts
+type Position struct {
+	Line   int   // line number, from 1
+	Offset int64 // bytes from the start of the config; -1 if not known
+}
+
+// String describes the position for messages, such as "line 3 (byte 120)"
+func (p Position) String() string {
+	if p.Offset < 0 {
+		return fmt.Sprintf("line %d", p.Line)
+	}
+	return fmt.Sprintf("line %d (byte %d)", p.Line, p.Offset)
+}
+
+// Line is a line of a config, which continuations may have joined from several
+type Line struct {
+	Text string
+	Pos  Position // where its first line starts
+}
+
+// ReadLines splits a config into lines, however long. A UTF-8 byte order mark and the
+// line endings, \n or \r\n, are removed. A line that isn't a comment and ends in a
+// backslash after a space or tab continues on the next, as in a shell script; a
+// backslash right after a path, as in a Windows directory, doesn't continue.
+func ReadLines(r io.Reader) ([]Line, error) {
+	br := bufio.NewReader(r)
+	var lines []Line
+	var joined *Line
+	var offset int64
+	for number := 1; ; number++ {
+		text, err := br.ReadString('\n')
+		if err != nil && err != io.EOF {
+			return nil, err
+		}
+		if text == "" && err == io.EOF {
+			break
+		}
+		start := offset
+		offset += int64(len(text))
+		text = strings.TrimSuffix(strings.TrimSuffix(text, "\n"), "\r")
+		if number == 1 {
+			text = strings.TrimPrefix(text, "\ufeff")
+		}
+
+		if joined == nil {
+			joined = &Line{Pos: Position{Line: number, Offset: start}}
+		}
+		joined.Text += text
+		if continues(joined.Text) {
+			joined.Text = strings.TrimSuffix(joined.Text, "\\")
+		} else {
+			lines = append(lines, *joined)
+			joined = nil
+		}
+		if err == io.EOF {
+			break
+		}
+	}
+	// A continuation on the last line has nothing to join
+	if joined != nil {
+		lines = append(lines, *joined)
+	}
+	return lines, nil
+}
+
+// continues reports whether line ends in a continuation: a space or tab, then a backslash
+func continues(line string) bool {
+	if strings.HasPrefix(strings.TrimSpace(line), "#") {
+		return false
+	}
+	return strings.HasSuffix(line, " \\") || strings.HasSuffix(line, "\t\\")
+}
+
+// ParseLine parses one line of a config, reporting whether it holds an entry. Blank
+// lines and comments don't; lines that can't be used come with a warning. Options
+// are returned as written, without checking their names or values. A line whose braces
+// expand to several entries gives the first; ParseLineAt gives them all.
+func ParseLine(line string, lineNumber int, expand func(string) string) (e Entry, ok bool, warnings []string) {
+	entries, warnings := ParseLineAt(line, Position{Line: lineNumber, Offset: -1}, expand)
+	if len(entries) == 0 {
+		return Entry{}, false, warnings
+	}
+	return entries[0], true, warnings
+}
+
+// ParseLineAt parses one line of a config, at a position its warnings give, into the
+// entries it holds. A path outside quotes can hold {a,b} brace expansions, as in a
+// shell: $HOME/.{bashrc,zshrc} $DOTFILES/{bashrc,zshrc} pairs the link paths with the
+// actual paths in order, and a single actual path goes with every link path.
+func ParseLineAt(line string, pos Position, expand func(string) string) (entries []Entry, warnings []string) {
+	if expand == nil {
+		expand = os.ExpandEnv
+	}
+
+	// Skip empty lines and comments
+	if strings.TrimSpace(line) == "" || strings.HasPrefix(strings.TrimSpace(line), "#") {
+		return nil, nil
+	}
+
+	fields, quoted, err := SplitFieldsQuoted(line)
+	if err != nil {
+		return nil, []string{fmt.Sprintf("Invalid %s in config file (%s): %s", pos, err, line)}
+	}
+	if len(fields) < 2 {
+		return nil, []string{fmt.Sprintf("Invalid %s in config file: %s", pos, line)}
+	}
+
+	links, targets := []string{fields[0]}, []string{fields[1]}
+	if !quoted[0] {
+		links = ExpandBraces(fields[0])
+	}
+	if !quoted[1] {
+		targets = ExpandBraces(fields[1])
+	}
+	switch {
+	case len(targets) == len(links):
+	case len(targets) == 1:
+		targets = slices.Repeat(targets, len(links))
+	default:
+		return nil, []string{fmt.Sprintf("Braces at %s give %d link paths but %d actual paths: %s", pos, len(links), len(targets), line)}
+	}
+
+	var options map[string]string
+	for _, field := range fields[2:] {
+		key, value, found := strings.Cut(field, "=")
+		if !found {
+			warnings = append(warnings, fmt.Sprintf("Invalid option at %s (want option=value): %s", pos, field))
+			continue
+		}
+		if options == nil {
+			options = map[string]string{}
+		}
+		options[key] = value
+	}
+
+	for i := range links {
+		e := Entry{Line: pos.Line, RawLink: links[i], RawTarget: targets[i], Link: expand(links[i]), Target: expand(targets[i]), Options: maps.Clone(options)}
+		if e.Link == "" || e.Target == "" {
+			return nil, []string{fmt.Sprintf("Invalid paths at %s in config file: %s", pos, line)}
+		}
+		entries = append(entries, e)
+	}
+	return entries, warnings
+}
+
+// ExpandBraces expands the {a,b} alternations in a path, nested ones too, in order:
+// .{bash,zsh}rc gives .bashrc and .zshrc. Braces without a comma, and those of ${VAR}
+// and $(command), are left as they are.
+func ExpandBraces(path string) []string {
+	open, close, alts := braceGroup(path)
+	if open < 0 {
+		return []string{path}
+	}
+	suffixes := ExpandBraces(path[close+1:])
+	var paths []string
+	for _, alt := range alts {
+		for _, a := range ExpandBraces(alt) {
+			for _, suffix := range suffixes {
+				paths = append(paths, path[:open]+a+suffix)
+			}
+		}
+	}
+	return paths
+}
+
+// braceGroup finds the first {a,b} alternation in path, returning where its braces are
+// and the alternatives between them, or -1 if there is none
+func braceGroup(path string) (open, close int, alts []string) {
+	for i := 0; i < len(path); i++ {
+		if end := skipSubstitution(path, i); end > i {
+			i = end - 1
+			continue
+		}
+		if path[i] != '{' {
+			continue
+		}
+		depth, start := 0, i+1
+		var parts []string
+	group:
+		for j := i; j < len(path); j++ {
+			if end := skipSubstitution(path, j); end > j {
+				j = end - 1
+				continue
+			}
+			switch path[j] {
+			case '{':
+				depth++
+			case ',':
+				if depth == 1 {
+					parts = append(parts, path[start:j])
+					start = j + 1
+				}
+			case '}':
+				if depth--; depth == 0 {
+					if parts != nil {
+						return i, j, append(parts, path[start:j])
+					}
+					break group
+				}
+			}
+		}
+	}
+	return -1, -1, nil
+}
+
+// skipSubstitution returns where a ${VAR} or $(command) starting at i ends, or i if
+// none starts there
+func skipSubstitution(path string, i int) int {
+	if path[i] != '$' || i+1 == len(path) || (path[i+1] != '{' && path[i+1] != '(') {
+		return i
+	}
+	closer := "}"
+	if path[i+1] == '(' {
+		closer = ")"
+	}
+	if end := strings.Index(path[i+2:], closer); end >= 0 {
+		return i + 2 + end + 1
+	}
+	return len(path)
+}
+
+// SplitFields splits a config line at spaces and tabs outside double quotes and
+// $(command) substitutions. Quotes are removed, and within them a backslash escapes a
+// quote or backslash, so a field such as post="tmux source-file ~/.tmux.conf" holds spaces.
+func SplitFields(line string) ([]string, error) {
+	fields, _, err := SplitFieldsQuoted(line)
+	return fields, err
+}
+
+// SplitFieldsQuoted is SplitFields, also reporting which fields were written with
+// quotes, whose braces ParseLineAt leaves as they are
+func SplitFieldsQuoted(line string) (fields []string, quoted []bool, err error) {
+	var field strings.Builder
+	inField, inQuotes, subst, hadQuotes := false, false, false, false
+	for i := 0; i < len(line); i++ {
+		c := line[i]
+		switch {
+		case inQuotes && c == '\\' && i+1 < len(line) && (line[i+1] == '"' || line[i+1] == '\\'):
+			i++
+			field.WriteByte(line[i])
+		case c == '"' && !subst:
+			inQuotes, inField, hadQuotes = !inQuotes, true, true
+		case !inQuotes && c == '$' && strings.HasPrefix(line[i:], "$("):
+			subst, inField = true, true
+			field.WriteByte(c)
+		case subst && c == ')':
+			subst = false
+			field.WriteByte(c)
+		case !inQuotes && !subst && (c == ' ' || c == '\t'):
+			if inField {
+				fields, quoted = append(fields, field.String()), append(quoted, hadQuotes)
+				field.Reset()
+				inField, hadQuotes = false, false
+			}
+		default:
+			field.WriteByte(c)
+			inField = true
+		}
+	}
+	if inQuotes {
+		return nil, nil, fmt.Errorf("unterminated quote")
+	}
+	if subst {
+		return nil, nil, fmt.Errorf("unterminated $(")
+	}
+	if inField {
+		fields, quoted = append(fields, field.String()), append(quoted, hadQuotes)
+	}
+	return fields, quoted, nil
+}
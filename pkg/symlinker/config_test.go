@@ -0,0 +1,249 @@
+package symlinker
+
+import (
+	"fmt"
+	"os"
+	"reflect"
+	"slices"
+	"strings"
+	"testing"
+)
+
+func TestReadLines(t *testing.T) {
+	long := strings.Repeat("x", 1<<20)
+	tests := []struct {
+		name  string
+		input string
+		want  []Line
+	}{
+		{"empty", "", nil},
+		{"lf", "a b\nc d\n", []Line{{"a b", Position{1, 0}}, {"c d", Position{2, 4}}}},
+		{"crlf", "a b\r\nc d\r\n", []Line{{"a b", Position{1, 0}}, {"c d", Position{2, 5}}}},
+		{"no final newline", "a b\nc d", []Line{{"a b", Position{1, 0}}, {"c d", Position{2, 4}}}},
+		{"byte order mark", "\ufeffa b\n", []Line{{"a b", Position{1, 0}}}},
+		{"blank lines kept", "a b\n\n\nc d\n", []Line{{"a b", Position{1, 0}}, {"", Position{2, 4}}, {"", Position{3, 5}}, {"c d", Position{4, 6}}}},
+		{"continuation", "a \\\n  b opt=1\nc d\n", []Line{{"a   b opt=1", Position{1, 0}}, {"c d", Position{3, 14}}}},
+		{"continuation after tab", "a\t\\\nb\n", []Line{{"a\tb", Position{1, 0}}}},
+		{"continuation with crlf", "a \\\r\nb\r\n", []Line{{"a b", Position{1, 0}}}},
+		{"several continuations", "a \\\nb \\\nc\n", []Line{{"a b c", Position{1, 0}}}},
+		{"continuation on the last line", "a b \\", []Line{{"a b ", Position{1, 0}}}},
+		{"windows directory", `$HOME/x C:\dots\` + "\nc d\n", []Line{{`$HOME/x C:\dots\`, Position{1, 0}}, {"c d", Position{2, 17}}}},
+		{"comment doesn't continue", "# note \\\na b\n", []Line{{"# note \\", Position{1, 0}}, {"a b", Position{2, 9}}}},
+		{"long line", long + " y\n", []Line{{long + " y", Position{1, 0}}}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ReadLines(strings.NewReader(tt.input))
+			if err != nil {
+				t.Fatalf("ReadLines: %v", err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("ReadLines(%q) = %q, want %q", truncated(tt.input), got, tt.want)
+			}
+		})
+	}
+}
+
+// truncated shortens long test input for messages
+func truncated(s string) string {
+	if len(s) > 40 {
+		return s[:40] + "..."
+	}
+	return s
+}
+
+// testExpand expands $HOME and $DOTS to fixed values, leaving other variables empty
+func testExpand(s string) string {
+	return os.Expand(s, func(name string) string {
+		return map[string]string{"HOME": "/home/me", "DOTS": "/home/me/dotfiles"}[name]
+	})
+}
+
+func TestParseLineAt(t *testing.T) {
+	type link struct{ raw, link, target string }
+	tests := []struct {
+		name     string
+		line     string
+		want     []link
+		options  map[string]string
+		warnings []string
+	}{
+		{name: "blank", line: "   "},
+		{name: "comment", line: "  # $HOME/x $DOTS/x"},
+		{name: "plain", line: "$HOME/.zshrc $DOTS/zshrc", want: []link{{"$HOME/.zshrc", "/home/me/.zshrc", "/home/me/dotfiles/zshrc"}}},
+		{name: "tabs", line: "\t$HOME/.zshrc\t\t$DOTS/zshrc\t", want: []link{{"$HOME/.zshrc", "/home/me/.zshrc", "/home/me/dotfiles/zshrc"}}},
+		{
+			name:    "options",
+			line:    `$HOME/.tmux.conf $DOTS/tmux.conf on-conflict=skip post="tmux source-file ~/.tmux.conf"`,
+			want:    []link{{"$HOME/.tmux.conf", "/home/me/.tmux.conf", "/home/me/dotfiles/tmux.conf"}},
+			options: map[string]string{"on-conflict": "skip", "post": "tmux source-file ~/.tmux.conf"},
+		},
+		{name: "quoted spaces", line: `"$HOME/My Documents" "$DOTS/my \"docs\""`, want: []link{{"$HOME/My Documents", "/home/me/My Documents", `/home/me/dotfiles/my "docs"`}}},
+		{
+			name: "braces pair up",
+			line: "$HOME/.{bash,zsh}rc $DOTS/{bash,zsh}rc",
+			want: []link{{"$HOME/.bashrc", "/home/me/.bashrc", "/home/me/dotfiles/bashrc"}, {"$HOME/.zshrc", "/home/me/.zshrc", "/home/me/dotfiles/zshrc"}},
+		},
+		{
+			name: "braces share one actual path",
+			line: "$HOME/.{bash,zsh}rc $DOTS/shellrc",
+			want: []link{{"$HOME/.bashrc", "/home/me/.bashrc", "/home/me/dotfiles/shellrc"}, {"$HOME/.zshrc", "/home/me/.zshrc", "/home/me/dotfiles/shellrc"}},
+		},
+		{name: "quoted braces", line: `"$HOME/{a,b}" $DOTS/x`, want: []link{{"$HOME/{a,b}", "/home/me/{a,b}", "/home/me/dotfiles/x"}}},
+		{name: "variable braces", line: "${HOME}/.x $DOTS/x", want: []link{{"${HOME}/.x", "/home/me/.x", "/home/me/dotfiles/x"}}},
+		{name: "one field", line: "$HOME/.zshrc", warnings: []string{"Invalid line 3 (byte 40) in config file: $HOME/.zshrc"}},
+		{name: "unterminated quote", line: `"$HOME/x $DOTS/x`, warnings: []string{`Invalid line 3 (byte 40) in config file (unterminated quote): "$HOME/x $DOTS/x`}},
+		{name: "empty path", line: "$UNSET $DOTS/x", warnings: []string{"Invalid paths at line 3 (byte 40) in config file: $UNSET $DOTS/x"}},
+		{
+			name:     "braces don't pair up",
+			line:     "$HOME/{a,b,c} $DOTS/{a,b}",
+			warnings: []string{"Braces at line 3 (byte 40) give 3 link paths but 2 actual paths: $HOME/{a,b,c} $DOTS/{a,b}"},
+		},
+		{
+			name:     "invalid option",
+			line:     "$HOME/x $DOTS/x relative",
+			want:     []link{{"$HOME/x", "/home/me/x", "/home/me/dotfiles/x"}},
+			warnings: []string{"Invalid option at line 3 (byte 40) (want option=value): relative"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			entries, warnings := ParseLineAt(tt.line, Position{Line: 3, Offset: 40}, testExpand)
+			var got []link
+			for _, e := range entries {
+				if e.Line != 3 {
+					t.Errorf("entry %s has line %d, want 3", e.RawLink, e.Line)
+				}
+				if !sameOptions(e.Options, tt.options) {
+					t.Errorf("entry %s has options %v, want %v", e.RawLink, e.Options, tt.options)
+				}
+				got = append(got, link{e.RawLink, e.Link, e.Target})
+			}
+			if !slices.Equal(got, tt.want) {
+				t.Errorf("entries = %q, want %q", got, tt.want)
+			}
+			if !slices.Equal(warnings, tt.warnings) {
+				t.Errorf("warnings = %q, want %q", warnings, tt.warnings)
+			}
+		})
+	}
+}
+
+// sameOptions reports whether two option maps hold the same options, nil matching empty
+func sameOptions(a, b map[string]string) bool {
+	return len(a) == len(b) && (len(a) == 0 || reflect.DeepEqual(a, b))
+}
+
+func TestParseLineAtEntriesDontShareOptions(t *testing.T) {
+	entries, _ := ParseLineAt("$HOME/{a,b} $DOTS/x relative=true", Position{Line: 1}, testExpand)
+	if len(entries) != 2 {
+		t.Fatalf("got %d entries, want 2", len(entries))
+	}
+	entries[0].Options["relative"] = "false"
+	if entries[1].Options["relative"] != "true" {
+		t.Errorf("changing one entry's options changed another's")
+	}
+}
+
+func TestParseConfig(t *testing.T) {
+	config := "# dotfiles\r\n" +
+		"$HOME/.zshrc $DOTS/zshrc\r\n" +
+		"\r\n" +
+		"$HOME/.config/{nvim,helix} \\\r\n" +
+		"  $DOTS/{nvim,helix} on-conflict=skip\r\n" +
+		"broken\r\n"
+	entries, warnings, err := ParseConfig(strings.NewReader(config), testExpand)
+	if err != nil {
+		t.Fatalf("ParseConfig: %v", err)
+	}
+	var got []string
+	for _, e := range entries {
+		got = append(got, fmt.Sprintf("%s %s %s %d", e.Link, e.Target, e.Options["on-conflict"], e.Line))
+	}
+	want := []string{
+		"/home/me/.zshrc /home/me/dotfiles/zshrc  2",
+		"/home/me/.config/nvim /home/me/dotfiles/nvim skip 4",
+		"/home/me/.config/helix /home/me/dotfiles/helix skip 4",
+	}
+	if !slices.Equal(got, want) {
+		t.Errorf("entries = %q, want %q", got, want)
+	}
+	if want := []string{"Invalid line 6 (byte 109) in config file: broken"}; !slices.Equal(warnings, want) {
+		t.Errorf("warnings = %q, want %q", warnings, want)
+	}
+}
+
+func TestParseLine(t *testing.T) {
+	e, ok, warnings := ParseLine("$HOME/.{bash,zsh}rc $DOTS/shellrc", 7, testExpand)
+	if !ok || e.Link != "/home/me/.bashrc" || e.Line != 7 || warnings != nil {
+		t.Errorf("ParseLine = %+v, %v, %q; want the first of the braces' entries", e, ok, warnings)
+	}
+	if _, ok, warnings := ParseLine("$HOME/x", 7, testExpand); ok || !slices.Equal(warnings, []string{"Invalid line 7 in config file: $HOME/x"}) {
+		t.Errorf("ParseLine of one field = %v, %q; want no entry and a warning without a byte offset", ok, warnings)
+	}
+}
+
+func TestExpandBraces(t *testing.T) {
+	tests := []struct {
+		path string
+		want []string
+	}{
+		{"plain", []string{"plain"}},
+		{".{bash,zsh}rc", []string{".bashrc", ".zshrc"}},
+		{"{a,b}/{c,d}", []string{"a/c", "a/d", "b/c", "b/d"}},
+		{"x{a,b{c,d}}y", []string{"xay", "xbcy", "xbdy"}},
+		{"{,.bak}", []string{"", ".bak"}},
+		{"{single}", []string{"{single}"}},
+		{"{unclosed,x", []string{"{unclosed,x"}},
+		{"${HOME}/{a,b}", []string{"${HOME}/a", "${HOME}/b"}},
+		{"${A,B}", []string{"${A,B}"}},
+		{"$(echo {a,b})", []string{"$(echo {a,b})"}},
+		{"$(echo x)/{a,b}", []string{"$(echo x)/a", "$(echo x)/b"}},
+	}
+	for _, tt := range tests {
+		if got := ExpandBraces(tt.path); !slices.Equal(got, tt.want) {
+			t.Errorf("ExpandBraces(%q) = %q, want %q", tt.path, got, tt.want)
+		}
+	}
+}
+
+func TestSplitFieldsQuoted(t *testing.T) {
+	tests := []struct {
+		line   string
+		fields []string
+		quoted []bool
+		err    string
+	}{
+		{line: "", fields: nil, quoted: nil},
+		{line: "a b", fields: []string{"a", "b"}, quoted: []bool{false, false}},
+		{line: "  a \t b  ", fields: []string{"a", "b"}, quoted: []bool{false, false}},
+		{line: `"a b" c`, fields: []string{"a b", "c"}, quoted: []bool{true, false}},
+		{line: `pre"fix x"post`, fields: []string{"prefix xpost"}, quoted: []bool{true}},
+		{line: `"" x`, fields: []string{"", "x"}, quoted: []bool{true, false}},
+		{line: `"a \"b\" \\c" d\e`, fields: []string{`a "b" \c`, `d\e`}, quoted: []bool{true, false}},
+		{line: `C:\Users\me x`, fields: []string{`C:\Users\me`, "x"}, quoted: []bool{false, false}},
+		{line: `$(printf "%s" "a b")/x y`, fields: []string{`$(printf "%s" "a b")/x`, "y"}, quoted: []bool{false, false}},
+		{line: `post="git -C $(pwd) pull" x`, fields: []string{"post=git -C $(pwd) pull", "x"}, quoted: []bool{true, false}},
+		{line: `"a b`, err: "unterminated quote"},
+		{line: `$(echo a b`, err: "unterminated $("},
+	}
+	for _, tt := range tests {
+		fields, quoted, err := SplitFieldsQuoted(tt.line)
+		if tt.err != "" {
+			if err == nil || err.Error() != tt.err {
+				t.Errorf("SplitFieldsQuoted(%q) error = %v, want %q", tt.line, err, tt.err)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("SplitFieldsQuoted(%q): %v", tt.line, err)
+			continue
+		}
+		if !slices.Equal(fields, tt.fields) || !slices.Equal(quoted, tt.quoted) {
+			t.Errorf("SplitFieldsQuoted(%q) = %q, %v; want %q, %v", tt.line, fields, quoted, tt.fields, tt.quoted)
+		}
+		if plain, _ := SplitFields(tt.line); !slices.Equal(plain, tt.fields) {
+			t.Errorf("SplitFields(%q) = %q, want %q", tt.line, plain, tt.fields)
+		}
+	}
+}
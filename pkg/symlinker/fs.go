@@ -0,0 +1,255 @@
+package symlinker
+
+import (
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// FS is the filesystem Plan and Apply work on. Paths are absolute, in the host's syntax.
+type FS interface {
+	Lstat(name string) (fs.FileInfo, error)
+	Stat(name string) (fs.FileInfo, error)
+	Readlink(name string) (string, error)
+	Symlink(target, name string) error
+	MkdirAll(path string, perm fs.FileMode) error
+	RemoveAll(path string) error
+}
+
+// OSFS is the real filesystem
+type OSFS struct{}
+
+func (OSFS) Lstat(name string) (fs.FileInfo, error)       { return os.Lstat(name) }
+func (OSFS) Stat(name string) (fs.FileInfo, error)        { return os.Stat(name) }
+func (OSFS) Readlink(name string) (string, error)         { return os.Readlink(name) }
+func (OSFS) Symlink(target, name string) error            { return os.Symlink(target, name) }
+func (OSFS) MkdirAll(path string, perm fs.FileMode) error { return os.MkdirAll(path, perm) }
+func (OSFS) RemoveAll(path string) error                  { return os.RemoveAll(path) }
+
+// MemFS is an in-memory filesystem for tests. The zero value holds just the root
+// directory; it is safe for concurrent use.
+type MemFS struct {
+	mu    sync.Mutex
+	nodes map[string]*memNode
+}
+
+// memNode is a file, directory or symlink in a MemFS
+type memNode struct {
+	mode   fs.FileMode
+	data   []byte
+	target string // for symlinks
+}
+
+// maxLinkDepth bounds how many symlinks MemFS follows, like the kernel's ELOOP limit
+const maxLinkDepth = 40
+
+// WriteFile creates or replaces a regular file, as os.WriteFile does. The parent
+// directory must exist.
+func (m *MemFS) WriteFile(name string, data []byte, perm fs.FileMode) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	name = filepath.Clean(name)
+	if err := m.checkParent("open", name); err != nil {
+		return err
+	}
+	if n, ok := m.nodes[name]; ok && n.mode.IsDir() {
+		return &fs.PathError{Op: "open", Path: name, Err: fs.ErrExist}
+	}
+	m.init()
+	m.nodes[name] = &memNode{mode: perm.Perm(), data: append([]byte(nil), data...)}
+	return nil
+}
+
+// Paths lists every path in the filesystem, sorted, for comparing with what a test expects
+func (m *MemFS) Paths() []string {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.init()
+	paths := make([]string, 0, len(m.nodes))
+	for name := range m.nodes {
+		paths = append(paths, name)
+	}
+	sort.Strings(paths)
+	return paths
+}
+
+func (m *MemFS) Lstat(name string) (fs.FileInfo, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	name = filepath.Clean(name)
+	n, err := m.lookup("lstat", name)
+	if err != nil {
+		return nil, err
+	}
+	return memInfo{name: filepath.Base(name), node: n}, nil
+}
+
+func (m *MemFS) Stat(name string) (fs.FileInfo, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	resolved, err := m.resolve("stat", filepath.Clean(name))
+	if err != nil {
+		return nil, err
+	}
+	return memInfo{name: filepath.Base(name), node: m.nodes[resolved]}, nil
+}
+
+func (m *MemFS) Readlink(name string) (string, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	name = filepath.Clean(name)
+	n, err := m.lookup("readlink", name)
+	if err != nil {
+		return "", err
+	}
+	if n.mode&fs.ModeSymlink == 0 {
+		return "", &fs.PathError{Op: "readlink", Path: name, Err: fs.ErrInvalid}
+	}
+	return n.target, nil
+}
+
+func (m *MemFS) Symlink(target, name string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	name = filepath.Clean(name)
+	if err := m.checkParent("symlink", name); err != nil {
+		return err
+	}
+	if _, ok := m.nodes[name]; ok {
+		return &fs.PathError{Op: "symlink", Path: name, Err: fs.ErrExist}
+	}
+	m.init()
+	m.nodes[name] = &memNode{mode: fs.ModeSymlink | 0777, target: target}
+	return nil
+}
+
+func (m *MemFS) MkdirAll(path string, perm fs.FileMode) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.mkdirAll(filepath.Clean(path), perm)
+}
+
+func (m *MemFS) RemoveAll(path string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	path = filepath.Clean(path)
+	if path == filepath.Dir(path) {
+		return &fs.PathError{Op: "removeall", Path: path, Err: fs.ErrInvalid}
+	}
+	prefix := path + string(filepath.Separator)
+	for name := range m.nodes {
+		if name == path || strings.HasPrefix(name, prefix) {
+			delete(m.nodes, name)
+		}
+	}
+	return nil
+}
+
+// mkdirAll creates path and any missing parents
+func (m *MemFS) mkdirAll(path string, perm fs.FileMode) error {
+	if resolved, err := m.resolve("mkdir", path); err == nil {
+		if !m.nodes[resolved].mode.IsDir() {
+			return &fs.PathError{Op: "mkdir", Path: path, Err: errNotDir}
+		}
+		return nil
+	}
+	if err := m.mkdirAll(filepath.Dir(path), perm); err != nil {
+		return err
+	}
+	dir, err := m.resolve("mkdir", filepath.Dir(path))
+	if err != nil {
+		return err
+	}
+	m.nodes[filepath.Join(dir, filepath.Base(path))] = &memNode{mode: fs.ModeDir | perm.Perm()}
+	return nil
+}
+
+// init creates the root directory of a zero MemFS
+func (m *MemFS) init() {
+	if m.nodes == nil {
+		root := filepath.VolumeName(os.TempDir()) + string(filepath.Separator)
+		m.nodes = map[string]*memNode{root: {mode: fs.ModeDir | 0755}}
+	}
+}
+
+// lookup returns the node at name, following symlinks in its directories but not in name itself
+func (m *MemFS) lookup(op, name string) (*memNode, error) {
+	m.init()
+	dir, err := m.resolve(op, filepath.Dir(name))
+	if err != nil {
+		return nil, err
+	}
+	n, ok := m.nodes[filepath.Join(dir, filepath.Base(name))]
+	if !ok {
+		return nil, &fs.PathError{Op: op, Path: name, Err: fs.ErrNotExist}
+	}
+	return n, nil
+}
+
+// resolve follows symlinks in name and returns the path of the node it refers to
+func (m *MemFS) resolve(op, name string) (string, error) {
+	m.init()
+	for depth := 0; depth < maxLinkDepth; depth++ {
+		dir := filepath.Dir(name)
+		if dir != name {
+			var err error
+			if dir, err = m.resolve(op, dir); err != nil {
+				return "", err
+			}
+			name = filepath.Join(dir, filepath.Base(name))
+		}
+		n, ok := m.nodes[name]
+		if !ok {
+			return "", &fs.PathError{Op: op, Path: name, Err: fs.ErrNotExist}
+		}
+		if n.mode&fs.ModeSymlink == 0 {
+			return name, nil
+		}
+		if filepath.IsAbs(n.target) {
+			name = filepath.Clean(n.target)
+		} else {
+			name = filepath.Join(filepath.Dir(name), n.target)
+		}
+	}
+	return "", &fs.PathError{Op: op, Path: name, Err: errTooManyLinks}
+}
+
+// checkParent reports why name can't be created: its directory is missing or not a directory
+func (m *MemFS) checkParent(op, name string) error {
+	m.init()
+	dir, err := m.resolve(op, filepath.Dir(name))
+	if err != nil {
+		return err
+	}
+	if !m.nodes[dir].mode.IsDir() {
+		return &fs.PathError{Op: op, Path: name, Err: errNotDir}
+	}
+	return nil
+}
+
+// memErr is an error MemFS returns where the os package would return a syscall error
+type memErr string
+
+func (e memErr) Error() string { return string(e) }
+
+const (
+	errTooManyLinks memErr = "too many levels of symbolic links"
+	errNotDir       memErr = "not a directory"
+)
+
+// memInfo describes a MemFS node
+type memInfo struct {
+	name string
+	node *memNode
+}
+
+func (i memInfo) Name() string       { return i.name }
+func (i memInfo) Size() int64        { return int64(len(i.node.data)) }
+func (i memInfo) Mode() fs.FileMode  { return i.node.mode }
+func (i memInfo) ModTime() time.Time { return time.Time{} }
+func (i memInfo) IsDir() bool        { return i.node.mode.IsDir() }
+func (i memInfo) Sys() any           { return nil }
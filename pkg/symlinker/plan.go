@@ -0,0 +1,124 @@
+package symlinker
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"path/filepath"
+)
+
+// Options controls Plan and Apply
+type Options struct {
+	// OnConflict is what to do when something other than the link is at a link path:
+	// ConflictOverwrite (the default) or ConflictSkip. The on-conflict entry option
+	// overrides it.
+	OnConflict string
+
+	// Force lets Apply remove files and directories in the way; without it, only
+	// symlinks are replaced and anything else is an error
+	Force bool
+
+	// DirPerm is the permission of the directories created for links (default 0755)
+	DirPerm fs.FileMode
+}
+
+// ErrConflict is returned by Apply for a file or directory in the way without Options.Force
+var ErrConflict = errors.New("refusing to remove a file or directory in the way")
+
+// Plan works out what applying entries would do, without changing anything
+func Plan(fsys FS, entries []Entry, opts Options) ([]Action, error) {
+	actions := make([]Action, 0, len(entries))
+	for _, e := range entries {
+		act, err := planEntry(fsys, e, opts)
+		if err != nil {
+			return actions, err
+		}
+		actions = append(actions, act)
+	}
+	return actions, nil
+}
+
+// planEntry works out what applying one entry would do
+func planEntry(fsys FS, e Entry, opts Options) (Action, error) {
+	act := Action{Entry: e, Kind: ActionCreate}
+	info, err := fsys.Lstat(e.Link)
+	if errors.Is(err, fs.ErrNotExist) {
+		return act, nil
+	}
+	if err != nil {
+		return act, fmt.Errorf("error reading %s: %w", e.Link, err)
+	}
+
+	act.Existing = kind(info)
+	if act.Existing == "symlink" {
+		if act.Previous, err = fsys.Readlink(e.Link); err != nil {
+			return act, fmt.Errorf("error reading %s: %w", e.Link, err)
+		}
+		if act.Previous == e.Target {
+			act.Kind, act.Existing, act.Previous = ActionUnchanged, "", ""
+			return act, nil
+		}
+	}
+
+	policy := opts.OnConflict
+	if p := e.Options["on-conflict"]; p != "" {
+		policy = p
+	}
+	switch policy {
+	case "", ConflictOverwrite:
+		act.Kind = ActionReplace
+	case ConflictSkip:
+		act.Kind = ActionSkip
+	default:
+		return act, fmt.Errorf("invalid conflict policy %q at line %d (want overwrite or skip)", policy, e.Line)
+	}
+	return act, nil
+}
+
+// Apply makes the changes in actions, as returned by Plan, stopping at the first one
+// that fails. Without Options.Force, nothing is changed if a file or directory is in the way.
+func Apply(fsys FS, actions []Action, opts Options) error {
+	if !opts.Force {
+		for _, act := range actions {
+			if act.Kind == ActionReplace && act.Existing != "symlink" {
+				return fmt.Errorf("%s at line %d: %w", act.Entry.Link, act.Entry.Line, ErrConflict)
+			}
+		}
+	}
+
+	perm := opts.DirPerm
+	if perm == 0 {
+		perm = 0755
+	}
+	for _, act := range actions {
+		e := act.Entry
+		switch act.Kind {
+		case ActionCreate:
+		case ActionReplace:
+			if err := fsys.RemoveAll(e.Link); err != nil {
+				return fmt.Errorf("error removing existing path: %w", err)
+			}
+		default:
+			continue
+		}
+		if err := fsys.MkdirAll(filepath.Dir(e.Link), perm); err != nil {
+			return fmt.Errorf("error creating directory %s: %w", filepath.Dir(e.Link), err)
+		}
+		if err := fsys.Symlink(e.Target, e.Link); err != nil {
+			return fmt.Errorf("error creating symlink at line %d: %w", e.Line, err)
+		}
+	}
+	return nil
+}
+
+// kind names what a path holds: symlink, dir or file
+func kind(info fs.FileInfo) string {
+	switch {
+	case info.Mode()&fs.ModeSymlink != 0:
+		return "symlink"
+	case info.IsDir():
+		return "dir"
+	default:
+		return "file"
+	}
+}
@@ -0,0 +1,259 @@
+package symlinker
+
+import (
+	"errors"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"reflect"
+	"slices"
+	"strings"
+	"testing"
+)
+
+// p turns a slash path into an absolute one in the host's syntax, under MemFS's root
+func p(s string) string {
+	return filepath.Join(filepath.VolumeName(os.TempDir())+string(filepath.Separator), filepath.FromSlash(s))
+}
+
+// newTestFS returns a MemFS holding a dotfiles directory, a file, a directory and two
+// symlinks, for planning against
+func newTestFS(t *testing.T) *MemFS {
+	t.Helper()
+	m := &MemFS{}
+	for _, dir := range []string{"/dots", "/home/dir"} {
+		if err := m.MkdirAll(p(dir), 0755); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for _, file := range []string{"/dots/a", "/dots/b", "/home/file"} {
+		if err := m.WriteFile(p(file), []byte("x"), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := m.Symlink(p("/dots/a"), p("/home/same")); err != nil {
+		t.Fatal(err)
+	}
+	if err := m.Symlink(p("/dots/old"), p("/home/other")); err != nil {
+		t.Fatal(err)
+	}
+	return m
+}
+
+// entry is an entry linking link to target, with options given as key=value
+func entry(line int, link, target string, options ...string) Entry {
+	e := Entry{Line: line, Link: p(link), Target: p(target), Options: map[string]string{}}
+	for _, o := range options {
+		k, v, _ := strings.Cut(o, "=")
+		e.Options[k] = v
+	}
+	return e
+}
+
+func TestPlan(t *testing.T) {
+	tests := []struct {
+		name     string
+		entry    Entry
+		opts     Options
+		kind     ActionKind
+		existing string
+		previous string
+	}{
+		{"nothing there", entry(1, "/home/new", "/dots/a"), Options{}, ActionCreate, "", ""},
+		{"missing parent", entry(1, "/home/x/y/new", "/dots/a"), Options{}, ActionCreate, "", ""},
+		{"linked already", entry(1, "/home/same", "/dots/a"), Options{}, ActionUnchanged, "", ""},
+		{"other symlink", entry(1, "/home/other", "/dots/a"), Options{}, ActionReplace, "symlink", p("/dots/old")},
+		{"file", entry(1, "/home/file", "/dots/a"), Options{}, ActionReplace, "file", ""},
+		{"directory", entry(1, "/home/dir", "/dots/a"), Options{}, ActionReplace, "dir", ""},
+		{"explicit overwrite", entry(1, "/home/file", "/dots/a"), Options{OnConflict: ConflictOverwrite}, ActionReplace, "file", ""},
+		{"skip by options", entry(1, "/home/file", "/dots/a"), Options{OnConflict: ConflictSkip}, ActionSkip, "file", ""},
+		{"skip by entry", entry(1, "/home/other", "/dots/a", "on-conflict=skip"), Options{}, ActionSkip, "symlink", p("/dots/old")},
+		{"entry overrides options", entry(1, "/home/file", "/dots/a", "on-conflict=overwrite"), Options{OnConflict: ConflictSkip}, ActionReplace, "file", ""},
+		{"skip leaves unchanged alone", entry(1, "/home/same", "/dots/a"), Options{OnConflict: ConflictSkip}, ActionUnchanged, "", ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m := newTestFS(t)
+			before := m.Paths()
+			actions, err := Plan(m, []Entry{tt.entry}, tt.opts)
+			if err != nil {
+				t.Fatalf("Plan: %v", err)
+			}
+			if len(actions) != 1 {
+				t.Fatalf("got %d actions, want 1", len(actions))
+			}
+			got := actions[0]
+			if got.Kind != tt.kind || got.Existing != tt.existing || got.Previous != tt.previous {
+				t.Errorf("got {%s %q %q}, want {%s %q %q}", got.Kind, got.Existing, got.Previous, tt.kind, tt.existing, tt.previous)
+			}
+			if !reflect.DeepEqual(got.Entry, tt.entry) {
+				t.Errorf("got entry %+v, want %+v", got.Entry, tt.entry)
+			}
+			if after := m.Paths(); !slices.Equal(after, before) {
+				t.Errorf("Plan changed the filesystem: %v, was %v", after, before)
+			}
+		})
+	}
+}
+
+func TestPlanInvalidPolicy(t *testing.T) {
+	m := newTestFS(t)
+	entries := []Entry{entry(1, "/home/new", "/dots/a"), entry(4, "/home/file", "/dots/b", "on-conflict=ask")}
+	actions, err := Plan(m, entries, Options{})
+	want := `invalid conflict policy "ask" at line 4 (want overwrite or skip)`
+	if err == nil || err.Error() != want {
+		t.Fatalf("Plan error = %v, want %s", err, want)
+	}
+	if len(actions) != 1 || actions[0].Kind != ActionCreate {
+		t.Errorf("got actions %+v, want only the create before the bad entry", actions)
+	}
+
+	// A bad policy only matters where there is a conflict
+	if _, err := Plan(m, []Entry{entry(1, "/home/new", "/dots/a", "on-conflict=ask")}, Options{}); err != nil {
+		t.Errorf("Plan without a conflict: %v", err)
+	}
+	if _, err := Plan(m, []Entry{entry(1, "/home/file", "/dots/a")}, Options{OnConflict: "ask"}); err == nil {
+		t.Error("Plan with an invalid Options.OnConflict succeeded")
+	}
+}
+
+func TestApply(t *testing.T) {
+	tests := []struct {
+		name    string
+		entries []Entry
+		opts    Options
+		links   map[string]string // link path to target, after applying
+		gone    []string          // paths that must no longer exist
+		err     error
+	}{
+		{
+			name:    "create",
+			entries: []Entry{entry(1, "/home/new", "/dots/a")},
+			links:   map[string]string{"/home/new": "/dots/a"},
+		},
+		{
+			name:    "create parents",
+			entries: []Entry{entry(1, "/home/x/y/new", "/dots/a")},
+			links:   map[string]string{"/home/x/y/new": "/dots/a"},
+		},
+		{
+			name:    "replace symlink without force",
+			entries: []Entry{entry(1, "/home/other", "/dots/b")},
+			links:   map[string]string{"/home/other": "/dots/b"},
+		},
+		{
+			name:    "unchanged",
+			entries: []Entry{entry(1, "/home/same", "/dots/a")},
+			links:   map[string]string{"/home/same": "/dots/a"},
+		},
+		{
+			name:    "skip",
+			entries: []Entry{entry(1, "/home/other", "/dots/b", "on-conflict=skip"), entry(2, "/home/file", "/dots/b", "on-conflict=skip")},
+			links:   map[string]string{"/home/other": "/dots/old"},
+		},
+		{
+			name:    "replace file and directory with force",
+			entries: []Entry{entry(1, "/home/file", "/dots/a"), entry(2, "/home/dir", "/dots/b")},
+			opts:    Options{Force: true},
+			links:   map[string]string{"/home/file": "/dots/a", "/home/dir": "/dots/b"},
+		},
+		{
+			name:    "file in the way",
+			entries: []Entry{entry(1, "/home/new", "/dots/a"), entry(2, "/home/file", "/dots/b")},
+			gone:    []string{"/home/new"},
+			err:     ErrConflict,
+		},
+		{
+			name:    "directory in the way",
+			entries: []Entry{entry(3, "/home/dir", "/dots/b")},
+			err:     ErrConflict,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m := newTestFS(t)
+			before := m.Paths()
+			actions, err := Plan(m, tt.entries, tt.opts)
+			if err != nil {
+				t.Fatalf("Plan: %v", err)
+			}
+			err = Apply(m, actions, tt.opts)
+			if !errors.Is(err, tt.err) {
+				t.Fatalf("Apply error = %v, want %v", err, tt.err)
+			}
+			if tt.err != nil {
+				if after := m.Paths(); !slices.Equal(after, before) {
+					t.Errorf("failed Apply changed the filesystem: %v, was %v", after, before)
+				}
+			}
+			for link, target := range tt.links {
+				got, err := m.Readlink(p(link))
+				if err != nil || got != p(target) {
+					t.Errorf("%s links to %q (%v), want %q", link, got, err, p(target))
+				}
+			}
+			for _, path := range tt.gone {
+				if _, err := m.Lstat(p(path)); !errors.Is(err, fs.ErrNotExist) {
+					t.Errorf("%s exists (%v), want it gone", path, err)
+				}
+			}
+		})
+	}
+}
+
+func TestApplyConflictError(t *testing.T) {
+	m := newTestFS(t)
+	actions, err := Plan(m, []Entry{entry(7, "/home/file", "/dots/a")}, Options{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = Apply(m, actions, Options{})
+	want := p("/home/file") + " at line 7: " + ErrConflict.Error()
+	if err == nil || err.Error() != want {
+		t.Errorf("Apply error = %v, want %s", err, want)
+	}
+}
+
+func TestApplyKeepsSkippedContents(t *testing.T) {
+	m := newTestFS(t)
+	if err := m.WriteFile(p("/home/dir/inner"), []byte("kept"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	actions, err := Plan(m, []Entry{entry(1, "/home/dir", "/dots/a")}, Options{OnConflict: ConflictSkip})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := Apply(m, actions, Options{Force: true}); err != nil {
+		t.Fatalf("Apply: %v", err)
+	}
+	if info, err := m.Lstat(p("/home/dir/inner")); err != nil || !info.Mode().IsRegular() {
+		t.Errorf("skipped directory lost its contents: %v", err)
+	}
+}
+
+func TestApplyDirPerm(t *testing.T) {
+	tests := []struct {
+		perm fs.FileMode
+		want fs.FileMode
+	}{
+		{0, 0755},
+		{0700, 0700},
+	}
+	for _, tt := range tests {
+		m := newTestFS(t)
+		actions, err := Plan(m, []Entry{entry(1, "/home/x/new", "/dots/a")}, Options{})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := Apply(m, actions, Options{DirPerm: tt.perm}); err != nil {
+			t.Fatalf("Apply: %v", err)
+		}
+		info, err := m.Lstat(p("/home/x"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := info.Mode().Perm(); got != tt.want {
+			t.Errorf("DirPerm %v: made %s with %v, want %v", tt.perm, p("/home/x"), got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,51 @@
+// Package symlinker parses symlinker configs and creates the links they describe, for
+// programs that manage dotfiles themselves rather than by running the symlinker command.
+//
+// Configs list one link per line:
+//
+//	<link_path> <actual_path> [option=value ...]
+//
+// ParseConfig reads them, Plan works out what applying the entries would change, and
+// Apply makes those changes. Both go through an FS, so they can run against the real
+// filesystem (OSFS) or an in-memory one (MemFS) in tests.
+//
+// The package covers plain symlinks with the on-conflict option. The symlinker command
+// inspects link paths and makes its plain symlinks through Plan and Apply too; backups,
+// the state manifest, undo, link modes other than symlinks and the rest of its features
+// stay with the command.
+package symlinker
+
+// Entry is one link from a config
+type Entry struct {
+	Line      int    // line number in the config; 0 for entries made in code
+	RawLink   string // link path as written, before expansion
+	RawTarget string // actual path as written, before expansion
+	Link      string // where the symlink goes
+	Target    string // what it points to
+	Options   map[string]string
+}
+
+// ActionKind is what applying an entry does
+type ActionKind string
+
+// Action kinds, with the same names as the command's JSON report
+const (
+	ActionCreate    ActionKind = "create"    // nothing is at the link path yet
+	ActionReplace   ActionKind = "replace"   // something else is at the link path and is removed
+	ActionUnchanged ActionKind = "unchanged" // the link path already links to the target
+	ActionSkip      ActionKind = "skip"      // something else is at the link path and is kept
+)
+
+// Action is the change applying an entry makes
+type Action struct {
+	Entry    Entry
+	Kind     ActionKind
+	Existing string // with ActionReplace and ActionSkip: symlink, file or dir
+	Previous string // the target of a symlink that is replaced or kept
+}
+
+// Conflict policies, for Options.OnConflict and the on-conflict entry option
+const (
+	ConflictOverwrite = "overwrite"
+	ConflictSkip      = "skip"
+)
@@ -0,0 +1,142 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+	"os/exec"
+)
+
+// pluginPrefix starts the name of the program that handles entries of a plugin mode:
+// mode=git runs symlinker-git
+const pluginPrefix = "symlinker-"
+
+// pluginVersion is the version of the plugin protocol sent in every request
+const pluginVersion = 1
+
+// Plugin operations
+const (
+	pluginCheck = "check" // report whether the entry is in place, changing nothing
+	pluginApply = "apply" // put the entry in place
+)
+
+// pluginRequest is the JSON a plugin reads on stdin
+type pluginRequest struct {
+	Version int               `json:"version"`
+	Op      string            `json:"op"`
+	Link    string            `json:"link"`
+	Target  string            `json:"target"`
+	Options map[string]string `json:"options,omitempty"`
+	Config  string            `json:"config,omitempty"`
+	Line    int               `json:"line,omitempty"`
+}
+
+// pluginResponse is the JSON a plugin writes on stdout
+type pluginResponse struct {
+	InPlace bool   `json:"in_place"`          // check: nothing needs doing
+	Changed bool   `json:"changed"`           // apply: something was changed
+	Message string `json:"message,omitempty"` // check: what apply would do; apply: what it did
+	Error   string `json:"error,omitempty"`
+}
+
+// isPluginMode reports whether a mode is handled by a plugin rather than symlinker itself
+func isPluginMode(mode string) bool {
+	switch mode {
+	case "", modeSymlink, modeHardlink, modeCopy, modeJunction, modeDecrypt, modeTemplate:
+		return false
+	}
+	return true
+}
+
+// runPlugin sends one request to the plugin for e's mode and reads its answer. The
+// plugin's stderr goes to the terminal, for its own messages and prompts.
+func runPlugin(op string, e entry) (pluginResponse, error) {
+	mode := e.options["mode"]
+	name := pluginPrefix + mode
+	path, err := exec.LookPath(name)
+	if err != nil {
+		return pluginResponse{}, fmt.Errorf("no plugin for mode=%s: %s is not on PATH", mode, name)
+	}
+
+	req, err := json.Marshal(pluginRequest{Version: pluginVersion, Op: op, Link: e.link, Target: e.target, Options: e.options, Config: e.config, Line: e.line})
+	if err != nil {
+		return pluginResponse{}, err
+	}
+	var out bytes.Buffer
+	cmd := exec.Command(path)
+	cmd.Stdin, cmd.Stdout, cmd.Stderr = bytes.NewReader(req), &out, os.Stderr
+	activeProgress.clear()
+	flushConsole()
+	runErr := cmd.Run()
+
+	var resp pluginResponse
+	if err := json.Unmarshal(out.Bytes(), &resp); err != nil {
+		if runErr != nil {
+			return resp, fmt.Errorf("%s %s failed for %s: %w", name, op, e.link, runErr)
+		}
+		return resp, fmt.Errorf("%s %s gave an invalid response for %s: %w", name, op, e.link, err)
+	}
+	switch {
+	case resp.Error != "":
+		return resp, fmt.Errorf("%s %s failed for %s: %s", name, op, e.link, resp.Error)
+	case runErr != nil:
+		return resp, fmt.Errorf("%s %s failed for %s: %w", name, op, e.link, runErr)
+	}
+	return resp, nil
+}
+
+// applyPluginEntry puts a plugin entry in place: the plugin is asked whether it already
+// is, and only told to apply it if not. Its pre and post hooks run around a change, as
+// for links. Plugin entries aren't recorded in the state file; the plugin keeps track of
+// what it made.
+func applyPluginEntry(e entry, act action, dryRun bool) (action, error) {
+	name := pluginPrefix + e.options["mode"]
+	check, err := runPlugin(pluginCheck, e)
+	if err != nil {
+		return act, err
+	}
+	if check.InPlace {
+		verbosef("%s\n", paint(styleSkip, fmt.Sprintf("Unchanged: %s (%s)", e.link, name)))
+		act.Action, act.Status = actionUnchanged, statusDone
+		return act, nil
+	}
+	if dryRun {
+		infof("[DRY RUN] Would run %s for %s: %s\n", name, e.link, pluginMessage(check, "apply"))
+		act.Status = statusPlanned
+		return act, nil
+	}
+
+	if pre := e.options["pre"]; pre != "" {
+		if err := runHookCommand("pre hook for "+e.link, pre, entryHookEnv(e), dryRun); err != nil {
+			return act, err
+		}
+	}
+	infof("%s %s (%s)\n", paint(styleCreate, "Running "+name+":"), e.link, pluginMessage(check, "apply"))
+	applied, err := runPlugin(pluginApply, e)
+	if err != nil {
+		return act, err
+	}
+	if !applied.Changed {
+		act.Action, act.Status = actionUnchanged, statusDone
+		return act, nil
+	}
+	if applied.Message != "" {
+		verbosef("%s: %s\n", name, applied.Message)
+	}
+	act.Status = statusDone
+	if post := e.options["post"]; post != "" {
+		if err := runHookCommand("post hook for "+e.link, post, entryHookEnv(e), dryRun); err != nil {
+			return act, err
+		}
+	}
+	return act, nil
+}
+
+// pluginMessage is what a plugin said it would do or did, or fallback if it said nothing
+func pluginMessage(resp pluginResponse, fallback string) string {
+	if resp.Message != "" {
+		return resp.Message
+	}
+	return fallback
+}
@@ -0,0 +1,96 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+	"time"
+)
+
+// progressMin is the fewest entries a run needs before it shows a progress line
+const progressMin = 50
+
+// progressInterval is how often the progress line is redrawn at most
+const progressInterval = 100 * time.Millisecond
+
+// progress is a [done/total] counter with the path being applied, kept on the last line
+// of the terminal during a long run. Messages are printed above it.
+type progress struct {
+	total, done int
+	current     string
+	drawn       time.Time
+	visible     bool
+}
+
+// activeProgress is the progress line being shown, if any
+var activeProgress *progress
+
+// startProgress shows a progress line for a run over total entries, if stdout is a
+// terminal and the run is long enough to want one; otherwise it returns nil, on which
+// the progress methods do nothing
+func startProgress(total int) *progress {
+	if *noProgress || total < progressMin || !isTerminal(os.Stdout) || os.Getenv("TERM") == "dumb" {
+		return nil
+	}
+	activeProgress = &progress{total: total}
+	return activeProgress
+}
+
+// step moves on to the entry for path
+func (p *progress) step(path string) {
+	if p == nil {
+		return
+	}
+	p.done++
+	p.current = path
+	if time.Since(p.drawn) >= progressInterval {
+		p.draw()
+	}
+}
+
+// draw prints the progress line, cut to the terminal's width so it never wraps
+func (p *progress) draw() {
+	if p == nil || p.current == "" {
+		return
+	}
+	counter := fmt.Sprintf("[%d/%d] ", p.done, p.total)
+	path := []rune(contractPath(p.current))
+	if room := terminalWidth() - 1 - len(counter); len(path) > room {
+		path = append([]rune("..."), path[len(path)-max(room-3, 0):]...)
+	}
+	fmt.Fprint(os.Stdout, "\r\x1b[K"+counter+string(path))
+	p.drawn = time.Now()
+	p.visible = true
+}
+
+// clear removes the progress line, so a message can be printed in its place
+func (p *progress) clear() {
+	if p != nil && p.visible {
+		fmt.Fprint(os.Stdout, "\r\x1b[K")
+		p.visible = false
+	}
+}
+
+// stop removes the progress line for good
+func (p *progress) stop() {
+	if p != nil {
+		p.clear()
+		activeProgress = nil
+	}
+}
+
+// columnsWidth returns the width in $COLUMNS, or 80 if it isn't set
+func columnsWidth() int {
+	if n, err := strconv.Atoi(os.Getenv("COLUMNS")); err == nil && n > 0 {
+		return n
+	}
+	return 80
+}
+
+// linesHeight returns $LINES, or 24 if it isn't set
+func linesHeight() int {
+	if n, err := strconv.Atoi(os.Getenv("LINES")); err == nil && n > 0 {
+		return n
+	}
+	return 24
+}
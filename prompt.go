@@ -0,0 +1,61 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"strings"
+)
+
+// stdinReader is shared by all prompts so buffered input isn't lost between them
+var stdinReader = bufio.NewReader(os.Stdin)
+
+// ask prints a question and returns the trimmed answer. It returns an error once
+// input is exhausted, so callers looping for a valid answer can stop.
+func ask(question string) (string, error) {
+	flushConsole()
+	activeProgress.clear()
+	fmt.Printf("%s: ", question)
+	answer, err := stdinReader.ReadString('\n')
+	if err != nil && answer == "" {
+		fmt.Println()
+		return "", err
+	}
+	return strings.TrimSpace(answer), nil
+}
+
+// prompt asks a question and returns the trimmed answer, or def if the answer is empty
+func prompt(question, def string) string {
+	if def != "" {
+		question = fmt.Sprintf("%s [%s]", question, def)
+	}
+
+	answer, _ := ask(question)
+	if answer == "" {
+		return def
+	}
+	return answer
+}
+
+// confirm asks a yes/no question, returning def when the answer is empty
+func confirm(question string, def bool) bool {
+	choices := "y/N"
+	if def {
+		choices = "Y/n"
+	}
+
+	for {
+		answer, err := ask(question + " (" + choices + ")")
+		if err != nil {
+			return def
+		}
+		switch strings.ToLower(answer) {
+		case "":
+			return def
+		case "y", "yes":
+			return true
+		case "n", "no":
+			return false
+		}
+	}
+}
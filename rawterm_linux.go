@@ -0,0 +1,9 @@
+package main
+
+import "syscall"
+
+// The ioctls reading and setting the terminal mode
+const (
+	ioctlGetTermios = syscall.TCGETS
+	ioctlSetTermios = syscall.TCSETS
+)
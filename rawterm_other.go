@@ -0,0 +1,13 @@
+//go:build !(darwin || dragonfly || freebsd || linux || netbsd || openbsd)
+
+package main
+
+import (
+	"errors"
+	"os"
+)
+
+// makeRaw is only available on Unix terminals
+func makeRaw(f *os.File) (func(), error) {
+	return nil, errors.New("reading single key presses is not supported on this system")
+}
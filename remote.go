@@ -0,0 +1,137 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// remoteTimeout bounds how long fetching a remote config may take
+const remoteTimeout = 30 * time.Second
+
+// isRemoteConfig reports whether a config argument is a URL to fetch rather than a file
+func isRemoteConfig(config string) bool {
+	return strings.HasPrefix(config, "https://") || strings.HasPrefix(config, "http://")
+}
+
+// configPath returns a config argument in the form entries record it: a file as an
+// absolute path, a URL or stdin as it is
+func configPath(config string) string {
+	if isRemoteConfig(config) || config == stdinConfig {
+		return config
+	}
+	return absPath(config)
+}
+
+// fetchConfig downloads a remote config into the state directory and returns the copy
+// to read. The copy is kept with its ETag, so unchanged configs aren't downloaded
+// again, and is used with a warning when the server can't be reached.
+func fetchConfig(rawURL string) (string, []string, error) {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return "", nil, fmt.Errorf("invalid config URL: %w", err)
+	}
+	if u.Scheme != "https" {
+		return "", nil, fmt.Errorf("refusing to fetch config over %s: use an https:// URL", u.Scheme)
+	}
+
+	dir, err := remoteCacheDir()
+	if err != nil {
+		return "", nil, err
+	}
+
+	// Keep the extension, which tells Dotbot configs from line-based ones
+	sum := sha256.Sum256([]byte(rawURL))
+	cached := filepath.Join(dir, hex.EncodeToString(sum[:8])+path.Ext(u.Path))
+	etagFile := cached + ".etag"
+
+	fetched, err := download(rawURL, cached, etagFile)
+	if err != nil {
+		if _, statErr := os.Stat(cached); statErr == nil {
+			return cached, []string{fmt.Sprintf("using the copy of %s fetched earlier: %s", rawURL, err)}, nil
+		}
+		return "", nil, err
+	}
+	if fetched {
+		verbosef("Fetched config: %s\n", rawURL)
+	} else {
+		verbosef("Config unchanged since last fetched: %s\n", rawURL)
+	}
+	return cached, nil, nil
+}
+
+// remoteCacheDir returns the directory downloaded configs are kept in, creating it
+func remoteCacheDir() (string, error) {
+	dir, err := stateDir()
+	if err != nil {
+		return "", err
+	}
+	dir = filepath.Join(dir, "remote")
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return "", fmt.Errorf("error creating remote config cache: %w", err)
+	}
+	return dir, nil
+}
+
+// download fetches rawURL into dest unless the ETag in etagFile shows the copy there is
+// current, reporting whether it fetched a new copy
+func download(rawURL, dest, etagFile string) (bool, error) {
+	req, err := http.NewRequest(http.MethodGet, rawURL, nil)
+	if err != nil {
+		return false, err
+	}
+	req.Header.Set("User-Agent", "symlinker")
+	if _, err := os.Stat(dest); err == nil {
+		if etag, err := os.ReadFile(etagFile); err == nil {
+			req.Header.Set("If-None-Match", strings.TrimSpace(string(etag)))
+		}
+	}
+
+	client := &http.Client{Timeout: remoteTimeout}
+	resp, err := client.Do(req)
+	if err != nil {
+		return false, fmt.Errorf("error fetching config: %w", err)
+	}
+	defer resp.Body.Close()
+
+	switch {
+	case resp.StatusCode == http.StatusNotModified:
+		return false, nil
+	case resp.StatusCode != http.StatusOK:
+		return false, fmt.Errorf("error fetching config %s: %s", rawURL, resp.Status)
+	}
+
+	// Write to a temporary file first, so a broken download never replaces a good copy
+	tmp := dest + ".tmp"
+	file, err := os.OpenFile(tmp, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0600)
+	if err != nil {
+		return false, err
+	}
+	if _, err := io.Copy(file, resp.Body); err != nil {
+		file.Close()
+		os.Remove(tmp)
+		return false, fmt.Errorf("error fetching config: %w", err)
+	}
+	if err := file.Close(); err != nil {
+		os.Remove(tmp)
+		return false, err
+	}
+	if err := os.Rename(tmp, dest); err != nil {
+		return false, err
+	}
+
+	if etag := resp.Header.Get("ETag"); etag != "" {
+		os.WriteFile(etagFile, []byte(etag+"\n"), 0600)
+	} else {
+		os.Remove(etagFile)
+	}
+	return true, nil
+}
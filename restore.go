@@ -0,0 +1,182 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+)
+
+// runRestore puts backed up originals back in place of the symlinks that replaced them
+func runRestore(args []string, configFilePath string) error {
+	flags := flag.NewFlagSet("restore", flag.ExitOnError)
+	run := flags.String("run", "", "Restore from this backup run (timestamp) instead of the latest")
+	from := flags.String("from", "", "Backup directory, if --backup=DIR was used (default ~/.local/share/symlinker/backups)")
+	list := flags.Bool("list", false, "List backup runs and their files instead of restoring")
+	force := flags.Bool("force", false, "Replace whatever is at the original path, not only symlinks")
+	flags.BoolVar(dryRun, "dry-run", *dryRun, "Show what would be done without making changes")
+	flags.Usage = func() {
+		fmt.Println("Usage: symlinker restore [flags] [path...]")
+		fmt.Println("\nRestore backed up originals, removing the symlinks that replaced them.")
+		fmt.Println("Without paths, every file from the backup run is restored.")
+		fmt.Println("With paths, the newest backup of each path is restored.")
+		fmt.Println("\nFlags:")
+		flags.PrintDefaults()
+	}
+	paths := parseArgs(flags, args)
+
+	root := expandPath(*from)
+	if root == "" {
+		var err error
+		if root, err = defaultBackupRoot(); err != nil {
+			return err
+		}
+	}
+
+	runs, err := backupRuns(root)
+	if err != nil {
+		return err
+	}
+	if *run != "" {
+		if _, err := os.Stat(filepath.Join(root, *run)); err != nil {
+			return fmt.Errorf("backup run not found: %s", *run)
+		}
+		runs = []string{*run}
+	}
+
+	if *list {
+		for _, r := range runs {
+			files, err := readBackupIndex(filepath.Join(root, r))
+			if err != nil {
+				return err
+			}
+			fmt.Printf("%s (%d files)\n", r, len(files))
+			for _, f := range files {
+				fmt.Printf("    %s\n", f)
+			}
+		}
+		return nil
+	}
+
+	// Work out which backup to restore for each path
+	type restoreItem struct{ path, backup string }
+	var items []restoreItem
+	if len(paths) == 0 {
+		for _, r := range runs {
+			files, err := readBackupIndex(filepath.Join(root, r))
+			if err != nil {
+				return err
+			}
+			if len(files) == 0 {
+				continue
+			}
+			for _, f := range files {
+				items = append(items, restoreItem{f, backupPath(filepath.Join(root, r), f)})
+			}
+			break
+		}
+	} else {
+		for _, p := range paths {
+			path := absPath(expandPath(p))
+			found := false
+			for _, r := range runs {
+				files, err := readBackupIndex(filepath.Join(root, r))
+				if err != nil {
+					return err
+				}
+				for _, f := range files {
+					if f == path {
+						items = append(items, restoreItem{f, backupPath(filepath.Join(root, r), f)})
+						found = true
+						break
+					}
+				}
+				if found {
+					break
+				}
+			}
+			if !found {
+				return fmt.Errorf("no backup found for %s", path)
+			}
+		}
+	}
+	if len(items) == 0 {
+		infof("No backups to restore.\n")
+		return nil
+	}
+
+	if !*dryRun {
+		unlock, err := acquireLock()
+		if err != nil {
+			return err
+		}
+		defer unlock()
+	}
+
+	st, err := loadState()
+	if err != nil {
+		return err
+	}
+	j := newJournal("restore", st)
+	defer j.save()
+
+	failed := 0
+	for _, item := range items {
+		if err := restoreBackup(item.path, item.backup, st, j, *force, *dryRun); err != nil {
+			errorf("%s\n", err)
+			failed++
+		}
+	}
+
+	if err := st.save(); err != nil {
+		return err
+	}
+	if failed > 0 {
+		return fmt.Errorf("failed to restore %d files", failed)
+	}
+
+	if *dryRun {
+		infof("[DRY RUN] Restore complete! (No changes made)\n")
+	} else {
+		infof("Restore complete!\n")
+	}
+	return nil
+}
+
+// restoreBackup moves one backup back to its original path
+func restoreBackup(path, backup string, st *state, j *journal, force, dryRun bool) error {
+	op := journalOp{Op: opRemove, Path: path, Backup: backup}
+
+	if info, err := os.Lstat(path); err == nil {
+		if info.Mode()&os.ModeSymlink == 0 && !force {
+			return fmt.Errorf("not restoring %s: it exists and is not a symlink (use --force to replace it)", path)
+		}
+		if info.Mode()&os.ModeSymlink != 0 {
+			op.Previous, _ = os.Readlink(path)
+		}
+		report(dryRun, "remove", "Removing", path)
+		if !dryRun {
+			if err := auditRemove(path, os.RemoveAll); err != nil {
+				return fmt.Errorf("error removing %s: %w", path, err)
+			}
+		}
+	}
+
+	report(dryRun, "restore backup", "Restoring backup", backup+" -> "+path)
+	if dryRun {
+		return nil
+	}
+	if err := ensureDirExists(filepath.Dir(path), defaultOwnership(), false, j); err != nil {
+		return fmt.Errorf("error creating directory for %s: %w", path, err)
+	}
+	if err := movePath(backup, path); err != nil {
+		return fmt.Errorf("error restoring %s: %w", path, err)
+	}
+
+	// Only a replaced symlink can be put back by undo
+	if op.Previous != "" {
+		j.add(op)
+	}
+	st.forget(path)
+	return nil
+}
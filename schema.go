@@ -0,0 +1,203 @@
+package main
+
+import (
+	_ "embed"
+	"encoding/json"
+	"fmt"
+	"maps"
+	"math"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// dotbotSchemaJSON is the JSON Schema for the Dotbot configs symlinker reads
+//
+//go:embed dotbot.schema.json
+var dotbotSchemaJSON []byte
+
+// schema is the subset of JSON Schema used by the embedded schemas:
+// type, properties, additionalProperties, items, enum and local $ref
+type schema struct {
+	Ref                  string             `json:"$ref"`
+	Type                 schemaTypes        `json:"type"`
+	Properties           map[string]*schema `json:"properties"`
+	AdditionalProperties *schemaOrBool      `json:"additionalProperties"`
+	Items                *schema            `json:"items"`
+	Enum                 []string           `json:"enum"`
+	Defs                 map[string]*schema `json:"$defs"`
+}
+
+// schemaTypes is a type keyword, written as a single name or a list of names
+type schemaTypes []string
+
+func (t *schemaTypes) UnmarshalJSON(data []byte) error {
+	var name string
+	if err := json.Unmarshal(data, &name); err == nil {
+		*t = schemaTypes{name}
+		return nil
+	}
+	return json.Unmarshal(data, (*[]string)(t))
+}
+
+// schemaOrBool is a keyword that takes either a schema or true/false
+type schemaOrBool struct {
+	allowed bool
+	schema  *schema
+}
+
+func (s *schemaOrBool) UnmarshalJSON(data []byte) error {
+	if err := json.Unmarshal(data, &s.allowed); err == nil {
+		return nil
+	}
+	s.allowed = true
+	return json.Unmarshal(data, &s.schema)
+}
+
+// loadSchema parses an embedded schema
+func loadSchema(data []byte) *schema {
+	var s schema
+	if err := json.Unmarshal(data, &s); err != nil {
+		panic(fmt.Sprintf("invalid embedded schema: %s", err))
+	}
+	return &s
+}
+
+// validateSchema checks a parsed config against a schema, reporting the first violation
+func validateSchema(n *node, root *schema) error {
+	return root.validate(n, root, "config")
+}
+
+// validate checks n against s; name describes n in error messages
+func (s *schema) validate(n *node, root *schema, name string) error {
+	if s.Ref != "" {
+		ref, ok := root.Defs[strings.TrimPrefix(s.Ref, "#/$defs/")]
+		if !ok {
+			panic(fmt.Sprintf("invalid embedded schema: unknown $ref %s", s.Ref))
+		}
+		if err := ref.validate(n, root, name); err != nil {
+			return err
+		}
+	}
+
+	if len(s.Type) > 0 && !slices.ContainsFunc(s.Type, n.isType) {
+		names := make([]string, len(s.Type))
+		for i, t := range s.Type {
+			names[i] = typeNoun(t)
+		}
+		return fmt.Errorf("%s: %s must be %s, got %s", n.position(), name, strings.Join(names, " or "), n.describe())
+	}
+	if len(s.Enum) > 0 && n.kind == scalarNode && !slices.Contains(s.Enum, n.value) {
+		return fmt.Errorf("%s: %s must be one of %s, got %s", n.position(), name, strings.Join(s.Enum, ", "), n.describe())
+	}
+
+	switch n.kind {
+	case mappingNode:
+		for i, key := range n.keys {
+			val := n.vals[i]
+			if prop, ok := s.Properties[key.value]; ok {
+				if err := prop.validate(val, root, key.value); err != nil {
+					return err
+				}
+				continue
+			}
+			if s.AdditionalProperties == nil {
+				continue
+			}
+			if !s.AdditionalProperties.allowed {
+				if guess := closestName(key.value, maps.Keys(s.Properties)); guess != "" {
+					return fmt.Errorf("%s: unknown field %q in %s (did you mean %q?)", key.position(), key.value, name, guess)
+				}
+				return fmt.Errorf("%s: unknown field %q in %s", key.position(), key.value, name)
+			}
+			if s.AdditionalProperties.schema != nil {
+				if err := s.AdditionalProperties.schema.validate(val, root, key.value); err != nil {
+					return err
+				}
+			}
+		}
+	case sequenceNode:
+		if s.Items != nil {
+			for _, item := range n.items {
+				if err := s.Items.validate(item, root, "each item of "+name); err != nil {
+					return err
+				}
+			}
+		}
+	}
+	return nil
+}
+
+// isType reports whether a node matches a JSON Schema type name
+func (n *node) isType(name string) bool {
+	switch name {
+	case "object":
+		return n.kind == mappingNode
+	case "array":
+		return n.kind == sequenceNode
+	case "null":
+		return n.kind == scalarNode && n.null
+	case "string":
+		return n.kind == scalarNode && !n.null
+	case "boolean":
+		_, err := n.bool()
+		return err == nil
+	case "number":
+		_, ok := n.number()
+		return ok
+	case "integer":
+		f, ok := n.number()
+		return ok && f == math.Trunc(f)
+	}
+	return false
+}
+
+// number parses a scalar written as a decimal number. JSON Schema's integers are the
+// numbers without a fraction, 1.0 among them; hex, infinities and NaN aren't numbers.
+func (n *node) number() (float64, bool) {
+	if n.kind != scalarNode || n.null {
+		return 0, false
+	}
+	digits := strings.TrimLeft(n.value, "+-")
+	if digits == "" || !(digits[0] >= '0' && digits[0] <= '9' || digits[0] == '.') || strings.ContainsAny(digits, "xX_") {
+		return 0, false
+	}
+	f, err := strconv.ParseFloat(n.value, 64)
+	return f, err == nil
+}
+
+// typeNoun phrases a JSON Schema type name in config terms
+func typeNoun(name string) string {
+	switch name {
+	case "object":
+		return "a mapping"
+	case "array":
+		return "a list"
+	case "null":
+		return "empty"
+	case "integer":
+		return "an integer"
+	}
+	return "a " + name
+}
+
+// describe phrases what a node actually holds, for error messages
+func (n *node) describe() string {
+	switch {
+	case n.kind == mappingNode:
+		return "a mapping"
+	case n.kind == sequenceNode:
+		return "a list"
+	case n.null:
+		return "nothing"
+	}
+	return strconv.Quote(n.value)
+}
+
+// position formats a node's source location
+func (n *node) position() string {
+	if n.col == 0 {
+		return fmt.Sprintf("line %d", n.line)
+	}
+	return fmt.Sprintf("line %d, column %d", n.line, n.col)
+}
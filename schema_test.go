@@ -0,0 +1,109 @@
+package main
+
+import "testing"
+
+// testSchema exercises each keyword validate supports
+var testSchema = loadSchema([]byte(`{
+  "type": "object",
+  "properties": {
+    "name": { "type": "string" },
+    "count": { "type": "integer" },
+    "ratio": { "type": "number" },
+    "enabled": { "type": "boolean" },
+    "mode": { "type": ["string", "null"], "enum": ["copy", "hardlink"] },
+    "tags": { "type": "array", "items": { "type": "string" } },
+    "strict": { "$ref": "#/$defs/strict" },
+    "loose": { "type": "object" },
+    "typed": { "type": "object", "additionalProperties": { "type": "boolean" } }
+  },
+  "additionalProperties": false,
+  "$defs": {
+    "strict": {
+      "type": "object",
+      "properties": { "force": { "type": "boolean" } },
+      "additionalProperties": false
+    }
+  }
+}`))
+
+func TestValidateSchema(t *testing.T) {
+	tests := []struct {
+		name  string
+		input string
+		want  string // "" for a valid config
+	}{
+		{"valid", "name: x\ncount: 3\nratio: 0.5\nenabled: yes\nmode: copy\ntags: [a, b]\nstrict: {force: true}\n", ""},
+		{"integer with a zero fraction", "count: 2.0\n", ""},
+		{"signed number", "ratio: -1e3\n", ""},
+		{"number as a string", "name: 42\n", ""},
+		{"unlisted keys allowed", "loose: {anything: [1]}\n", ""},
+		{"additional properties schema", "typed: {a: true, b: off}\n", ""},
+		{"wrong top-level type", "- a\n", `line 1, column 1: config must be a mapping, got a list`},
+		{"null string", "name:\n", `line 1: name must be a string, got nothing`},
+		{"fractional integer", "count: 1.5\n", `line 1, column 8: count must be an integer, got "1.5"`},
+		{"hex is not a number", "ratio: 0x10\n", `line 1, column 8: ratio must be a number, got "0x10"`},
+		{"infinity is not a number", "ratio: Inf\n", `line 1, column 8: ratio must be a number, got "Inf"`},
+		{"not a boolean", "enabled: maybe\n", `line 1, column 10: enabled must be a boolean, got "maybe"`},
+		{"type list", "mode: [copy]\n", `line 1, column 7: mode must be a string or empty, got a list`},
+		{"enum", "mode: symlink\n", `line 1, column 7: mode must be one of copy, hardlink, got "symlink"`},
+		{"null outside the enum", "mode: ~\n", `line 1, column 7: mode must be one of copy, hardlink, got nothing`},
+		{"items", "tags:\n  - a\n  - {b: c}\n", `line 3, column 5: each item of tags must be a string, got a mapping`},
+		{"unknown field", "nmae: x\n", `line 1, column 1: unknown field "nmae" in config (did you mean "name"?)`},
+		{"unknown field without a guess", "colour: red\n", `line 1, column 1: unknown field "colour" in config`},
+		{"ref", "strict: {forse: true}\n", `line 1, column 10: unknown field "forse" in strict (did you mean "force"?)`},
+		{"ref type", "strict: [force]\n", `line 1, column 9: strict must be a mapping, got a list`},
+		{"additional properties schema violation", "typed: {a: 1}\n", `line 1, column 12: a must be a boolean, got "1"`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			n, err := parseYAML([]byte(tt.input))
+			if err != nil {
+				t.Fatalf("parseYAML: %v", err)
+			}
+			err = validateSchema(n, testSchema)
+			switch {
+			case tt.want == "" && err != nil:
+				t.Errorf("got error %v, want none", err)
+			case tt.want != "" && (err == nil || err.Error() != tt.want):
+				t.Errorf("got error %v, want %s", err, tt.want)
+			}
+		})
+	}
+}
+
+func TestValidateDotbotSchema(t *testing.T) {
+	tests := []struct {
+		name  string
+		input string
+		want  string
+	}{
+		{"links", "- defaults:\n    link:\n      relink: true\n- link:\n    ~/.vimrc: vimrc\n    ~/.zshrc:\n    ~/.tmux.conf:\n      path: tmux.conf\n      force: true\n      symlinker: {mode: copy, backup: true, retries: 3}\n- shell: [[echo hi]]\n", ""},
+		{"misspelled option", "- link:\n    ~/.vimrc:\n      path: vimrc\n      forse: true\n", `line 4, column 7: unknown field "forse" in ~/.vimrc (did you mean "force"?)`},
+		{"option type", "- link:\n    ~/.vimrc: {force: sometimes}\n", `line 2, column 23: force must be a boolean, got "sometimes"`},
+		{"link target type", "- link:\n    ~/.vimrc: [vimrc]\n", `line 2, column 15: ~/.vimrc must be a string or empty or a mapping, got a list`},
+		{"symlinker option type", "- link:\n    ~/.vimrc:\n      symlinker: {mode: [copy]}\n", `line 3, column 25: mode must be a string or a boolean or a number, got a list`},
+		{"not a list of directives", "link: {}\n", `line 1, column 1: config must be a list, got a mapping`},
+		{"JSON", "[{\"link\": {\"~/.vimrc\": {\"relative\": \"yes please\"}}}]", `line 1, column 37: relative must be a boolean, got "yes please"`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var n *node
+			var err error
+			if tt.input[0] == '[' {
+				n, err = parseJSONNode([]byte(tt.input))
+			} else {
+				n, err = parseYAML([]byte(tt.input))
+			}
+			if err != nil {
+				t.Fatalf("parse: %v", err)
+			}
+			err = validateSchema(n, dotbotSchema)
+			switch {
+			case tt.want == "" && err != nil:
+				t.Errorf("got error %v, want none", err)
+			case tt.want != "" && (err == nil || err.Error() != tt.want):
+				t.Errorf("got error %v, want %s", err, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,219 @@
+package main
+
+import (
+	"context"
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"net/http"
+	"os"
+	"os/signal"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+)
+
+// serveStatus is one entry in serve's /status
+type serveStatus struct {
+	Link   string `json:"link"`
+	Target string `json:"target"`
+	Config string `json:"config,omitempty"`
+	Line   int    `json:"line,omitempty"`
+	Status string `json:"status"`
+	Detail string `json:"detail,omitempty"`
+}
+
+// server answers serve's endpoints for a set of configs
+type server struct {
+	configs []string
+	opts    applyOptions
+	token   string     // bearer token /apply wants; empty disables it
+	applyMu sync.Mutex // one triggered apply at a time
+}
+
+// runServe answers HTTP requests for the state of the configs' links, so dashboards and
+// orchestration can query it without running symlinker themselves
+func runServe(args []string, configFilePath string) error {
+	flags := flag.NewFlagSet("serve", flag.ExitOnError)
+	listen := flags.String("listen", "127.0.0.1:7070", "Address to listen on")
+	tokenFile := flags.String("token-file", "", "Enable POST /apply for requests bearing the token in `FILE` (or $SYMLINKER_TOKEN)")
+	flags.Usage = func() {
+		fmt.Println("Usage: symlinker serve [flags] [config-file...]")
+		fmt.Println("\nAnswer HTTP requests for the state of the configs' links, in JSON:")
+		fmt.Println("  GET /status     each entry's status, as status shows it, and orphaned links")
+		fmt.Println("  GET /last-run   the report of the last apply run")
+		fmt.Println("  GET /links      the managed links in the state file")
+		fmt.Println("  POST /apply     apply the configs; needs \"Authorization: Bearer <token>\"")
+		fmt.Println("\nFlags:")
+		flags.PrintDefaults()
+		fmt.Println("\nThe flags for applying configs, such as --backup, go before \"serve\".")
+	}
+	configs := parseArgs(flags, args)
+	if len(configs) == 0 {
+		configs = []string{configFilePath}
+	}
+	for i, config := range configs {
+		configs[i] = configPath(config)
+	}
+
+	opts, err := optionsFromFlags()
+	if err != nil {
+		return err
+	}
+	s := &server{configs: configs, opts: opts, token: os.Getenv("SYMLINKER_TOKEN")}
+	if *tokenFile != "" {
+		data, err := os.ReadFile(expandTilde(*tokenFile))
+		if err != nil {
+			return withExitCode(exitConfig, fmt.Errorf("error reading token file: %w", err))
+		}
+		s.token = strings.TrimSpace(string(data))
+	}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /status", s.handleStatus)
+	mux.HandleFunc("GET /last-run", s.handleLastRun)
+	mux.HandleFunc("GET /links", s.handleLinks)
+	mux.HandleFunc("POST /apply", s.handleApply)
+	srv := &http.Server{Addr: *listen, Handler: mux, ReadHeaderTimeout: 10 * time.Second}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	errs := make(chan error, 1)
+	go func() { errs <- srv.ListenAndServe() }()
+	if s.token == "" {
+		noticef("Serving on http://%s (POST /apply is disabled without a token)\n", *listen)
+	} else {
+		noticef("Serving on http://%s\n", *listen)
+	}
+
+	select {
+	case err := <-errs:
+		return withExitCode(exitEnv, fmt.Errorf("error serving: %w", err))
+	case <-ctx.Done():
+	}
+	shutdown, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err := srv.Shutdown(shutdown); err != nil && !errors.Is(err, http.ErrServerClosed) {
+		return err
+	}
+	infof("Server stopped.\n")
+	return nil
+}
+
+// handleStatus answers with each entry's status and the orphaned managed links
+func (s *server) handleStatus(w http.ResponseWriter, r *http.Request) {
+	entries, err := parseConfigs(s.configs)
+	if err != nil {
+		writeJSONError(w, http.StatusInternalServerError, err)
+		return
+	}
+	st, err := loadState()
+	if err != nil {
+		writeJSONError(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	statuses := []serveStatus{}
+	linked := 0
+	for _, e := range entries {
+		link := underRoot(*rootDir, e.link)
+		status, detail := entryStatus(e, link, st)
+		if status == entryLinked {
+			linked++
+		}
+		detail = strings.TrimSuffix(strings.TrimPrefix(detail, " ("), ")")
+		statuses = append(statuses, serveStatus{Link: link, Target: e.target, Config: e.config, Line: e.line, Status: status, Detail: detail})
+	}
+	for _, l := range orphanedLinks(s.configs, entries, st) {
+		statuses = append(statuses, serveStatus{Link: l.Link, Target: l.Target, Config: l.Config, Status: entryOrphaned})
+	}
+	writeJSON(w, http.StatusOK, map[string]any{
+		"entries": len(entries),
+		"linked":  linked,
+		"links":   statuses,
+	})
+}
+
+// handleLastRun answers with the report of the last apply run
+func (s *server) handleLastRun(w http.ResponseWriter, r *http.Request) {
+	last, err := loadLastRun()
+	switch {
+	case err != nil:
+		writeJSONError(w, http.StatusInternalServerError, err)
+	case last == nil:
+		writeJSONError(w, http.StatusNotFound, fmt.Errorf("no apply run has been recorded"))
+	default:
+		writeJSON(w, http.StatusOK, last)
+	}
+}
+
+// handleLinks answers with the managed links in the state file
+func (s *server) handleLinks(w http.ResponseWriter, r *http.Request) {
+	st, err := loadState()
+	if err != nil {
+		writeJSONError(w, http.StatusInternalServerError, err)
+		return
+	}
+	links := st.Links
+	if links == nil {
+		links = []managedLink{}
+	}
+	writeJSON(w, http.StatusOK, map[string]any{"links": links})
+}
+
+// handleApply applies the configs for a request bearing the token, answering with the
+// run's report
+func (s *server) handleApply(w http.ResponseWriter, r *http.Request) {
+	if s.token == "" {
+		writeJSONError(w, http.StatusForbidden, fmt.Errorf("apply is disabled; start serve with --token-file or $SYMLINKER_TOKEN"))
+		return
+	}
+	token, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+	if !ok || subtle.ConstantTimeCompare([]byte(token), []byte(s.token)) != 1 {
+		w.Header().Set("WWW-Authenticate", "Bearer")
+		writeJSONError(w, http.StatusUnauthorized, fmt.Errorf("missing or wrong token"))
+		return
+	}
+
+	s.applyMu.Lock()
+	defer s.applyMu.Unlock()
+	noticef("Apply requested by %s\n", r.RemoteAddr)
+	opts, err := s.opts.nextRun()
+	if err == nil {
+		err = setupSymlinks(s.configs, opts)
+	}
+	if err != nil {
+		errorf("%s\n", err)
+	}
+	resp := map[string]any{"ok": err == nil}
+	if err != nil {
+		resp["error"] = err.Error()
+	}
+	if !s.opts.dryRun {
+		if last, loadErr := loadLastRun(); loadErr == nil && last != nil {
+			resp["run"] = last
+		}
+	}
+	code := http.StatusOK
+	if err != nil {
+		code = http.StatusInternalServerError
+	}
+	writeJSON(w, code, resp)
+}
+
+// writeJSON answers a request with v as JSON
+func writeJSON(w http.ResponseWriter, code int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(code)
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	enc.Encode(v)
+}
+
+// writeJSONError answers a request with an error as JSON
+func writeJSONError(w http.ResponseWriter, code int, err error) {
+	writeJSON(w, code, map[string]string{"error": err.Error()})
+}
@@ -0,0 +1,254 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+// serviceName names the units symlinker installs
+const serviceName = "symlinker"
+
+// runService installs or removes a service that keeps the links applied
+func runService(args []string, configFilePath string) error {
+	flags := flag.NewFlagSet("service", flag.ExitOnError)
+	systemd := flags.Bool("systemd", false, "Use a systemd user service (the only service manager supported so far)")
+	daemon := flags.Bool("daemon", false, "Run symlinker daemon in the service instead of applying on a timer")
+	schedule := flags.String("schedule", "hourly", "When the timer applies the config, as a systemd OnCalendar= expression")
+	interval := flags.Duration("interval", 15*time.Minute, "With --daemon, how often the daemon checks the links")
+	flags.BoolVar(dryRun, "dry-run", *dryRun, "Print the units and commands instead of installing them")
+	flags.Usage = func() {
+		fmt.Println("Usage: symlinker service install|uninstall --systemd [flags] [config-file]")
+		fmt.Println("\nInstall a systemd user service that applies the config at login and on a schedule,")
+		fmt.Println("or that runs the daemon; uninstall stops and removes it again.")
+		fmt.Println("\nFlags:")
+		flags.PrintDefaults()
+	}
+	rest := parseArgs(flags, args)
+	if len(rest) == 0 || (rest[0] != "install" && rest[0] != "uninstall") {
+		flags.Usage()
+		return withExitCode(exitConfig, fmt.Errorf("service needs install or uninstall"))
+	}
+	if !*systemd {
+		return withExitCode(exitConfig, fmt.Errorf("choose a service manager: --systemd"))
+	}
+
+	dir, err := systemdUserDir()
+	if err != nil {
+		return err
+	}
+	if rest[0] == "uninstall" {
+		return uninstallSystemd(dir)
+	}
+	if len(rest) > 1 {
+		configFilePath = rest[1]
+	}
+	return installSystemd(dir, configPath(configFilePath), *daemon, *schedule, *interval)
+}
+
+// systemdUserDir returns the directory user units are installed in
+func systemdUserDir() (string, error) {
+	if dir := os.Getenv("XDG_CONFIG_HOME"); dir != "" {
+		return filepath.Join(dir, "systemd", "user"), nil
+	}
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return "", withExitCode(exitEnv, fmt.Errorf("error finding systemd unit directory: %w", err))
+	}
+	return filepath.Join(home, ".config", "systemd", "user"), nil
+}
+
+// installSystemd writes the units for applying configFile and enables them
+func installSystemd(dir, configFile string, daemon bool, schedule string, interval time.Duration) error {
+	entries, err := parseConfig(configFile)
+	if err != nil {
+		return err
+	}
+	exe, err := os.Executable()
+	if err != nil {
+		return err
+	}
+	if exe, err = filepath.EvalSymlinks(exe); err != nil {
+		return err
+	}
+
+	command, err := serviceCommand(exe)
+	if err != nil {
+		return err
+	}
+
+	units := map[string]string{}
+	if daemon {
+		units[serviceName+".service"] = systemdService(entries, "simple",
+			append(command, "daemon", "--interval", interval.String(), configFile), "Restart=on-failure\nRestartSec=1min\n")
+	} else {
+		units[serviceName+".service"] = systemdService(entries, "oneshot", append(command, configFile), "")
+		units[serviceName+".timer"] = "[Unit]\nDescription=Apply symlinker config on a schedule\n\n" +
+			"[Timer]\nOnCalendar=" + schedule + "\nPersistent=true\n\n" +
+			"[Install]\nWantedBy=timers.target\n"
+	}
+
+	names := make([]string, 0, len(units))
+	for name := range units {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		path := filepath.Join(dir, name)
+		if *dryRun {
+			fmt.Printf("[DRY RUN] Would write %s:\n%s\n", path, units[name])
+			continue
+		}
+		if err := os.MkdirAll(dir, 0755); err != nil {
+			return fmt.Errorf("error creating unit directory: %w", err)
+		}
+		if err := os.WriteFile(path, []byte(units[name]), 0644); err != nil {
+			return fmt.Errorf("error writing unit: %w", err)
+		}
+		infof("Wrote %s\n", path)
+	}
+
+	if err := systemctl("daemon-reload"); err != nil {
+		return err
+	}
+	return systemctl(append([]string{"enable", "--now"}, names...)...)
+}
+
+// serviceFlags are the flags given before "service" that its runs keep, mapped to
+// whether they hold a path. Flags for someone at a terminal, such as --interactive, -v
+// or --output, are left out, as are --user and --sudo, which don't fit a user service.
+var serviceFlags = map[string]bool{
+	"age-identity": true, "allow-exec": false, "backup": true, "continue-on-error": false,
+	"copy-fallback": false, "create-missing-targets": false, "dir-mode": false, "force": false,
+	"log-file": true, "log-format": false, "metrics-file": true, "no-expand": false,
+	"notify": false, "on-conflict": false, "percent-vars": false, "post": false, "pre": false,
+	"relative": false, "report-url": false, "require-target": false, "resolve-targets": false,
+	"root": true, "root-targets": false, "sha256": false, "signature": false,
+	"signing-key": false, "state-dir": true, "strict": false, "trash": false,
+}
+
+// serviceCommand returns the command line the service runs exe with: the flags given
+// before "service" that suit unattended runs, with paths made absolute, since the
+// service doesn't start in the current directory
+func serviceCommand(exe string) ([]string, error) {
+	command := []string{exe}
+	var err error
+	flag.Visit(func(f *flag.Flag) {
+		isPath, kept := serviceFlags[f.Name]
+		value := f.Value.String()
+		switch {
+		case f.Name == "dry-run" || f.Name == "config" || (f.Name == "backup" && !backup.enabled):
+		case !kept:
+			dash := "--"
+			if len(f.Name) <= 2 { // -i, -q, -v and -vv
+				dash = "-"
+			}
+			warnf("%s%s is left out of the service, which runs unattended\n", dash, f.Name)
+		case f.Name == "on-conflict" && value == conflictAsk:
+			err = withExitCode(exitConfig, fmt.Errorf("--on-conflict=ask needs someone to answer; choose overwrite, skip or backup for the service"))
+		default:
+			// The signature may also be a URL, and the signing key the key itself
+			_, statErr := os.Stat(expandTilde(value))
+			if isPath && value != "" || f.Name == "signature" && !isRemoteConfig(value) || f.Name == "signing-key" && statErr == nil {
+				value = absPath(expandTilde(value))
+			}
+			command = append(command, "--"+f.Name+"="+value)
+		}
+	})
+	return command, err
+}
+
+// systemdService builds a service unit running command, started at login. The variables
+// the config uses are copied in, since a user service doesn't get the login shell's.
+func systemdService(entries []entry, kind string, command []string, extra string) string {
+	var b strings.Builder
+	b.WriteString("[Unit]\nDescription=Keep symlinker links in place\n\n[Service]\n")
+	b.WriteString("Type=" + kind + "\n")
+	for _, name := range configVars(entries) {
+		if value, ok := os.LookupEnv(name); ok {
+			b.WriteString("Environment=" + systemdQuote(name+"="+value) + "\n")
+		}
+	}
+	quoted := make([]string, len(command))
+	for i, arg := range command {
+		quoted[i] = systemdQuote(arg)
+	}
+	b.WriteString("ExecStart=" + strings.Join(quoted, " ") + "\n")
+	b.WriteString(extra)
+	b.WriteString("\n[Install]\nWantedBy=default.target\n")
+	return b.String()
+}
+
+// configVars returns the names of the environment variables a config's paths use
+func configVars(entries []entry) []string {
+	seen := map[string]bool{}
+	for _, e := range entries {
+		for _, name := range referencedVars(e.rawLink + " " + e.rawTarget) {
+			seen[name] = true
+		}
+	}
+	names := make([]string, 0, len(seen))
+	for name := range seen {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// systemdQuote quotes a word for a unit file, escaping what systemd would otherwise
+// expand: backslashes and quotes, and % specifiers
+func systemdQuote(s string) string {
+	s = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "%", "%%", "\n", `\n`).Replace(s)
+	return `"` + s + `"`
+}
+
+// uninstallSystemd stops and removes the units installSystemd wrote
+func uninstallSystemd(dir string) error {
+	var names []string
+	for _, name := range []string{serviceName + ".timer", serviceName + ".service"} {
+		if _, err := os.Stat(filepath.Join(dir, name)); err == nil {
+			names = append(names, name)
+		}
+	}
+	if len(names) == 0 {
+		infof("No symlinker units installed in %s\n", dir)
+		return nil
+	}
+
+	if err := systemctl(append([]string{"disable", "--now"}, names...)...); err != nil {
+		return err
+	}
+	for _, name := range names {
+		path := filepath.Join(dir, name)
+		if *dryRun {
+			infof("[DRY RUN] Would remove %s\n", path)
+			continue
+		}
+		if err := os.Remove(path); err != nil {
+			return fmt.Errorf("error removing unit: %w", err)
+		}
+		infof("Removed %s\n", path)
+	}
+	return systemctl("daemon-reload")
+}
+
+// systemctl runs systemctl --user with args
+func systemctl(args ...string) error {
+	args = append([]string{"--user"}, args...)
+	if *dryRun {
+		infof("[DRY RUN] Would run: systemctl %s\n", strings.Join(args, " "))
+		return nil
+	}
+	infof("Running: systemctl %s\n", strings.Join(args, " "))
+	cmd := exec.Command("systemctl", args...)
+	cmd.Stdout, cmd.Stderr = os.Stdout, os.Stderr
+	if err := cmd.Run(); err != nil {
+		return withExitCode(exitEnv, fmt.Errorf("error running systemctl: %w", err))
+	}
+	return nil
+}
@@ -0,0 +1,198 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+)
+
+// stateVersion is bumped whenever the state file format changes incompatibly
+const stateVersion = 1
+
+// managedLink is a symlink created by symlinker
+type managedLink struct {
+	Link    string    `json:"link"`
+	Target  string    `json:"target"`
+	Mode    string    `json:"mode,omitempty"` // link mode; empty means symlink
+	Config  string    `json:"config,omitempty"`
+	Backup  string    `json:"backup,omitempty"`  // where the displaced original was moved, if anywhere
+	Trashed string    `json:"trashed,omitempty"` // where in the trash the displaced original went, with --trash
+	Hash    string    `json:"hash,omitempty"`    // content digest of a copy when it was made
+	Source  string    `json:"source,omitempty"`  // content digest of the encrypted actual path a file was decrypted from
+	Created time.Time `json:"created"`
+}
+
+// state is the manifest of every link symlinker manages
+type state struct {
+	Version int           `json:"version"`
+	Links   []managedLink `json:"links"`
+	Daemon  *daemonStatus `json:"daemon,omitempty"` // what the daemon last did
+
+	path  string
+	dirty bool
+	index map[string]int // position of each link in Links, built on first use
+}
+
+// stateDir returns the directory symlinker keeps its state in. With --root it is
+// kept inside the root, so the links made there are tracked apart from the host's.
+func stateDir() (string, error) {
+	if *stateDirFlag != "" {
+		return absPath(*stateDirFlag), nil
+	}
+	if dir := os.Getenv("XDG_STATE_HOME"); dir != "" {
+		return underRoot(*rootDir, filepath.Join(dir, "symlinker")), nil
+	}
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return "", withExitCode(exitEnv, fmt.Errorf("error finding state directory: %w", err))
+	}
+	return underRoot(*rootDir, filepath.Join(home, ".local", "state", "symlinker")), nil
+}
+
+// handStateBack gives what a root run wrote in a --state-dir to the directory's owner,
+// as when a user's run hands entries to sudo, so their own runs can still update the
+// state and history
+func handStateBack() {
+	if *stateDirFlag == "" || os.Geteuid() != 0 {
+		return
+	}
+	dir := absPath(*stateDirFlag)
+	info, err := os.Stat(dir)
+	if err != nil {
+		return
+	}
+	uid, gid, ok := ownerOf(info)
+	if !ok || uid == 0 {
+		return
+	}
+	if err := chownTree(dir, ownership{uid: uid, gid: gid}); err != nil {
+		warnf("can't give %s back to its owner: %s\n", dir, err)
+	}
+}
+
+// loadState reads the state manifest, returning an empty one if none exists yet
+func loadState() (*state, error) {
+	dir, err := stateDir()
+	if err != nil {
+		return nil, err
+	}
+
+	st := &state{Version: stateVersion, path: filepath.Join(dir, "state.json")}
+	data, err := os.ReadFile(st.path)
+	if os.IsNotExist(err) {
+		return st, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("error reading state file: %w", err)
+	}
+
+	if err := json.Unmarshal(data, st); err != nil {
+		return nil, fmt.Errorf("error parsing state file %s: %w", st.path, err)
+	}
+	if st.Version > stateVersion {
+		return nil, fmt.Errorf("state file %s was written by a newer symlinker (version %d)", st.path, st.Version)
+	}
+	return st, nil
+}
+
+// save writes the state manifest atomically if it changed
+func (st *state) save() error {
+	if !st.dirty {
+		return nil
+	}
+
+	sort.Slice(st.Links, func(i, j int) bool { return st.Links[i].Link < st.Links[j].Link })
+	st.index = nil
+	data, err := json.MarshalIndent(st, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	if err := os.MkdirAll(filepath.Dir(st.path), 0755); err != nil {
+		return fmt.Errorf("error creating state directory: %w", err)
+	}
+	tmp := st.path + ".tmp"
+	if err := os.WriteFile(tmp, append(data, '\n'), 0644); err != nil {
+		return fmt.Errorf("error writing state file: %w", err)
+	}
+	if err := os.Rename(tmp, st.path); err != nil {
+		return fmt.Errorf("error writing state file: %w", err)
+	}
+	st.dirty = false
+	return nil
+}
+
+// record adds or updates a managed link. No backup or trashed original keeps the one
+// recorded earlier, since the original file is still the one to restore.
+func (st *state) record(link managedLink) {
+	link.Link = absPath(link.Link)
+	l, ok := st.lookup(link.Link)
+	displaced := link.Backup != "" || link.Trashed != ""
+	if ok && l.Target == link.Target && l.Mode == link.Mode && l.Config == link.Config && l.Hash == link.Hash && l.Source == link.Source && !displaced {
+		return
+	}
+	if !displaced {
+		link.Backup, link.Trashed = l.Backup, l.Trashed
+	}
+	link.Created = time.Now()
+	st.add(link)
+}
+
+// add puts a record in the manifest, replacing any for the same link path
+func (st *state) add(link managedLink) {
+	index := st.indexed()
+	if i, ok := index[link.Link]; ok {
+		st.Links[i] = link
+	} else {
+		index[link.Link] = len(st.Links)
+		st.Links = append(st.Links, link)
+	}
+	st.dirty = true
+}
+
+// forget removes a link from the manifest. The order of Links isn't kept; save sorts them.
+func (st *state) forget(link string) {
+	link = absPath(link)
+	index := st.indexed()
+	i, ok := index[link]
+	if !ok {
+		return
+	}
+	last := len(st.Links) - 1
+	st.Links[i] = st.Links[last]
+	index[st.Links[i].Link] = i
+	st.Links = st.Links[:last]
+	delete(index, link)
+	st.dirty = true
+}
+
+// lookup returns the manifest record for a link path
+func (st *state) lookup(link string) (managedLink, bool) {
+	if i, ok := st.indexed()[absPath(link)]; ok {
+		return st.Links[i], true
+	}
+	return managedLink{}, false
+}
+
+// indexed returns the index of Links by link path, building it the first time, so
+// lookups stay fast in manifests of tens of thousands of links
+func (st *state) indexed() map[string]int {
+	if st.index == nil {
+		st.index = make(map[string]int, len(st.Links))
+		for i, l := range st.Links {
+			st.index[l.Link] = i
+		}
+	}
+	return st.index
+}
+
+// absPath returns a clean absolute path, or the cleaned input if it can't be resolved
+func absPath(path string) string {
+	if abs, err := filepath.Abs(path); err == nil {
+		return abs
+	}
+	return filepath.Clean(path)
+}
@@ -0,0 +1,150 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+)
+
+// Entry statuses shown by status
+const (
+	entryLinked   = "linked"   // the link path holds the link the config asks for
+	entryMissing  = "missing"  // nothing is at the link path yet
+	entryDiffers  = "differs"  // a symlink to somewhere else is at the link path
+	entryBlocked  = "blocked"  // a file or directory is in the way
+	entryDangling = "dangling" // linked, but the actual path doesn't exist
+	entryOutdated = "outdated" // a written file, such as a copy, that no longer matches the actual path
+	entryModified = "modified" // a written file, such as a rendered template, that was edited in place
+	entryOrphaned = "orphaned" // a managed link the configs no longer list
+	entryPending  = "pending"  // a plugin entry its plugin has yet to put in place
+	entryFailed   = "failed"   // a plugin entry its plugin couldn't check
+)
+
+// statusStyles colors each entry status
+var statusStyles = map[string]string{
+	entryLinked:   styleCreate,
+	entryMissing:  styleReplace,
+	entryDiffers:  styleReplace,
+	entryBlocked:  styleError,
+	entryDangling: styleError,
+	entryOutdated: styleReplace,
+	entryModified: styleError,
+	entryOrphaned: styleSkip,
+	entryPending:  styleReplace,
+	entryFailed:   styleError,
+}
+
+// runStatus shows whether each entry of the configs is in place, without changing anything
+func runStatus(args []string, configFilePath string) error {
+	flags := flag.NewFlagSet("status", flag.ExitOnError)
+	flags.Usage = func() {
+		fmt.Println("Usage: symlinker status [config-file...]")
+		fmt.Println("\nShow whether each entry of the configs is linked, and list managed links the")
+		fmt.Println("configs no longer have (see clean). Exits 1 unless every entry is linked.")
+	}
+	configs := parseArgs(flags, args)
+	if len(configs) == 0 {
+		configs = []string{configFilePath}
+	}
+
+	entries, err := parseConfigs(configs)
+	if err != nil {
+		return err
+	}
+	st, err := loadState()
+	if err != nil {
+		return err
+	}
+
+	counts := map[string]int{}
+	show := func(status, link, target, detail string) {
+		counts[status]++
+		fmt.Printf("%s %s -> %s%s\n", paint(statusStyles[status], fmt.Sprintf("%-8s", status)), contractPath(link), target, detail)
+	}
+	for _, e := range entries {
+		link := underRoot(*rootDir, e.link)
+		status, detail := entryStatus(e, link, st)
+		show(status, link, e.target, detail)
+	}
+	for _, l := range orphanedLinks(configs, entries, st) {
+		show(entryOrphaned, l.Link, l.Target, "")
+	}
+
+	pending := len(entries) - counts[entryLinked]
+	fmt.Printf("\n%d of %d entries linked", counts[entryLinked], len(entries))
+	if counts[entryOrphaned] > 0 {
+		fmt.Printf(", %d orphaned links", counts[entryOrphaned])
+	}
+	fmt.Println()
+	reportRun(statusReport(entries, counts))
+	if pending > 0 {
+		return fmt.Errorf("%d entries not linked", pending)
+	}
+	return nil
+}
+
+// entryStatus says what is at an entry's link path compared with what the config asks for
+func entryStatus(e entry, link string, st *state) (status, detail string) {
+	if isPluginMode(e.options["mode"]) {
+		e.link = link
+		return pluginStatus(e)
+	}
+	info, err := os.Lstat(link)
+	if err != nil {
+		return entryMissing, ""
+	}
+	l, ok := st.lookup(link)
+	managed := ok && isManaged(link, l)
+	if managed && writesFile(l.Mode) && !isOwnLink(link, e.target, l.Mode, st) {
+		return entryOutdated, ""
+	}
+	if managed || isOwnLink(link, e.target, e.options["mode"], st) {
+		if _, err := os.Stat(underRoot(*rootDir, e.target)); err != nil {
+			return entryDangling, ""
+		}
+		return entryLinked, ""
+	}
+	if wasEdited(link, st) {
+		return entryModified, fmt.Sprintf(" (edited since symlinker wrote the %s)", linkNoun(l.Mode))
+	}
+	if kind := fileKind(info); kind != "symlink" {
+		return entryBlocked, fmt.Sprintf(" (a %s is in the way)", kind)
+	}
+	current, _ := os.Readlink(link)
+	return entryDiffers, fmt.Sprintf(" (points to %s)", current)
+}
+
+// pluginStatus asks a plugin entry's plugin whether it is in place
+func pluginStatus(e entry) (status, detail string) {
+	check, err := runPlugin(pluginCheck, e)
+	switch {
+	case err != nil:
+		return entryFailed, " (" + err.Error() + ")"
+	case check.InPlace:
+		return entryLinked, ""
+	case check.Message != "":
+		return entryPending, " (" + check.Message + ")"
+	}
+	return entryPending, ""
+}
+
+// orphanedLinks returns the managed links made from configs that their entries no
+// longer list, such as entries since removed from a config
+func orphanedLinks(configs []string, entries []entry, st *state) []managedLink {
+	fromConfigs := map[string]bool{}
+	for _, config := range configs {
+		fromConfigs[configPath(config)] = true
+	}
+	listed := map[string]bool{}
+	for _, e := range entries {
+		listed[underRoot(*rootDir, e.link)] = true
+	}
+
+	var orphaned []managedLink
+	for _, l := range st.Links {
+		if fromConfigs[l.Config] && !listed[l.Link] {
+			orphaned = append(orphaned, l)
+		}
+	}
+	return orphaned
+}
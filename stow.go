@@ -0,0 +1,200 @@
+package main
+
+import (
+	"bufio"
+	"flag"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+// Ignore patterns GNU Stow uses when a package has no .stow-local-ignore
+var defaultStowIgnore = []string{
+	`RCS`, `.+,v`, `CVS`, `\.\#.+`, `\.cvsignore`, `\.svn`, `_darcs`, `\.hg`,
+	`\.git`, `\.gitignore`, `\.gitmodules`, `.+~`, `\#.*\#`,
+	`^/README.*`, `^/LICENSE.*`, `^/COPYING`,
+}
+
+// runImportStow converts a GNU Stow directory into config lines, or applies it directly
+func runImportStow(args []string, configFilePath string) error {
+	flags := flag.NewFlagSet("import-stow", flag.ExitOnError)
+	target := flags.String("target", "", "Stow target directory (default: parent of the stow directory)")
+	dotfiles := flags.Bool("dotfiles", false, "Translate dot-foo names to .foo, like stow --dotfiles")
+	output := flags.String("output", "", "Write config lines to this file instead of stdout")
+	apply := flags.Bool("apply", false, "Create the symlinks instead of printing config lines")
+	flags.BoolVar(dryRun, "dry-run", *dryRun, "Show what would be done without making changes")
+	flags.Usage = func() {
+		fmt.Println("Usage: symlinker import-stow [flags] <stow-dir> [package...]")
+		fmt.Println("\nConvert a GNU Stow package layout into symlinker config lines.")
+		fmt.Println("All packages in the stow directory are converted unless some are named.")
+		fmt.Println("\nFlags:")
+		flags.PrintDefaults()
+	}
+	rest := parseArgs(flags, args)
+
+	if len(rest) == 0 {
+		flags.Usage()
+		return fmt.Errorf("import-stow requires a stow directory")
+	}
+
+	stowDir, err := filepath.Abs(expandPath(rest[0]))
+	if err != nil {
+		return fmt.Errorf("error resolving stow directory: %w", err)
+	}
+	targetDir := filepath.Dir(stowDir)
+	if *target != "" {
+		if targetDir, err = filepath.Abs(expandPath(*target)); err != nil {
+			return fmt.Errorf("error resolving target directory: %w", err)
+		}
+	}
+
+	packages := rest[1:]
+	if len(packages) == 0 {
+		if packages, err = stowPackages(stowDir); err != nil {
+			return err
+		}
+	}
+
+	var entries []entry
+	for _, pkg := range packages {
+		pkgEntries, err := stowPackageEntries(filepath.Join(stowDir, pkg), targetDir, *dotfiles)
+		if err != nil {
+			return err
+		}
+		entries = append(entries, pkgEntries...)
+	}
+
+	if *apply {
+		opts, err := optionsFromFlags()
+		if err != nil {
+			return err
+		}
+		return applyEntries(entries, opts)
+	}
+
+	return writeConfigLines(*output, fmt.Sprintf("Imported from GNU Stow directory %s", contractPath(stowDir)), entries)
+}
+
+// stowPackages lists the package directories in a stow directory
+func stowPackages(stowDir string) ([]string, error) {
+	dirEntries, err := os.ReadDir(stowDir)
+	if err != nil {
+		return nil, fmt.Errorf("error reading stow directory: %w", err)
+	}
+
+	var packages []string
+	for _, d := range dirEntries {
+		if d.IsDir() && !strings.HasPrefix(d.Name(), ".") {
+			packages = append(packages, d.Name())
+		}
+	}
+	return packages, nil
+}
+
+// stowPackageEntries returns an entry for every file in a stow package
+func stowPackageEntries(pkgDir, targetDir string, dotfiles bool) ([]entry, error) {
+	ignore, err := stowIgnorePatterns(pkgDir)
+	if err != nil {
+		return nil, err
+	}
+
+	var entries []entry
+	err = filepath.WalkDir(pkgDir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if path == pkgDir {
+			return nil
+		}
+
+		rel, err := filepath.Rel(pkgDir, path)
+		if err != nil {
+			return err
+		}
+		if stowIgnored(ignore, rel) {
+			if d.IsDir() {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if d.IsDir() {
+			return nil
+		}
+
+		linkRel := rel
+		if dotfiles {
+			linkRel = stowDotfilesName(rel)
+		}
+		if strings.ContainsAny(path+linkRel, "\r\n") {
+			warnf("skipping path containing a line break: %q\n", path)
+			return nil
+		}
+
+		entries = append(entries, entry{link: filepath.Join(targetDir, linkRel), target: path, config: pkgDir})
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("error reading stow package %s: %w", pkgDir, err)
+	}
+	return entries, nil
+}
+
+// stowIgnorePatterns loads a package's .stow-local-ignore, falling back to Stow's defaults
+func stowIgnorePatterns(pkgDir string) ([]*regexp.Regexp, error) {
+	patterns := append([]string{`\.stow-local-ignore`}, defaultStowIgnore...)
+
+	if file, err := os.Open(filepath.Join(pkgDir, ".stow-local-ignore")); err == nil {
+		defer file.Close()
+		patterns = []string{`\.stow-local-ignore`}
+		scanner := bufio.NewScanner(file)
+		for scanner.Scan() {
+			line := strings.TrimSpace(scanner.Text())
+			if line != "" && !strings.HasPrefix(line, "#") {
+				patterns = append(patterns, line)
+			}
+		}
+		if err := scanner.Err(); err != nil {
+			return nil, fmt.Errorf("error reading .stow-local-ignore: %w", err)
+		}
+	}
+
+	compiled := make([]*regexp.Regexp, 0, len(patterns))
+	for _, p := range patterns {
+		re, err := regexp.Compile("^(?:" + strings.TrimPrefix(p, "^") + ")$")
+		if err != nil {
+			return nil, fmt.Errorf("invalid ignore pattern %q: %w", p, err)
+		}
+		compiled = append(compiled, re)
+	}
+	return compiled, nil
+}
+
+// stowIgnored applies Stow's matching rules: patterns containing a slash match the
+// package-relative path, all others match the basename
+func stowIgnored(patterns []*regexp.Regexp, rel string) bool {
+	rel = filepath.ToSlash(rel)
+	for _, re := range patterns {
+		subject := filepath.Base(rel)
+		if strings.Contains(re.String(), "/") {
+			subject = "/" + rel
+		}
+		if re.MatchString(subject) {
+			return true
+		}
+	}
+	return false
+}
+
+// stowDotfilesName translates each dot- prefixed path component to a leading dot
+func stowDotfilesName(rel string) string {
+	parts := strings.Split(rel, string(filepath.Separator))
+	for i, part := range parts {
+		if strings.HasPrefix(part, "dot-") {
+			parts[i] = "." + strings.TrimPrefix(part, "dot-")
+		}
+	}
+	return filepath.Join(parts...)
+}
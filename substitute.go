@@ -0,0 +1,65 @@
+package main
+
+import (
+	"os"
+	"regexp"
+	"strings"
+	"sync"
+)
+
+// commandSubstRegex matches a $(command) substitution; commands can't nest
+var commandSubstRegex = regexp.MustCompile(`\$\(([^()]*)\)`)
+
+// commandOutputs caches what each substituted command printed, so a command used on
+// many lines runs once
+var (
+	commandOutputs   = map[string]string{}
+	commandOutputsMu sync.Mutex
+)
+
+// substituteCommands replaces each $(command) in path with the command's output, as
+// the shell would, and passes the rest of path through expand. A command that fails
+// is left in place, so the path shows up as unexpanded.
+func substituteCommands(path string, expand func(string) string) string {
+	var b strings.Builder
+	last := 0
+	for _, loc := range commandSubstRegex.FindAllStringSubmatchIndex(path, -1) {
+		b.WriteString(expand(path[last:loc[0]]))
+		if out, ok := commandOutput(path[loc[2]:loc[3]]); ok {
+			b.WriteString(out)
+		} else {
+			b.WriteString(path[loc[0]:loc[1]])
+		}
+		last = loc[1]
+	}
+	b.WriteString(expand(path[last:]))
+	return b.String()
+}
+
+// commandOutput runs command through the shell and returns its output without trailing
+// newlines, reporting whether it succeeded
+func commandOutput(command string) (string, bool) {
+	commandOutputsMu.Lock()
+	defer commandOutputsMu.Unlock()
+	if out, ok := commandOutputs[command]; ok {
+		return out, true
+	}
+
+	cmd := shellCommand(command)
+	cmd.Stderr = os.Stderr
+	data, err := cmd.Output()
+	if err != nil {
+		warnf("command in path failed: $(%s): %s\n", command, err)
+		return "", false
+	}
+	out := strings.TrimRight(string(data), "\r\n")
+	commandOutputs[command] = out
+
+	// A dry run says which commands it ran, since they run even then
+	if *dryRun {
+		infof("[DRY RUN] Ran $(%s) for a path: %s\n", command, out)
+	} else {
+		verbosef("Ran $(%s) for a path: %s\n", command, out)
+	}
+	return out, true
+}
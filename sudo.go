@@ -0,0 +1,71 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"os/exec"
+	"runtime"
+)
+
+// canEscalate reports whether entries denied for lack of permission can be retried with sudo
+func canEscalate() bool {
+	if runtime.GOOS == "windows" || os.Geteuid() == 0 {
+		return false
+	}
+	_, err := exec.LookPath("sudo")
+	return err == nil
+}
+
+// runWithSudo re-runs entries that failed with permission errors as root, asking first
+// unless always is set
+func runWithSudo(entries []entry, always bool) error {
+	if !always && !confirm(fmt.Sprintf("%d links need root. Re-run them with sudo?", len(entries)), false) {
+		return fmt.Errorf("permission denied for %d links (use --sudo to create them as root)", len(entries))
+	}
+
+	// Hand root a config with just these entries, already expanded, so it is read with
+	// --no-expand and paths are quoted where they hold spaces or braces
+	tmp, err := os.CreateTemp("", "symlinker-sudo-*.conf")
+	if err != nil {
+		return fmt.Errorf("error creating config for sudo: %w", err)
+	}
+	defer os.Remove(tmp.Name())
+	for _, e := range entries {
+		fmt.Fprintf(tmp, "%s %s%s\n", quoteField(e.link), quoteField(e.target), formatOptions(e.options))
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("error writing config for sudo: %w", err)
+	}
+
+	exe, err := os.Executable()
+	if err != nil {
+		return err
+	}
+	// sudo resets $HOME and the XDG variables, so the root run is told where this run's
+	// state is, to record its links there under this run's lock
+	state, err := stateDir()
+	if err != nil {
+		return err
+	}
+	args := []string{"--", exe, "--no-expand", "--state-dir=" + state}
+	flag.Visit(func(f *flag.Flag) {
+		// The root run reports in text; its actions are part of this run's report,
+		// and the global hooks, notification and fleet reports happen once, in this run
+		switch f.Name {
+		case "sudo", "output", "porcelain", "pre", "post", "notify", "report-url", "metrics-file", "changed-exit-code", "no-expand", "state-dir":
+		default:
+			args = append(args, "--"+f.Name+"="+f.Value.String())
+		}
+	})
+	args = append(args, tmp.Name())
+
+	infof("Running as root: sudo %s ...\n", exe)
+	cmd := exec.Command("sudo", args...)
+	cmd.Stdin, cmd.Stdout, cmd.Stderr = os.Stdin, os.Stdout, os.Stderr
+	flushConsole()
+	if err := cmd.Run(); err != nil {
+		return fmt.Errorf("error running sudo: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,103 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"os/user"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"text/template"
+)
+
+// templateFacts is what mode=template entries are rendered with
+type templateFacts struct {
+	Hostname string
+	OS       string // as runtime.GOOS: linux, darwin, windows, ...
+	Arch     string // as runtime.GOARCH: amd64, arm64, ...
+	User     string
+	Home     string
+	Env      map[string]string
+}
+
+// machineFacts gathers the facts about this machine that templates can use
+func machineFacts() templateFacts {
+	facts := templateFacts{OS: runtime.GOOS, Arch: runtime.GOARCH, Env: map[string]string{}}
+	facts.Hostname, _ = os.Hostname()
+	facts.Home, _ = os.UserHomeDir()
+	if switchedUser != nil {
+		facts.User = switchedUser.name
+	} else if u, err := user.Current(); err == nil {
+		facts.User = u.Username
+	}
+	for _, kv := range os.Environ() {
+		if name, value, ok := strings.Cut(kv, "="); ok {
+			facts.Env[name] = value
+		}
+	}
+	return facts
+}
+
+// templateFuncs are the functions templates can call besides the built-in ones
+var templateFuncs = template.FuncMap{
+	"env": os.Getenv,
+}
+
+// renderTemplate renders the Go template in target. A missing fact or map key is an
+// error rather than "<no value>".
+func renderTemplate(target string) ([]byte, error) {
+	text, err := os.ReadFile(target)
+	if err != nil {
+		return nil, err
+	}
+	tmpl, err := template.New(filepath.Base(target)).Funcs(templateFuncs).Option("missingkey=error").Parse(string(text))
+	if err != nil {
+		return nil, err
+	}
+	var out bytes.Buffer
+	if err := tmpl.Execute(&out, machineFacts()); err != nil {
+		return nil, err
+	}
+	return out.Bytes(), nil
+}
+
+// renderedHash returns the content digest that rendering target would produce, as
+// contentHash gives for the file it is written to
+func renderedHash(target string) (string, error) {
+	out, err := renderTemplate(target)
+	if err != nil {
+		return "", err
+	}
+	return dataHash(out), nil
+}
+
+// renderTemplateFile renders target into a new file at path with the template's
+// permissions, through a temporary file next to it
+func renderTemplateFile(target, path string) error {
+	out, err := renderTemplate(target)
+	if err != nil {
+		return fmt.Errorf("error rendering %s: %w", target, err)
+	}
+	info, err := os.Stat(target)
+	if err != nil {
+		return err
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(path), "."+filepath.Base(path)+".symlinker-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	_, err = tmp.Write(out)
+	if closeErr := tmp.Close(); err == nil {
+		err = closeErr
+	}
+	if err == nil {
+		err = os.Chmod(tmp.Name(), info.Mode().Perm())
+	}
+	if err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
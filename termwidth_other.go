@@ -0,0 +1,13 @@
+//go:build !(darwin || dragonfly || freebsd || linux || netbsd || openbsd)
+
+package main
+
+// terminalWidth returns the width of the terminal as given by $COLUMNS
+func terminalWidth() int {
+	return columnsWidth()
+}
+
+// terminalHeight returns the number of lines of the terminal as given by $LINES
+func terminalHeight() int {
+	return linesHeight()
+}
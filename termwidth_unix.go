@@ -0,0 +1,37 @@
+//go:build darwin || dragonfly || freebsd || linux || netbsd || openbsd
+
+package main
+
+import (
+	"os"
+	"syscall"
+	"unsafe"
+)
+
+// terminalWidth returns the width of the terminal on stdout
+func terminalWidth() int {
+	size, ok := windowSize()
+	if !ok || size.cols == 0 {
+		return columnsWidth()
+	}
+	return int(size.cols)
+}
+
+// terminalHeight returns the number of lines of the terminal on stdout
+func terminalHeight() int {
+	size, ok := windowSize()
+	if !ok || size.rows == 0 {
+		return linesHeight()
+	}
+	return int(size.rows)
+}
+
+// winsize is the terminal size as TIOCGWINSZ reports it
+type winsize struct{ rows, cols, xpixel, ypixel uint16 }
+
+// windowSize asks the terminal on stdout for its size
+func windowSize() (winsize, bool) {
+	var size winsize
+	_, _, errno := syscall.Syscall(syscall.SYS_IOCTL, os.Stdout.Fd(), uintptr(syscall.TIOCGWINSZ), uintptr(unsafe.Pointer(&size)))
+	return size, errno == 0
+}
@@ -0,0 +1,125 @@
+package main
+
+import (
+	"fmt"
+	"net/url"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// moveToTrash moves path into the desktop trash and returns where it went
+func moveToTrash(path string, dryRun bool) (string, error) {
+	path = absPath(path)
+	switch runtime.GOOS {
+	case "darwin":
+		return moveToMacTrash(path, dryRun)
+	case "windows", "plan9", "js", "wasip1":
+		return "", fmt.Errorf("--trash is not supported on %s", runtime.GOOS)
+	}
+	return moveToXDGTrash(path, dryRun)
+}
+
+// moveToXDGTrash follows the freedesktop.org Trash specification for the home trash
+func moveToXDGTrash(path string, dryRun bool) (string, error) {
+	dataHome := os.Getenv("XDG_DATA_HOME")
+	if dataHome == "" {
+		home, err := os.UserHomeDir()
+		if err != nil {
+			return "", fmt.Errorf("error finding trash directory: %w", err)
+		}
+		dataHome = filepath.Join(home, ".local", "share")
+	}
+	filesDir := filepath.Join(dataHome, "Trash", "files")
+	infoDir := filepath.Join(dataHome, "Trash", "info")
+
+	name := uniqueTrashName(filesDir, filepath.Base(path), func(candidate string) bool {
+		_, err := os.Lstat(filepath.Join(infoDir, candidate+".trashinfo"))
+		return err == nil
+	})
+	dest := filepath.Join(filesDir, name)
+
+	if dryRun {
+		infof("[DRY RUN] Would move existing to trash: %s -> %s\n", path, dest)
+		return dest, nil
+	}
+
+	infof("Moving existing to trash: %s -> %s\n", path, dest)
+	if err := os.MkdirAll(filesDir, 0700); err != nil {
+		return "", fmt.Errorf("error creating trash directory: %w", err)
+	}
+	if err := os.MkdirAll(infoDir, 0700); err != nil {
+		return "", fmt.Errorf("error creating trash directory: %w", err)
+	}
+
+	// The info file is written first, as the spec requires
+	info := fmt.Sprintf("[Trash Info]\nPath=%s\nDeletionDate=%s\n",
+		(&url.URL{Path: path}).EscapedPath(), time.Now().Format("2006-01-02T15:04:05"))
+	infoPath := filepath.Join(infoDir, name+".trashinfo")
+	if err := os.WriteFile(infoPath, []byte(info), 0600); err != nil {
+		return "", fmt.Errorf("error writing trash info: %w", err)
+	}
+	if err := auditMove(path, dest); err != nil {
+		os.Remove(infoPath)
+		return "", fmt.Errorf("error moving %s to trash: %w", path, err)
+	}
+	return dest, nil
+}
+
+// restoreFromTrash moves a trashed path back to where it was, removing its .trashinfo
+// file so the desktop trash no longer lists it
+func restoreFromTrash(trashed, path string) error {
+	if err := movePath(trashed, path); err != nil {
+		return err
+	}
+	// Only the freedesktop.org trash keeps info files: Trash/files/NAME has Trash/info/NAME.trashinfo
+	if filesDir := filepath.Dir(trashed); filepath.Base(filesDir) == "files" {
+		info := filepath.Join(filepath.Dir(filesDir), "info", filepath.Base(trashed)+".trashinfo")
+		if err := os.Remove(info); err != nil && !os.IsNotExist(err) {
+			return fmt.Errorf("error removing trash info: %w", err)
+		}
+	}
+	return nil
+}
+
+// moveToMacTrash moves path into ~/.Trash
+func moveToMacTrash(path string, dryRun bool) (string, error) {
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return "", fmt.Errorf("error finding trash directory: %w", err)
+	}
+	trashDir := filepath.Join(home, ".Trash")
+	dest := filepath.Join(trashDir, uniqueTrashName(trashDir, filepath.Base(path), nil))
+
+	if dryRun {
+		infof("[DRY RUN] Would move existing to trash: %s -> %s\n", path, dest)
+		return dest, nil
+	}
+
+	infof("Moving existing to trash: %s -> %s\n", path, dest)
+	if err := auditMove(path, dest); err != nil {
+		return "", fmt.Errorf("error moving %s to trash: %w", path, err)
+	}
+	return dest, nil
+}
+
+// uniqueTrashName returns name, or name with a numeric suffix, that is free in dir
+func uniqueTrashName(dir, name string, taken func(string) bool) string {
+	ext := filepath.Ext(name)
+	base := strings.TrimSuffix(name, ext)
+	if base == "" {
+		base, ext = name, ""
+	}
+
+	candidate := name
+	for i := 2; ; i++ {
+		_, err := os.Lstat(filepath.Join(dir, candidate))
+		if os.IsNotExist(err) && (taken == nil || !taken(candidate)) {
+			return candidate
+		}
+		candidate = base + "." + strconv.Itoa(i) + ext
+	}
+}
@@ -0,0 +1,245 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+	"unicode/utf8"
+)
+
+// Terminal control sequences used by the tui
+const (
+	termAltScreen  = "\x1b[?1049h\x1b[?25l" // switch to the alternate screen and hide the cursor
+	termMainScreen = "\x1b[?25h\x1b[?1049l" // show the cursor and switch back
+	termClear      = "\x1b[H\x1b[2J"
+)
+
+// tuiHelp lists the keys of the checklist
+const tuiHelp = "↑/↓ move  space toggle  a all  n none  i inspect  enter apply selected  q quit"
+
+// tuiItem is one entry of the checklist with its state when last checked
+type tuiItem struct {
+	e        entry
+	status   string
+	detail   string
+	selected bool
+}
+
+// tuiList is the checklist of entries and where the cursor is in it
+type tuiList struct {
+	items   []tuiItem
+	cursor  int
+	top     int    // first item on screen
+	message string // shown above the key help until the next key press
+}
+
+// runTUI shows the configs' entries as a checklist on the terminal, applying the ones
+// picked, so a cluttered home directory can be taken over a few entries at a time
+func runTUI(args []string, configFilePath string) error {
+	flags := flag.NewFlagSet("tui", flag.ExitOnError)
+	flags.Usage = func() {
+		fmt.Println("Usage: symlinker tui [config-file...]")
+		fmt.Println("\nShow the configs' entries as a checklist with the state of each, pick the ones to")
+		fmt.Println("apply and apply them. Entries that aren't in place are picked to begin with; ones")
+		fmt.Println("with something in the way are left for you to inspect and pick.")
+		fmt.Println("\nThe flags for applying configs, such as --backup, go before \"tui\".")
+	}
+	configs := parseArgs(flags, args)
+	if len(configs) == 0 {
+		configs = []string{configFilePath}
+	}
+	if !isTerminal(os.Stdin) || !isTerminal(os.Stdout) {
+		return withExitCode(exitEnv, fmt.Errorf("tui needs a terminal; use --interactive to confirm each change instead"))
+	}
+
+	opts, err := optionsFromFlags()
+	if err != nil {
+		return err
+	}
+	entries, err := parseConfigs(configs)
+	if err != nil {
+		return err
+	}
+	if len(entries) == 0 {
+		return withExitCode(exitConfig, fmt.Errorf("the configs have no entries"))
+	}
+
+	list := &tuiList{}
+	for {
+		st, err := loadState()
+		if err != nil {
+			return err
+		}
+		list.refresh(entries, st)
+
+		picked, err := list.run(st)
+		if err != nil || picked == nil {
+			return err
+		}
+		run, err := opts.nextRun()
+		if err == nil {
+			err = applyEntries(picked, run)
+		}
+		if err != nil {
+			errorf("%s\n", err)
+		}
+		if answer, err := ask("\nPress Enter to go back to the list, or q to quit"); err != nil || strings.EqualFold(answer, "q") {
+			return nil
+		}
+	}
+}
+
+// refresh checks the state of every entry, picking the ones that aren't in place and
+// have nothing in the way
+func (l *tuiList) refresh(entries []entry, st *state) {
+	l.items = make([]tuiItem, len(entries))
+	for i, e := range entries {
+		link := underRoot(*rootDir, e.link)
+		linked := e
+		linked.link = link
+		it := tuiItem{e: e}
+		it.status, it.detail = entryStatus(linked, link, st)
+		switch it.status {
+		case entryMissing, entryOutdated, entryPending:
+			it.selected = true
+		}
+		l.items[i] = it
+	}
+	l.cursor = min(l.cursor, len(l.items)-1)
+}
+
+// run shows the checklist until entries are picked for applying, which it returns, or
+// the user quits, when it returns nil
+func (l *tuiList) run(st *state) ([]entry, error) {
+	restore, err := makeRaw(os.Stdin)
+	if err != nil {
+		return nil, withExitCode(exitEnv, err)
+	}
+	flushConsole()
+	fmt.Print(termAltScreen)
+	defer func() {
+		fmt.Print(termMainScreen)
+		restore()
+	}()
+
+	buf := make([]byte, 16)
+	for {
+		l.draw()
+		n, err := stdinReader.Read(buf)
+		if err != nil {
+			return nil, err
+		}
+		l.message = ""
+		switch key := string(buf[:n]); key {
+		case "q", "\x1b", "\x03": // q, Esc, Ctrl-C
+			return nil, nil
+		case "j", "\x1b[B", "\x1bOB":
+			l.move(1)
+		case "k", "\x1b[A", "\x1bOA":
+			l.move(-1)
+		case "\x1b[6~":
+			l.move(l.rows())
+		case "\x1b[5~":
+			l.move(-l.rows())
+		case "g", "\x1b[H", "\x1bOH":
+			l.move(-len(l.items))
+		case "G", "\x1b[F", "\x1bOF":
+			l.move(len(l.items))
+		case " ":
+			l.items[l.cursor].selected = !l.items[l.cursor].selected
+			l.move(1)
+		case "a", "n":
+			for i := range l.items {
+				l.items[i].selected = key == "a"
+			}
+		case "i", "\x1b[C", "\x1bOC":
+			l.inspect(st)
+		case "\r", "\n":
+			var picked []entry
+			for _, it := range l.items {
+				if it.selected {
+					picked = append(picked, it.e)
+				}
+			}
+			if len(picked) > 0 {
+				return picked, nil
+			}
+			l.message = "Nothing is selected; pick entries with space, or a for all."
+		}
+	}
+}
+
+// rows returns how many entries fit on the screen below the header and above the help
+func (l *tuiList) rows() int {
+	return max(terminalHeight()-4, 1)
+}
+
+// move moves the cursor by delta entries, scrolling to keep it on the screen
+func (l *tuiList) move(delta int) {
+	l.cursor = max(0, min(l.cursor+delta, len(l.items)-1))
+	rows := l.rows()
+	if l.cursor < l.top {
+		l.top = l.cursor
+	}
+	if l.cursor >= l.top+rows {
+		l.top = l.cursor - rows + 1
+	}
+}
+
+// draw redraws the checklist
+func (l *tuiList) draw() {
+	width := terminalWidth()
+	selected := 0
+	for _, it := range l.items {
+		if it.selected {
+			selected++
+		}
+	}
+
+	var b strings.Builder
+	b.WriteString(termClear)
+	fmt.Fprintf(&b, "symlinker: %d entries, %d selected\n\n", len(l.items), selected)
+	l.move(0)
+	for i := l.top; i < min(len(l.items), l.top+l.rows()); i++ {
+		it := l.items[i]
+		box := "[ ]"
+		if it.selected {
+			box = "[x]"
+		}
+		line := truncate(fmt.Sprintf(" %s %-8s %s -> %s%s", box, it.status, contractPath(it.e.link), it.e.target, it.detail), width-1)
+		if i == l.cursor {
+			line = paint(styleCursor, ">"+line)
+		} else {
+			line = " " + line
+		}
+		b.WriteString(line + "\n")
+	}
+	b.WriteString("\n")
+	if l.message != "" {
+		b.WriteString(l.message + "  ")
+	}
+	b.WriteString(paint(styleSkip, truncate(tuiHelp, width-len(l.message)-3)))
+	fmt.Print(b.String())
+}
+
+// inspect shows what explain would about the entry under the cursor, until a key is pressed
+func (l *tuiList) inspect(st *state) {
+	e := l.items[l.cursor].e
+	fmt.Print(termClear)
+	printExplanation(absPath(e.link), []entry{e}, st)
+	fmt.Print("\n" + paint(styleSkip, "Press any key to go back"))
+	stdinReader.Read(make([]byte, 16))
+}
+
+// truncate shortens s to at most width characters, marking the cut with …
+func truncate(s string, width int) string {
+	if width <= 0 {
+		return ""
+	}
+	if utf8.RuneCountInString(s) <= width {
+		return s
+	}
+	runes := []rune(s)
+	return string(runes[:max(width-1, 0)]) + "…"
+}
@@ -0,0 +1,253 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+)
+
+// runUndo reverts the most recent run that hasn't been undone
+func runUndo(args []string, configFilePath string) error {
+	flags := flag.NewFlagSet("undo", flag.ExitOnError)
+	list := flags.Bool("list", false, "List recorded runs instead of undoing")
+	flags.BoolVar(dryRun, "dry-run", *dryRun, "Show what would be done without making changes")
+	flags.Usage = func() {
+		fmt.Println("Usage: symlinker undo [flags]")
+		fmt.Println("\nRevert the changes made by the most recent run.")
+		fmt.Println("\nFlags:")
+		flags.PrintDefaults()
+	}
+	parseArgs(flags, args)
+
+	if !*dryRun {
+		unlock, err := acquireLock()
+		if err != nil {
+			return err
+		}
+		defer unlock()
+	}
+
+	history, err := loadHistory()
+	if err != nil {
+		return err
+	}
+
+	if *list {
+		for _, j := range history {
+			status := ""
+			if j.Undone {
+				status = " (undone)"
+			}
+			fmt.Printf("%s  %-10s %d changes%s\n", j.Started.Format("2006-01-02 15:04:05"), j.Command, len(j.Ops), status)
+			for _, op := range j.Ops {
+				fmt.Printf("    %s\n", op.describe())
+			}
+		}
+		return nil
+	}
+
+	var last *journal
+	for _, j := range history {
+		if !j.Undone {
+			last = j
+			break
+		}
+	}
+	if last == nil {
+		infof("Nothing to undo.\n")
+		return nil
+	}
+
+	st, err := loadState()
+	if err != nil {
+		return err
+	}
+
+	infof("Undoing %s run from %s (%d changes)\n", last.Command, last.Started.Format("2006-01-02 15:04:05"), len(last.Ops))
+	failed := 0
+	for i := len(last.Ops) - 1; i >= 0; i-- {
+		if err := undoOp(last.Ops[i], st, *dryRun); err != nil {
+			errorf("%s\n", err)
+			failed++
+		}
+	}
+
+	if *dryRun {
+		infof("[DRY RUN] Undo complete! (No changes made)\n")
+		return nil
+	}
+
+	if err := st.save(); err != nil {
+		return err
+	}
+	last.Undone = true
+	if err := last.save(); err != nil {
+		return err
+	}
+	if failed > 0 {
+		return fmt.Errorf("failed to undo %d changes", failed)
+	}
+	infof("Undo complete!\n")
+	return nil
+}
+
+// undoOp reverts a single journal operation
+func undoOp(op journalOp, st *state, dryRun bool) error {
+	switch op.Op {
+	case opMkdir:
+		report(dryRun, "remove directory", "Removing directory", op.Path)
+		if dryRun {
+			return nil
+		}
+		// Only remove directories that are still empty
+		if err := auditRemove(op.Path, os.Remove); err != nil && !os.IsNotExist(err) {
+			warnf("Leaving %s: %s\n", op.Path, err)
+		}
+		return nil
+
+	case opTouch:
+		// Only remove files nobody has filled in since
+		if info, err := os.Stat(op.Path); err != nil || info.Size() > 0 {
+			warnf("Leaving %s: changed since the run\n", op.Path)
+			return nil
+		}
+		report(dryRun, "remove empty file", "Removing empty file", op.Path)
+		if dryRun {
+			return nil
+		}
+		if err := auditRemove(op.Path, os.Remove); err != nil {
+			return fmt.Errorf("error removing %s: %w", op.Path, err)
+		}
+		return nil
+
+	case opCreate, opReplace:
+		removed, err := removeOwnLink(op, dryRun)
+		if err != nil {
+			return err
+		}
+		if op.Op == opReplace {
+			if err := restoreOriginal(op, removed && dryRun, dryRun); err != nil {
+				return err
+			}
+		}
+
+	case opMove:
+		report(dryRun, "move back", "Moving back", op.Target+" -> "+op.Path)
+		if dryRun {
+			return nil
+		}
+		if _, err := os.Lstat(op.Path); err == nil {
+			return fmt.Errorf("cannot move %s back: %s exists", op.Target, op.Path)
+		}
+		if err := auditMove(op.Target, op.Path); err != nil {
+			return fmt.Errorf("error moving %s back: %w", op.Target, err)
+		}
+
+	case opRemove:
+		if op.Backup != "" {
+			report(dryRun, "move back to backup", "Moving back to backup", op.Path+" -> "+op.Backup)
+			if !dryRun {
+				if err := auditMove(op.Path, op.Backup); err != nil {
+					return fmt.Errorf("error moving %s back to backup: %w", op.Path, err)
+				}
+			}
+		}
+		if op.Trashed != "" {
+			// The trash may give it another name this time
+			trashed, err := moveToTrash(op.Path, dryRun)
+			if err != nil {
+				return err
+			}
+			if op.Managed != nil {
+				op.Managed.Trashed = trashed
+			}
+		}
+		report(dryRun, "recreate "+linkNoun(op.Mode), "Recreating "+linkNoun(op.Mode), op.Path+" -> "+op.Previous)
+		if !dryRun {
+			if err := makeLink(op.Previous, op.Path, op.Mode); err != nil {
+				return fmt.Errorf("error recreating %s: %w", op.Path, err)
+			}
+		}
+
+	default:
+		return fmt.Errorf("unknown journal operation %q for %s", op.Op, op.Path)
+	}
+
+	// Put the state manifest back the way it was for this path
+	if !dryRun && op.Op != opMove {
+		st.forget(op.Path)
+		if op.Managed != nil {
+			st.add(*op.Managed)
+		}
+	}
+	return nil
+}
+
+// removeOwnLink removes the link an operation created if it is unchanged, reporting whether it did
+func removeOwnLink(op journalOp, dryRun bool) (bool, error) {
+	path := op.Path
+	if _, err := os.Lstat(path); os.IsNotExist(err) {
+		return false, nil
+	}
+	if !isManaged(path, managedLink{Target: op.Target, Mode: op.Mode, Hash: op.Hash}) {
+		warnf("Leaving %s: changed since the run\n", path)
+		return false, nil
+	}
+
+	report(dryRun, "remove "+linkNoun(op.Mode), "Removing "+linkNoun(op.Mode), path)
+	if dryRun {
+		return true, nil
+	}
+	if err := removeLink(path, op.Mode); err != nil {
+		return false, fmt.Errorf("error removing %s: %w", path, err)
+	}
+	return true, nil
+}
+
+// restoreOriginal puts back whatever a replace operation displaced.
+// freed tells a dry run that the path would have been cleared first.
+func restoreOriginal(op journalOp, freed, dryRun bool) error {
+	if _, err := os.Lstat(op.Path); err == nil && !freed {
+		warnf("Not restoring %s: path is in use\n", op.Path)
+		return nil
+	}
+
+	switch {
+	case op.Backup != "":
+		report(dryRun, "restore backup", "Restoring backup", op.Backup+" -> "+op.Path)
+		if dryRun {
+			return nil
+		}
+		if err := movePath(op.Backup, op.Path); err != nil {
+			return fmt.Errorf("error restoring backup of %s: %w", op.Path, err)
+		}
+	case op.Trashed != "":
+		report(dryRun, "restore from trash", "Restoring from trash", op.Trashed+" -> "+op.Path)
+		if dryRun {
+			return nil
+		}
+		if err := restoreFromTrash(op.Trashed, op.Path); err != nil {
+			return fmt.Errorf("error restoring %s from trash: %w", op.Path, err)
+		}
+	case op.Kind == "symlink":
+		report(dryRun, "restore symlink", "Restoring symlink", op.Path+" -> "+op.Previous)
+		if dryRun {
+			return nil
+		}
+		if err := os.Symlink(op.Previous, op.Path); err != nil {
+			return fmt.Errorf("error restoring %s: %w", op.Path, err)
+		}
+	default:
+		return fmt.Errorf("cannot restore %s: the original %s was deleted and no backup exists", op.Path, op.Kind)
+	}
+	return nil
+}
+
+// report prints an action, phrased as a preview in dry-run mode
+func report(dryRun bool, would, doing, detail string) {
+	if dryRun {
+		infof("[DRY RUN] Would %s: %s\n", would, detail)
+	} else {
+		infof("%s: %s\n", doing, detail)
+	}
+}
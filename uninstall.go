@@ -0,0 +1,156 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+)
+
+// runUninstall removes every link recorded in the state manifest
+func runUninstall(args []string, configFilePath string) error {
+	flags := flag.NewFlagSet("uninstall", flag.ExitOnError)
+	yes := flags.Bool("yes", false, "Don't ask for confirmation")
+	force := flags.Bool("force", false, "Also remove links that were retargeted since symlinker created them")
+	only := flags.String("config", "", "Only remove links created from this config file")
+	flags.BoolVar(dryRun, "dry-run", *dryRun, "Show what would be done without making changes")
+	flags.Usage = func() {
+		fmt.Println("Usage: symlinker uninstall [flags]")
+		fmt.Println("\nRemove every symlink recorded in the state manifest, restoring backed up originals.")
+		fmt.Println("\nFlags:")
+		flags.PrintDefaults()
+	}
+	parseArgs(flags, args)
+
+	if !*dryRun {
+		unlock, err := acquireLock()
+		if err != nil {
+			return err
+		}
+		defer unlock()
+	}
+
+	st, err := loadState()
+	if err != nil {
+		return err
+	}
+
+	var links []managedLink
+	for _, l := range st.Links {
+		if *only == "" || l.Config == configPath(*only) {
+			links = append(links, l)
+		}
+	}
+	if len(links) == 0 {
+		infof("No managed links to remove.\n")
+		return nil
+	}
+
+	if !*dryRun && !*yes && !confirm(fmt.Sprintf("Remove %d managed links?", len(links)), false) {
+		fmt.Println("Aborted.")
+		return nil
+	}
+
+	j := newJournal("uninstall", st)
+	defer j.save()
+
+	failed := 0
+	for _, l := range links {
+		if err := uninstallLink(st, j, l, *force, *dryRun); err != nil {
+			errorf("%s\n", err)
+			failed++
+		}
+	}
+
+	if err := st.save(); err != nil {
+		return err
+	}
+	if failed > 0 {
+		return fmt.Errorf("failed to remove %d links", failed)
+	}
+
+	if *dryRun {
+		infof("[DRY RUN] Uninstall complete! (No changes made)\n")
+	} else {
+		infof("Uninstall complete!\n")
+	}
+	return nil
+}
+
+// uninstallLink removes one managed link and puts its backup back, if it has one
+func uninstallLink(st *state, j *journal, l managedLink, force, dryRun bool) error {
+	info, err := os.Lstat(l.Link)
+	if os.IsNotExist(err) {
+		verbosef("Already removed: %s\n", l.Link)
+		if !dryRun {
+			st.forget(l.Link)
+		}
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("error reading %s: %w", l.Link, err)
+	}
+
+	// Leave alone anything the user has changed since it was linked
+	if l.Mode == modeHardlink || l.Mode == modeJunction || writesFile(l.Mode) {
+		// A file that no longer matches may hold the user's edits; never force it
+		if !isManaged(l.Link, l) {
+			warnf("Skipping %s: changed since symlinker created it\n", l.Link)
+			return nil
+		}
+	} else if info.Mode()&os.ModeSymlink == 0 {
+		warnf("Skipping %s: no longer a symlink\n", l.Link)
+		return nil
+	}
+	if current, err := os.Readlink(l.Link); err == nil && current != l.Target && !force {
+		warnf("Skipping %s: now points to %s (use --force to remove anyway)\n", l.Link, current)
+		return nil
+	}
+
+	hasBackup, hasTrashed := false, false
+	if l.Backup != "" {
+		if _, err := os.Lstat(l.Backup); err == nil {
+			hasBackup = true
+		}
+	}
+	if l.Trashed != "" && !hasBackup {
+		if _, err := os.Lstat(l.Trashed); err == nil {
+			hasTrashed = true
+		}
+	}
+
+	if dryRun {
+		infof("[DRY RUN] Would remove %s: %s\n", linkNoun(l.Mode), l.Link)
+		switch {
+		case hasBackup:
+			infof("[DRY RUN] Would restore backup: %s -> %s\n", l.Backup, l.Link)
+		case hasTrashed:
+			infof("[DRY RUN] Would restore from trash: %s -> %s\n", l.Trashed, l.Link)
+		}
+		return nil
+	}
+
+	infof("Removing %s: %s\n", linkNoun(l.Mode), l.Link)
+	op := journalOp{Op: opRemove, Path: l.Link, Previous: l.Target, Mode: l.Mode, Hash: l.Hash}
+	if err := removeLink(l.Link, l.Mode); err != nil {
+		return fmt.Errorf("error removing %s: %w", l.Link, err)
+	}
+	if hasBackup {
+		infof("Restoring backup: %s -> %s\n", l.Backup, l.Link)
+		if err := movePath(l.Backup, l.Link); err != nil {
+			j.add(op)
+			return fmt.Errorf("error restoring backup of %s: %w", l.Link, err)
+		}
+		op.Backup = l.Backup
+	}
+	if hasTrashed {
+		infof("Restoring from trash: %s -> %s\n", l.Trashed, l.Link)
+		if err := restoreFromTrash(l.Trashed, l.Link); err != nil {
+			j.add(op)
+			return fmt.Errorf("error restoring %s from trash: %w", l.Link, err)
+		}
+		op.Trashed = l.Trashed
+	}
+	j.add(op)
+	st.forget(l.Link)
+	return nil
+}
@@ -0,0 +1,133 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"os/user"
+	"path/filepath"
+	"runtime"
+	"strconv"
+)
+
+// userSwitch is the account --user applies configs for
+type userSwitch struct {
+	name     string
+	uid, gid int
+	home     string   // their home directory, with symlinks resolved
+	dirs     []string // symlinker's own directories under the user's home, given to them after the run
+	missing  []string // directories above those that didn't exist yet, so the run may create them
+}
+
+// switchedUser is set by --user; nil applies configs for the user running symlinker
+var switchedUser *userSwitch
+
+// switchUser points $HOME, $USER and the XDG base directories at another user's
+// account, so configs, ~ and symlinker's own state resolve against their home, and the
+// directories and links created are given to them. Only root can apply for someone else.
+func switchUser(name string) error {
+	if runtime.GOOS == "windows" {
+		return withExitCode(exitEnv, fmt.Errorf("--user is not supported on Windows"))
+	}
+	u, err := user.Lookup(name)
+	if _, numeric := strconv.Atoi(name); err != nil && numeric == nil {
+		u, err = user.LookupId(name)
+	}
+	if err != nil {
+		return withExitCode(exitConfig, fmt.Errorf("invalid --user: %w", err))
+	}
+	if u.HomeDir == "" {
+		return withExitCode(exitEnv, fmt.Errorf("user %s has no home directory", u.Username))
+	}
+	uid, _ := strconv.Atoi(u.Uid)
+	gid, _ := strconv.Atoi(u.Gid)
+	if uid != os.Geteuid() && os.Geteuid() != 0 {
+		return withExitCode(exitEnv, fmt.Errorf("--user %s needs root, to create links in their home and give them to them", u.Username))
+	}
+
+	os.Setenv("HOME", u.HomeDir)
+	os.Setenv("USER", u.Username)
+	os.Setenv("LOGNAME", u.Username)
+	// The caller's base directories would keep state and backups in their own home
+	for _, name := range []string{"XDG_CONFIG_HOME", "XDG_DATA_HOME", "XDG_STATE_HOME", "XDG_CACHE_HOME"} {
+		os.Unsetenv(name)
+	}
+	verbosef("Applying for user %s (uid %d), home %s\n", u.Username, uid, u.HomeDir)
+	if uid == os.Geteuid() {
+		return nil
+	}
+
+	s := &userSwitch{name: u.Username, uid: uid, gid: gid, home: u.HomeDir}
+	if home, err := filepath.EvalSymlinks(u.HomeDir); err == nil {
+		s.home = home
+	}
+	state, err := stateDir()
+	if err != nil {
+		return err
+	}
+	backups, err := defaultBackupRoot()
+	if err != nil {
+		return err
+	}
+	data := filepath.Join(u.HomeDir, ".local", "share")
+	s.dirs = []string{state, filepath.Dir(backups), filepath.Join(data, "Trash", "files"), filepath.Join(data, "Trash", "info")}
+	for _, dir := range s.dirs {
+		for p := filepath.Dir(dir); p != filepath.Dir(p); p = filepath.Dir(p) {
+			if _, err := os.Lstat(p); err == nil {
+				break
+			}
+			s.missing = append(s.missing, p)
+		}
+	}
+	switchedUser = s
+	return nil
+}
+
+// checkUserLink refuses a link path in the --user account's home whose directory a
+// symlink leads somewhere else they don't own. The run is root's, so a symlink they
+// planted, such as ~/.config pointing at /etc, would otherwise have it replace files there.
+func checkUserLink(link string) error {
+	s := switchedUser
+	if s == nil || !isWithin(os.Getenv("HOME"), link) {
+		return nil
+	}
+	dir := filepath.Dir(link)
+	for {
+		if _, err := os.Lstat(dir); err == nil || filepath.Dir(dir) == dir {
+			break
+		}
+		dir = filepath.Dir(dir)
+	}
+	resolved, err := filepath.EvalSymlinks(dir)
+	if err != nil || isWithin(s.home, resolved) {
+		return err
+	}
+	if info, err := os.Stat(resolved); err == nil {
+		if uid, _, ok := ownerOf(info); ok && uid == s.uid {
+			return nil
+		}
+	}
+	return fmt.Errorf("%s leads out of the home of %s, to %s, which isn't theirs", dir, s.name, resolved)
+}
+
+// handOverUserDirs gives the --user account the state, backups and trash this run
+// created in their home as root, so symlinker keeps working when they run it themselves
+func handOverUserDirs() {
+	s := switchedUser
+	if s == nil || *dryRun {
+		return
+	}
+	own := ownership{uid: s.uid, gid: s.gid}
+	for _, dir := range s.dirs {
+		if _, err := os.Lstat(dir); err != nil {
+			continue
+		}
+		if err := chownTree(dir, own); err != nil {
+			warnf("can't give %s to %s: %s\n", dir, s.name, err)
+		}
+	}
+	for _, dir := range s.missing {
+		if err := os.Lchown(dir, s.uid, s.gid); err != nil && !os.IsNotExist(err) {
+			warnf("can't give %s to %s: %s\n", dir, s.name, err)
+		}
+	}
+}
@@ -0,0 +1,108 @@
+package main
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+)
+
+// signatureNamespace is the ssh-keygen -Y namespace config signatures are made in, so a
+// signature made for something else can't pass for one
+const signatureNamespace = "symlinker"
+
+// verifyingConfigs reports whether configs must pass a check before they are read
+func verifyingConfigs() bool {
+	return *configSHA256 != "" || *signingKey != ""
+}
+
+// verifyConfig checks a config's content against --sha256 and, with --signing-key, its
+// SSH signature. sigPath is where the signature is unless --signature says otherwise.
+func verifyConfig(config string, data []byte, sigPath func() (string, error)) error {
+	if want := strings.ToLower(strings.TrimSpace(*configSHA256)); want != "" {
+		sum := sha256.Sum256(data)
+		if got := hex.EncodeToString(sum[:]); got != want {
+			return fmt.Errorf("config %s has SHA-256 %s, not the %s given with --sha256", config, got, want)
+		}
+		verbosef("SHA-256 of %s matches\n", config)
+	}
+	if *signingKey == "" {
+		return nil
+	}
+
+	sig := *signatureFile
+	if sig == "" {
+		if sigPath == nil {
+			return fmt.Errorf("config %s has no signature file next to it; give one with --signature", config)
+		}
+		var err error
+		if sig, err = sigPath(); err != nil {
+			return err
+		}
+	} else if isRemoteConfig(sig) {
+		var err error
+		if sig, err = fetchSignature(sig); err != nil {
+			return err
+		}
+	}
+	if err := verifySSHSignature(data, sig, *signingKey); err != nil {
+		return fmt.Errorf("config %s failed signature check: %w", config, err)
+	}
+	verbosef("Signature of %s is good\n", config)
+	return nil
+}
+
+// verifySSHSignature checks sig with ssh-keygen -Y verify, trusting key alone. key is a
+// public key file or the key itself, as in an authorized_keys line.
+func verifySSHSignature(data []byte, sig, key string) error {
+	keyLine := key
+	if _, err := os.Stat(expandTilde(key)); err == nil {
+		contents, err := os.ReadFile(expandTilde(key))
+		if err != nil {
+			return err
+		}
+		keyLine = strings.TrimSpace(string(contents))
+	}
+
+	// ssh-keygen wants an allowed signers file naming who the key belongs to
+	dir, err := os.MkdirTemp("", "symlinker-verify-*")
+	if err != nil {
+		return err
+	}
+	defer os.RemoveAll(dir)
+	signers := filepath.Join(dir, "allowed_signers")
+	if err := os.WriteFile(signers, []byte("symlinker "+keyLine+"\n"), 0600); err != nil {
+		return err
+	}
+
+	cmd := exec.Command("ssh-keygen", "-Y", "verify", "-f", signers, "-I", "symlinker", "-n", signatureNamespace, "-s", sig)
+	cmd.Stdin = bytes.NewReader(data)
+	if out, err := cmd.CombinedOutput(); err != nil {
+		if msg := strings.TrimSpace(string(out)); msg != "" {
+			return fmt.Errorf("%s", msg)
+		}
+		return err
+	}
+	return nil
+}
+
+// fetchSignature downloads a signature for a remote config into the cache
+func fetchSignature(rawURL string) (string, error) {
+	dir, err := remoteCacheDir()
+	if err != nil {
+		return "", err
+	}
+	sum := sha256.Sum256([]byte(rawURL))
+	cached := filepath.Join(dir, hex.EncodeToString(sum[:8])+".sig")
+	if _, err := download(rawURL, cached, cached+".etag"); err != nil {
+		if _, statErr := os.Stat(cached); statErr != nil {
+			return "", fmt.Errorf("error fetching signature: %w", err)
+		}
+		warnf("using the copy of %s fetched earlier: %s\n", rawURL, err)
+	}
+	return cached, nil
+}
@@ -0,0 +1,87 @@
+package main
+
+import (
+	"fmt"
+	"runtime"
+	"runtime/debug"
+	"sync"
+)
+
+// Build metadata, set at build time with
+// -ldflags "-X main.version=v1.2.0 -X main.commit=$(git rev-parse HEAD) -X main.date=..."
+// Whatever isn't set is taken from the module and VCS information Go embeds.
+var (
+	version string
+	commit  string
+	date    string
+)
+
+// buildInfo says which build of symlinker produced an output
+type buildInfo struct {
+	Version string `json:"version"`
+	Commit  string `json:"commit,omitempty"`
+	Date    string `json:"date,omitempty"`  // when the build was made, or its commit if that isn't known
+	Dirty   bool   `json:"dirty,omitempty"` // built from a work tree with uncommitted changes
+}
+
+// currentBuild returns the metadata of the running binary
+var currentBuild = sync.OnceValue(func() buildInfo {
+	b := buildInfo{Version: version, Commit: commit, Date: date}
+	info, ok := debug.ReadBuildInfo()
+	if !ok {
+		if b.Version == "" {
+			b.Version = "unknown"
+		}
+		return b
+	}
+	if b.Version == "" {
+		b.Version = info.Main.Version
+	}
+	for _, s := range info.Settings {
+		switch s.Key {
+		case "vcs.revision":
+			if b.Commit == "" {
+				b.Commit = s.Value
+			}
+		case "vcs.time":
+			if b.Date == "" {
+				b.Date = s.Value
+			}
+		case "vcs.modified":
+			b.Dirty = commit == "" && s.Value == "true"
+		}
+	}
+	if b.Version == "" {
+		b.Version = "(devel)"
+	}
+	return b
+})
+
+// String gives the version with a short commit, as in "v1.2.0 (3f2a9c1)"
+func (b buildInfo) String() string {
+	if b.Commit == "" {
+		return b.Version
+	}
+	short := b.Commit[:min(len(b.Commit), 7)]
+	if b.Dirty {
+		short += "-dirty"
+	}
+	return fmt.Sprintf("%s (%s)", b.Version, short)
+}
+
+// printVersion prints the build metadata for --version
+func printVersion() {
+	b := currentBuild()
+	fmt.Printf("symlinker %s\n", b.Version)
+	if b.Commit != "" {
+		dirty := ""
+		if b.Dirty {
+			dirty = " (with uncommitted changes)"
+		}
+		fmt.Printf("commit:  %s%s\n", b.Commit, dirty)
+	}
+	if b.Date != "" {
+		fmt.Printf("built:   %s\n", b.Date)
+	}
+	fmt.Printf("go:      %s\n", runtime.Version())
+}
@@ -0,0 +1,257 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"io/fs"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+// runWatch applies configs, then applies them again whenever they or the dotfiles they
+// link change, until interrupted
+func runWatch(args []string, configFilePath string) error {
+	flags := flag.NewFlagSet("watch", flag.ExitOnError)
+	interval := flags.Duration("interval", time.Second, "How often to check for changes where the system can't report them (everywhere but Linux)")
+	debounce := flags.Duration("debounce", 500*time.Millisecond, "How long changes must stop for before applying, so a checkout or a burst of saves applies once")
+	flags.Usage = func() {
+		fmt.Println("Usage: symlinker watch [flags] [config-file...]")
+		fmt.Println("\nApply the configs, then apply them again whenever a config or an actual path")
+		fmt.Println("changes, or files are added next to them. Stop with Ctrl-C.")
+		fmt.Println("\nFlags:")
+		flags.PrintDefaults()
+		fmt.Println("\nThe flags for applying configs, such as --dry-run or --backup, go before \"watch\".")
+	}
+	configs := parseArgs(flags, args)
+	if len(configs) == 0 {
+		configs = []string{configFilePath}
+	}
+	if *interval <= 0 {
+		return withExitCode(exitConfig, fmt.Errorf("--interval must be positive"))
+	}
+
+	opts, err := optionsFromFlags()
+	if err != nil {
+		return err
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+
+	applyAll := func() {
+		run, err := opts.nextRun()
+		if err == nil {
+			err = setupSymlinks(configs, run)
+		}
+		if err != nil {
+			errorf("%s\n", err)
+		}
+	}
+	applyAll()
+	// The configs are only read again after they are applied, not on every change
+	seen := watchSnapshot(configs)
+	n := watchWith(nil, seen, *interval)
+	defer func() { n.close() }()
+	noticef("Watching %d paths for changes (Ctrl-C to stop)\n", len(seen))
+
+	var settled <-chan time.Time // fires once changes have stopped for the debounce time
+	for {
+		select {
+		case <-ctx.Done():
+			infof("Stopped watching.\n")
+			return nil
+		case path := <-n.changes():
+			if settled == nil {
+				infof("\nChanged: %s\n", path)
+			}
+			settled = time.After(*debounce)
+		case <-settled:
+			settled = nil
+			applyAll()
+			seen = watchSnapshot(configs)
+			n = watchWith(n, seen, *interval)
+		}
+	}
+}
+
+// notifier reports changes to the paths it was last given to watch
+type notifier interface {
+	watch(paths map[string]fileStamp) error
+	changes() <-chan string // a path that changed
+	close()
+}
+
+// watchWith has n watch paths, starting with the system's file notifications and
+// falling back to polling them every interval where those aren't available or run out
+func watchWith(n notifier, paths map[string]fileStamp, interval time.Duration) notifier {
+	if n == nil {
+		var err error
+		if n, err = newSystemNotifier(); err != nil {
+			verbosef("Checking for changes every %s: %s\n", interval, err)
+			n = newPoller(interval)
+		}
+	}
+	if err := n.watch(paths); err != nil {
+		warnf("Checking for changes every %s instead: %s\n", interval, err)
+		n.close()
+		n = newPoller(interval)
+		n.watch(paths)
+	}
+	return n
+}
+
+// poller finds changes by stamping the watched paths every interval. Only the paths
+// are checked, without reading configs or directories, so a tick costs one stat each.
+type poller struct {
+	interval time.Duration
+	mu       sync.Mutex
+	seen     map[string]fileStamp
+	watches  int // how many times watch has been called, so a tick doesn't overwrite newer paths
+	found    chan string
+	done     chan struct{}
+}
+
+// newPoller starts a poller with nothing to watch yet
+func newPoller(interval time.Duration) *poller {
+	p := &poller{interval: interval, found: make(chan string), done: make(chan struct{})}
+	go p.run()
+	return p
+}
+
+func (p *poller) watch(paths map[string]fileStamp) error {
+	p.mu.Lock()
+	p.seen = paths
+	p.watches++
+	p.mu.Unlock()
+	return nil
+}
+
+func (p *poller) changes() <-chan string { return p.found }
+
+func (p *poller) close() { close(p.done) }
+
+// run stamps the paths on every tick, reporting the first that changed
+func (p *poller) run() {
+	ticker := time.NewTicker(p.interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-p.done:
+			return
+		case <-ticker.C:
+		}
+
+		p.mu.Lock()
+		seen, watches := p.seen, p.watches
+		p.mu.Unlock()
+		now := restamp(seen)
+		path, ok := firstChange(seen, now)
+		if !ok {
+			continue
+		}
+		p.mu.Lock()
+		if p.watches == watches {
+			p.seen = now
+		}
+		p.mu.Unlock()
+		select {
+		case p.found <- path:
+		case <-p.done:
+			return
+		}
+	}
+}
+
+// restamp stamps the paths of a snapshot again; ones that are gone are left out, and a
+// directory's stamp tells that files were added to or removed from it
+func restamp(seen map[string]fileStamp) map[string]fileStamp {
+	now := make(map[string]fileStamp, len(seen))
+	for path, old := range seen {
+		stat := os.Stat
+		if old.mode&fs.ModeSymlink != 0 {
+			stat = os.Lstat
+		}
+		if info, err := stat(path); err == nil {
+			now[path] = fileStamp{info.ModTime(), info.Size(), info.Mode()}
+		}
+	}
+	return now
+}
+
+// fileStamp is what watch compares to tell that a file changed
+type fileStamp struct {
+	modTime time.Time
+	size    int64
+	mode    fs.FileMode
+}
+
+// watchSnapshot stamps every watched path: the configs, the actual paths their entries
+// name with everything under them, and the directories holding those, whose own stamps
+// change when a file is added to or removed from them. It reads the configs, so it is
+// only taken when they have been applied.
+func watchSnapshot(configs []string) map[string]fileStamp {
+	stamps := map[string]fileStamp{}
+	stampPath := func(path string) {
+		if info, err := os.Stat(path); err == nil {
+			stamps[path] = fileStamp{info.ModTime(), info.Size(), info.Mode()}
+		}
+	}
+	walk := func(root string) {
+		filepath.WalkDir(root, func(path string, d fs.DirEntry, err error) error {
+			if err != nil {
+				return nil
+			}
+			if d.IsDir() && d.Name() == ".git" {
+				return filepath.SkipDir
+			}
+			if info, err := d.Info(); err == nil {
+				stamps[path] = fileStamp{info.ModTime(), info.Size(), info.Mode()}
+			}
+			return nil
+		})
+	}
+
+	for _, config := range configs {
+		// A remote config would be downloaded on every check, and stdin can't change;
+		// they are only applied at the start
+		if isRemoteConfig(config) || config == stdinConfig {
+			continue
+		}
+		config = absPath(config)
+		stampPath(config)
+		stampPath(filepath.Dir(config))
+
+		// Read quietly: a broken config is reported when it's applied
+		entries, _, err := readConfig(config)
+		if err != nil {
+			continue
+		}
+		for _, e := range entries {
+			target := absPath(underRoot(*rootDir, e.target))
+			if _, seen := stamps[target]; !seen {
+				walk(target)
+				stampPath(filepath.Dir(target))
+			}
+		}
+	}
+	return stamps
+}
+
+// firstChange returns a path that was added, removed or changed between two snapshots
+func firstChange(before, after map[string]fileStamp) (string, bool) {
+	for path, stamp := range after {
+		if old, ok := before[path]; !ok || old != stamp {
+			return path, true
+		}
+	}
+	for path := range before {
+		if _, ok := after[path]; !ok {
+			return path, true
+		}
+	}
+	return "", false
+}
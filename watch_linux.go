@@ -0,0 +1,131 @@
+package main
+
+import (
+	"encoding/binary"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"syscall"
+)
+
+// inotifyMask is what watch is told about in each watched directory
+const inotifyMask = syscall.IN_MODIFY | syscall.IN_ATTRIB | syscall.IN_CLOSE_WRITE | syscall.IN_CREATE |
+	syscall.IN_DELETE | syscall.IN_MOVED_FROM | syscall.IN_MOVED_TO | syscall.IN_DELETE_SELF | syscall.IN_MOVE_SELF
+
+// inotifyAdded are the events that add or remove a directory's files, which count
+// wherever they happen, as the polling stamps of directories do
+const inotifyAdded = syscall.IN_CREATE | syscall.IN_DELETE | syscall.IN_MOVED_FROM | syscall.IN_MOVED_TO |
+	syscall.IN_DELETE_SELF | syscall.IN_MOVE_SELF
+
+// inotifier is told of changes by inotify, in every directory among the watched paths
+type inotifier struct {
+	fd    int
+	file  *os.File // fd, read through the runtime's poller so close stops the reader
+	mu    sync.Mutex
+	dirs  map[int32]string // by watch descriptor
+	wds   map[string]int32
+	paths map[string]fileStamp
+	found chan string
+	done  chan struct{}
+}
+
+// newSystemNotifier starts an inotify instance with nothing to watch yet
+func newSystemNotifier() (notifier, error) {
+	fd, err := syscall.InotifyInit1(syscall.IN_CLOEXEC | syscall.IN_NONBLOCK)
+	if err != nil {
+		return nil, os.NewSyscallError("inotify_init1", err)
+	}
+	n := &inotifier{fd: fd, file: os.NewFile(uintptr(fd), "inotify"), dirs: map[int32]string{}, wds: map[string]int32{}, found: make(chan string), done: make(chan struct{})}
+	go n.read()
+	return n, nil
+}
+
+// watch watches the directories among paths, dropping the ones no longer among them
+func (n *inotifier) watch(paths map[string]fileStamp) error {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	n.paths = paths
+	for path, stamp := range paths {
+		if !stamp.mode.IsDir() {
+			continue
+		}
+		if _, ok := n.wds[path]; ok {
+			continue
+		}
+		wd, err := syscall.InotifyAddWatch(n.fd, path, inotifyMask)
+		if errors.Is(err, syscall.ENOENT) {
+			continue
+		}
+		if errors.Is(err, syscall.ENOSPC) {
+			return errors.New("out of inotify watches (raise fs.inotify.max_user_watches)")
+		}
+		if err != nil {
+			return os.NewSyscallError("inotify_add_watch", err)
+		}
+		n.dirs[int32(wd)], n.wds[path] = path, int32(wd)
+	}
+	for path, wd := range n.wds {
+		if _, ok := paths[path]; !ok {
+			syscall.InotifyRmWatch(n.fd, uint32(wd))
+			delete(n.wds, path)
+			delete(n.dirs, wd)
+		}
+	}
+	return nil
+}
+
+func (n *inotifier) changes() <-chan string { return n.found }
+
+func (n *inotifier) close() {
+	close(n.done)
+	n.file.Close()
+}
+
+// read passes on the events that concern watched paths until the instance is closed
+func (n *inotifier) read() {
+	buf := make([]byte, 64<<10)
+	for {
+		size, err := n.file.Read(buf)
+		if err != nil {
+			return
+		}
+		// Each event is a wd, mask, cookie and name length, then the name padded with NULs
+		for off := 0; off+syscall.SizeofInotifyEvent <= size; {
+			wd := int32(binary.NativeEndian.Uint32(buf[off:]))
+			mask := binary.NativeEndian.Uint32(buf[off+4:])
+			nameLen := int(binary.NativeEndian.Uint32(buf[off+12:]))
+			name := strings.TrimRight(string(buf[off+syscall.SizeofInotifyEvent:off+syscall.SizeofInotifyEvent+nameLen]), "\x00")
+			off += syscall.SizeofInotifyEvent + nameLen
+
+			if path, ok := n.concerns(wd, mask, name); ok {
+				select {
+				case n.found <- path:
+				case <-n.done:
+					return
+				}
+			}
+		}
+	}
+}
+
+// concerns reports whether an event is about a watched path, or adds or removes files
+// in a watched directory, and which path it is about
+func (n *inotifier) concerns(wd int32, mask uint32, name string) (string, bool) {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	if mask&syscall.IN_Q_OVERFLOW != 0 {
+		return "(too many changes to tell)", true
+	}
+	dir, ok := n.dirs[wd]
+	if !ok || mask&syscall.IN_IGNORED != 0 {
+		return "", false
+	}
+	path := dir
+	if name != "" {
+		path = filepath.Join(dir, name)
+	}
+	_, watched := n.paths[path]
+	return path, watched || mask&inotifyAdded != 0
+}
@@ -0,0 +1,14 @@
+//go:build !linux
+
+package main
+
+import (
+	"errors"
+	"fmt"
+	"runtime"
+)
+
+// newSystemNotifier is only implemented with inotify; elsewhere watch polls
+func newSystemNotifier() (notifier, error) {
+	return nil, fmt.Errorf("file notifications on %s: %w", runtime.GOOS, errors.ErrUnsupported)
+}
@@ -0,0 +1,80 @@
+package main
+
+import (
+	"bufio"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"runtime"
+	"strings"
+	"sync"
+)
+
+// isWSL reports whether symlinker is running inside the Windows Subsystem for Linux
+var isWSL = sync.OnceValue(func() bool {
+	if runtime.GOOS != "linux" {
+		return false
+	}
+	if os.Getenv("WSL_DISTRO_NAME") != "" {
+		return true
+	}
+	version, err := os.ReadFile("/proc/sys/kernel/osrelease")
+	return err == nil && strings.Contains(strings.ToLower(string(version)), "microsoft")
+})
+
+// wslMountRoot returns where Windows drives are mounted, honoring [automount] root in /etc/wsl.conf
+func wslMountRoot() string {
+	root := "/mnt/"
+	file, err := os.Open("/etc/wsl.conf")
+	if err != nil {
+		return root
+	}
+	defer file.Close()
+
+	section := ""
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if strings.HasPrefix(line, "[") && strings.HasSuffix(line, "]") {
+			section = strings.ToLower(strings.Trim(line, "[]"))
+			continue
+		}
+		key, value, ok := strings.Cut(line, "=")
+		if ok && section == "automount" && strings.TrimSpace(key) == "root" {
+			root = strings.Trim(strings.TrimSpace(value), `"`)
+		}
+	}
+	if !strings.HasSuffix(root, "/") {
+		root += "/"
+	}
+	return root
+}
+
+// A Windows drive path such as C:\Users\me or C:/Users/me
+var windowsPathRegex = regexp.MustCompile(`^([A-Za-z]):([\\/].*)?$`)
+
+// wslPath converts a Windows drive path to where WSL mounts it, like wslpath -u
+func wslPath(path string) (string, bool) {
+	match := windowsPathRegex.FindStringSubmatch(path)
+	if match == nil {
+		return path, false
+	}
+	rest := strings.ReplaceAll(match[2], `\`, "/")
+	return filepath.Join(wslMountRoot()+strings.ToLower(match[1]), rest), true
+}
+
+// setWSLEnv sets WIN_HOME to the Windows user's profile directory, as seen from WSL,
+// unless it is already set
+func setWSLEnv() {
+	if !isWSL() || os.Getenv("WIN_HOME") != "" {
+		return
+	}
+	out, err := exec.Command("cmd.exe", "/c", "echo %USERPROFILE%").Output()
+	if err != nil {
+		return
+	}
+	if home, ok := wslPath(strings.TrimSpace(string(out))); ok {
+		os.Setenv("WIN_HOME", home)
+	}
+}
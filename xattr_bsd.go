@@ -0,0 +1,17 @@
+//go:build dragonfly || freebsd || netbsd || openbsd
+
+package main
+
+import (
+	"os"
+	"syscall"
+)
+
+// copyMetadata copies the BSD file flags of src to dst
+func copyMetadata(src, dst string, info os.FileInfo) error {
+	stat, ok := info.Sys().(*syscall.Stat_t)
+	if !ok || stat.Flags == 0 || info.Mode()&os.ModeSymlink != 0 {
+		return nil
+	}
+	return syscall.Chflags(dst, int(stat.Flags))
+}
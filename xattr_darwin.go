@@ -0,0 +1,127 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"os"
+	"syscall"
+	"unsafe"
+)
+
+// xattrNoFollow is XATTR_NOFOLLOW: act on a symlink itself rather than its target
+const xattrNoFollow = 0x0001
+
+// copyMetadata copies the extended attributes (such as com.apple.quarantine) and the
+// BSD file flags of src to dst. Attributes the run has no privilege to set, such as
+// com.apple.rootless, are skipped with a warning.
+func copyMetadata(src, dst string, info os.FileInfo) error {
+	var firstErr error
+	names, err := xattrList(src)
+	if err != nil {
+		firstErr = ignoreUnsupported(err)
+	}
+	for _, name := range names {
+		value, err := xattrGet(src, name)
+		if err != nil {
+			continue
+		}
+		err = xattrSet(dst, name, value)
+		switch {
+		case errors.Is(err, syscall.EPERM) || errors.Is(err, syscall.EACCES):
+			warnf("can't copy extended attribute %s to %s: %s\n", name, dst, err)
+		case ignoreUnsupported(err) != nil && firstErr == nil:
+			firstErr = &os.PathError{Op: "setxattr " + name, Path: dst, Err: err}
+		}
+	}
+
+	// Flags such as uchg go last, since they can stop further changes
+	if stat, ok := info.Sys().(*syscall.Stat_t); ok && stat.Flags != 0 && info.Mode()&os.ModeSymlink == 0 {
+		if err := syscall.Chflags(dst, int(stat.Flags)); err != nil && firstErr == nil {
+			firstErr = &os.PathError{Op: "chflags", Path: dst, Err: err}
+		}
+	}
+	return firstErr
+}
+
+// xattrList returns the names of path's extended attributes
+func xattrList(path string) ([]string, error) {
+	p, err := syscall.BytePtrFromString(path)
+	if err != nil {
+		return nil, err
+	}
+	size, _, errno := syscall.Syscall6(syscall.SYS_LISTXATTR, uintptr(unsafe.Pointer(p)), 0, 0, xattrNoFollow, 0, 0)
+	if errno != 0 || size == 0 {
+		return nil, errnoErr(errno)
+	}
+	buf := make([]byte, size)
+	size, _, errno = syscall.Syscall6(syscall.SYS_LISTXATTR, uintptr(unsafe.Pointer(p)), uintptr(unsafe.Pointer(&buf[0])), size, xattrNoFollow, 0, 0)
+	if errno != 0 {
+		return nil, errno
+	}
+
+	var names []string
+	for _, name := range bytes.Split(buf[:size], []byte{0}) {
+		if len(name) > 0 {
+			names = append(names, string(name))
+		}
+	}
+	return names, nil
+}
+
+// xattrGet returns the value of one extended attribute
+func xattrGet(path, name string) ([]byte, error) {
+	p, err := syscall.BytePtrFromString(path)
+	if err != nil {
+		return nil, err
+	}
+	n, err := syscall.BytePtrFromString(name)
+	if err != nil {
+		return nil, err
+	}
+	size, _, errno := syscall.Syscall6(syscall.SYS_GETXATTR, uintptr(unsafe.Pointer(p)), uintptr(unsafe.Pointer(n)), 0, 0, 0, xattrNoFollow)
+	if errno != 0 || size == 0 {
+		return nil, errnoErr(errno)
+	}
+	value := make([]byte, size)
+	size, _, errno = syscall.Syscall6(syscall.SYS_GETXATTR, uintptr(unsafe.Pointer(p)), uintptr(unsafe.Pointer(n)), uintptr(unsafe.Pointer(&value[0])), size, 0, xattrNoFollow)
+	if errno != 0 {
+		return nil, errno
+	}
+	return value[:size], nil
+}
+
+// xattrSet sets one extended attribute
+func xattrSet(path, name string, value []byte) error {
+	p, err := syscall.BytePtrFromString(path)
+	if err != nil {
+		return err
+	}
+	n, err := syscall.BytePtrFromString(name)
+	if err != nil {
+		return err
+	}
+	var v unsafe.Pointer
+	if len(value) > 0 {
+		v = unsafe.Pointer(&value[0])
+	}
+	if _, _, errno := syscall.Syscall6(syscall.SYS_SETXATTR, uintptr(unsafe.Pointer(p)), uintptr(unsafe.Pointer(n)), uintptr(v), uintptr(len(value)), 0, xattrNoFollow); errno != 0 {
+		return errno
+	}
+	return nil
+}
+
+// errnoErr turns a zero errno into a nil error
+func errnoErr(errno syscall.Errno) error {
+	if errno == 0 {
+		return nil
+	}
+	return errno
+}
+
+// ignoreUnsupported drops the error a filesystem without extended attribute support returns
+func ignoreUnsupported(err error) error {
+	if errors.Is(err, syscall.ENOTSUP) {
+		return nil
+	}
+	return err
+}
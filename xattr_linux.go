@@ -0,0 +1,60 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"os"
+	"syscall"
+)
+
+// copyMetadata copies the extended attributes of src to dst, which covers POSIX ACLs
+// (system.posix_acl_*) and SELinux contexts (security.selinux). Attributes the
+// destination filesystem can't hold are skipped, and those in namespaces the run has
+// no privilege to set, such as trusted.* without root, are skipped with a warning.
+func copyMetadata(src, dst string, info os.FileInfo) error {
+	// The xattr calls follow symlinks, so a symlink's own attributes can't be copied
+	if info.Mode()&os.ModeSymlink != 0 {
+		return nil
+	}
+
+	size, err := syscall.Listxattr(src, nil)
+	if err != nil || size == 0 {
+		return ignoreUnsupported(err)
+	}
+	buf := make([]byte, size)
+	if size, err = syscall.Listxattr(src, buf); err != nil {
+		return ignoreUnsupported(err)
+	}
+
+	var firstErr error
+	for _, name := range bytes.Split(buf[:size], []byte{0}) {
+		if len(name) == 0 {
+			continue
+		}
+		attr := string(name)
+		valueSize, err := syscall.Getxattr(src, attr, nil)
+		if err != nil {
+			continue
+		}
+		value := make([]byte, valueSize)
+		if valueSize, err = syscall.Getxattr(src, attr, value); err != nil {
+			continue
+		}
+		err = syscall.Setxattr(dst, attr, value[:valueSize], 0)
+		switch {
+		case errors.Is(err, syscall.EPERM) || errors.Is(err, syscall.EACCES):
+			warnf("can't copy extended attribute %s to %s: %s\n", attr, dst, err)
+		case ignoreUnsupported(err) != nil && firstErr == nil:
+			firstErr = &os.PathError{Op: "setxattr " + attr, Path: dst, Err: err}
+		}
+	}
+	return firstErr
+}
+
+// ignoreUnsupported drops the error a filesystem without extended attribute support returns
+func ignoreUnsupported(err error) error {
+	if errors.Is(err, syscall.ENOTSUP) || errors.Is(err, syscall.EOPNOTSUPP) {
+		return nil
+	}
+	return err
+}
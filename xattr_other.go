@@ -0,0 +1,10 @@
+//go:build !(darwin || dragonfly || freebsd || linux || netbsd || openbsd)
+
+package main
+
+import "os"
+
+// copyMetadata has nothing beyond permissions to copy here
+func copyMetadata(src, dst string, info os.FileInfo) error {
+	return nil
+}
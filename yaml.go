@@ -0,0 +1,502 @@
+package main
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+// yamlLine is a non-blank, comment-stripped source line
+type yamlLine struct {
+	indent int
+	text   string
+	num    int
+}
+
+// yamlParser parses the block-style subset of YAML used by dotfile manager configs:
+// mappings, sequences, quoted and plain scalars, flow collections and block scalars.
+// Anchors, tags and multi-document streams are not supported.
+type yamlParser struct {
+	lines []yamlLine
+	raw   []string
+	pos   int
+}
+
+// parseYAML parses a YAML document into a node tree
+func parseYAML(data []byte) (*node, error) {
+	p := &yamlParser{raw: strings.Split(strings.ReplaceAll(string(data), "\r\n", "\n"), "\n")}
+	for i, raw := range p.raw {
+		text := strings.TrimRight(stripYAMLComment(raw), " \t")
+		trimmed := strings.TrimLeft(text, " ")
+		if trimmed == "" || trimmed == "---" {
+			continue
+		}
+		if strings.HasPrefix(trimmed, "\t") {
+			return nil, fmt.Errorf("line %d: tabs are not allowed for indentation", i+1)
+		}
+		p.lines = append(p.lines, yamlLine{indent: len(text) - len(trimmed), text: trimmed, num: i + 1})
+	}
+
+	if len(p.lines) == 0 {
+		return &node{kind: scalarNode, null: true, line: 1}, nil
+	}
+	n, err := p.parseBlock(p.lines[0].indent)
+	if err != nil {
+		return nil, err
+	}
+	if p.pos < len(p.lines) {
+		return nil, fmt.Errorf("line %d: unexpected content: %s", p.lines[p.pos].num, p.lines[p.pos].text)
+	}
+	return n, nil
+}
+
+// parseBlock parses the block node starting at the current line
+func (p *yamlParser) parseBlock(indent int) (*node, error) {
+	l := p.lines[p.pos]
+	if l.text == "-" || strings.HasPrefix(l.text, "- ") {
+		return p.parseSequence(indent)
+	}
+	if _, _, ok := splitYAMLKey(l.text); ok {
+		return p.parseMapping(indent)
+	}
+	p.pos++
+	return parseYAMLScalar(l.text, l.num, l.indent+1)
+}
+
+// parseSequence parses block sequence items at the given indent
+func (p *yamlParser) parseSequence(indent int) (*node, error) {
+	seq := &node{kind: sequenceNode, line: p.lines[p.pos].num, col: indent + 1}
+	for p.pos < len(p.lines) {
+		l := p.lines[p.pos]
+		if l.indent != indent || !(l.text == "-" || strings.HasPrefix(l.text, "- ")) {
+			break
+		}
+
+		rest := strings.TrimLeft(strings.TrimPrefix(l.text, "-"), " ")
+		if rest == "" {
+			// Item content is on the following, more indented lines
+			p.pos++
+			item, err := p.parseNested(indent, l.num)
+			if err != nil {
+				return nil, err
+			}
+			seq.items = append(seq.items, item)
+			continue
+		}
+
+		// Treat the item content as if it started its own block at its column
+		p.lines[p.pos] = yamlLine{indent: indent + len(l.text) - len(rest), text: rest, num: l.num}
+		item, err := p.parseBlock(p.lines[p.pos].indent)
+		if err != nil {
+			return nil, err
+		}
+		seq.items = append(seq.items, item)
+	}
+	return seq, nil
+}
+
+// parseMapping parses block mapping keys at the given indent
+func (p *yamlParser) parseMapping(indent int) (*node, error) {
+	m := &node{kind: mappingNode, line: p.lines[p.pos].num, col: indent + 1}
+	for p.pos < len(p.lines) {
+		l := p.lines[p.pos]
+		if l.indent != indent {
+			if l.indent > indent {
+				return nil, fmt.Errorf("line %d: unexpected indentation", l.num)
+			}
+			break
+		}
+
+		rawKey, rest, ok := splitYAMLKey(l.text)
+		if !ok {
+			return nil, fmt.Errorf("line %d: expected a mapping key: %s", l.num, l.text)
+		}
+		key, err := parseYAMLScalar(rawKey, l.num, indent+1)
+		if err != nil {
+			return nil, err
+		}
+		p.pos++
+
+		var val *node
+		switch {
+		case rest == "":
+			// A sequence may sit at the same indent as its key
+			if p.pos < len(p.lines) && p.lines[p.pos].indent == indent && strings.HasPrefix(p.lines[p.pos].text, "-") {
+				val, err = p.parseSequence(indent)
+			} else {
+				val, err = p.parseNested(indent, l.num)
+			}
+		case strings.HasPrefix(rest, "|") || strings.HasPrefix(rest, ">"):
+			val, err = p.parseBlockScalar(indent, rest, l.num)
+		default:
+			val, err = parseYAMLScalar(rest, l.num, indent+1+utf8.RuneCountInString(l.text[:len(l.text)-len(rest)]))
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		m.keys = append(m.keys, key)
+		m.vals = append(m.vals, val)
+	}
+	return m, nil
+}
+
+// parseNested parses a block more indented than parent, or returns null if there is none
+func (p *yamlParser) parseNested(parent, line int) (*node, error) {
+	if p.pos >= len(p.lines) || p.lines[p.pos].indent <= parent {
+		return &node{kind: scalarNode, null: true, line: line}, nil
+	}
+	return p.parseBlock(p.lines[p.pos].indent)
+}
+
+// parseBlockScalar reads a literal (|) or folded (>) scalar from the raw source lines.
+// The header may give the content's indentation as a digit, and a chomping indicator:
+// - drops the final line break, + keeps the trailing blank lines as well.
+func (p *yamlParser) parseBlockScalar(parent int, header string, line int) (*node, error) {
+	var chomp byte
+	indent := -1
+	for _, c := range []byte(header[1:]) {
+		switch {
+		case (c == '-' || c == '+') && chomp == 0:
+			chomp = c
+		case c >= '1' && c <= '9' && indent < 0:
+			indent = parent + int(c-'0')
+		default:
+			return nil, fmt.Errorf("line %d: invalid block scalar header: %s", line, header)
+		}
+	}
+
+	for p.pos < len(p.lines) && p.lines[p.pos].indent > parent {
+		p.pos++
+	}
+	end := len(p.raw)
+	if p.pos < len(p.lines) {
+		end = p.lines[p.pos].num - 1
+	}
+
+	// Take the raw lines between the header and the next block line, up to a comment
+	// indented less than the content
+	var body []string
+	for _, raw := range p.raw[line:end] {
+		trimmed := strings.TrimLeft(raw, " ")
+		if trimmed == "" {
+			body = append(body, "")
+			continue
+		}
+		lead := len(raw) - len(trimmed)
+		if indent < 0 && lead > parent {
+			indent = lead
+		}
+		if lead < indent || indent < 0 {
+			break
+		}
+		body = append(body, raw[indent:])
+	}
+	trailing := 0
+	for len(body) > 0 && body[len(body)-1] == "" {
+		body = body[:len(body)-1]
+		trailing++
+	}
+
+	var value string
+	if header[0] == '>' {
+		value = foldYAMLLines(body)
+	} else {
+		value = strings.Join(body, "\n")
+	}
+	switch {
+	case chomp == '+':
+		value += strings.Repeat("\n", trailing+1)
+	case chomp != '-' && len(body) > 0:
+		value += "\n"
+	}
+	return &node{kind: scalarNode, value: value, line: line}, nil
+}
+
+// foldYAMLLines joins the lines of a folded scalar: the line break between two text
+// lines becomes a space, or is dropped when blank lines follow it, each of which stands
+// for a line break. Breaks next to a more indented line are kept, as in YAML.
+func foldYAMLLines(lines []string) string {
+	var b strings.Builder
+	prev := -1 // the last text line
+	for i, l := range lines {
+		if l == "" {
+			continue
+		}
+		breaks := i - prev - 1
+		switch {
+		case prev < 0:
+			breaks = i
+		case strings.HasPrefix(l, " ") || strings.HasPrefix(lines[prev], " "):
+			breaks++
+		case breaks == 0:
+			b.WriteByte(' ')
+		}
+		b.WriteString(strings.Repeat("\n", breaks))
+		b.WriteString(l)
+		prev = i
+	}
+	return b.String()
+}
+
+// parseYAMLScalar parses an inline value starting at column col: a quoted or plain scalar, or a flow collection
+func parseYAMLScalar(text string, line, col int) (*node, error) {
+	f := &yamlFlow{text: text, line: line, col: col}
+	n, err := f.value()
+	if err != nil {
+		return nil, err
+	}
+	f.skipSpace()
+	if f.pos < len(f.text) {
+		return nil, fmt.Errorf("line %d: unexpected characters after value: %s", line, f.text[f.pos:])
+	}
+	return n, nil
+}
+
+// yamlFlow parses flow-style values within a single line
+type yamlFlow struct {
+	text string
+	pos  int
+	line int
+	col  int // column of text[0]
+	nest int
+}
+
+// column returns the source column of a position in the text
+func (f *yamlFlow) column(pos int) int {
+	return f.col + utf8.RuneCountInString(f.text[:pos])
+}
+
+func (f *yamlFlow) skipSpace() {
+	for f.pos < len(f.text) && (f.text[f.pos] == ' ' || f.text[f.pos] == '\t') {
+		f.pos++
+	}
+}
+
+func (f *yamlFlow) value() (*node, error) {
+	f.skipSpace()
+	col := f.column(f.pos)
+	if f.pos >= len(f.text) {
+		return &node{kind: scalarNode, null: true, line: f.line, col: col}, nil
+	}
+
+	switch f.text[f.pos] {
+	case '[':
+		f.pos++
+		f.nest++
+		seq := &node{kind: sequenceNode, line: f.line, col: col}
+		for {
+			f.skipSpace()
+			if f.pos < len(f.text) && f.text[f.pos] == ']' {
+				f.pos++
+				f.nest--
+				return seq, nil
+			}
+			item, err := f.value()
+			if err != nil {
+				return nil, err
+			}
+			seq.items = append(seq.items, item)
+			if err := f.separator(']'); err != nil {
+				return nil, err
+			}
+		}
+	case '{':
+		f.pos++
+		f.nest++
+		m := &node{kind: mappingNode, line: f.line, col: col}
+		for {
+			f.skipSpace()
+			if f.pos < len(f.text) && f.text[f.pos] == '}' {
+				f.pos++
+				f.nest--
+				return m, nil
+			}
+			key, err := f.value()
+			if err != nil {
+				return nil, err
+			}
+			f.skipSpace()
+			if f.pos >= len(f.text) || f.text[f.pos] != ':' {
+				return nil, fmt.Errorf("line %d: expected ':' in flow mapping", f.line)
+			}
+			f.pos++
+			val, err := f.value()
+			if err != nil {
+				return nil, err
+			}
+			m.keys = append(m.keys, key)
+			m.vals = append(m.vals, val)
+			if err := f.separator('}'); err != nil {
+				return nil, err
+			}
+		}
+	case '\'':
+		end := f.pos + 1
+		var b strings.Builder
+		for {
+			i := strings.IndexByte(f.text[end:], '\'')
+			if i < 0 {
+				return nil, fmt.Errorf("line %d: unterminated single-quoted string", f.line)
+			}
+			b.WriteString(f.text[end : end+i])
+			end += i + 1
+			if end < len(f.text) && f.text[end] == '\'' {
+				b.WriteByte('\'')
+				end++
+				continue
+			}
+			break
+		}
+		f.pos = end
+		return &node{kind: scalarNode, value: b.String(), line: f.line, col: col}, nil
+	case '"':
+		end := f.pos + 1
+		for end < len(f.text) && f.text[end] != '"' {
+			if f.text[end] == '\\' {
+				end++
+			}
+			end++
+		}
+		if end >= len(f.text) {
+			return nil, fmt.Errorf("line %d: unterminated double-quoted string", f.line)
+		}
+		value, err := unquoteYAML(f.text[f.pos+1 : end])
+		if err != nil {
+			return nil, fmt.Errorf("line %d: invalid double-quoted string: %w", f.line, err)
+		}
+		f.pos = end + 1
+		return &node{kind: scalarNode, value: value, line: f.line, col: col}, nil
+	case '&', '*', '!':
+		return nil, fmt.Errorf("line %d: anchors, aliases and tags are not supported: %s", f.line, f.text[f.pos:])
+	}
+
+	// Plain scalar: runs to the end of the line, or to a flow indicator inside a collection
+	start := f.pos
+	for f.pos < len(f.text) {
+		c := f.text[f.pos]
+		if f.nest > 0 && (c == ',' || c == ']' || c == '}') {
+			break
+		}
+		if c == ':' && f.nest > 0 && (f.pos+1 == len(f.text) || f.text[f.pos+1] == ' ') {
+			break
+		}
+		f.pos++
+	}
+	value := strings.TrimSpace(f.text[start:f.pos])
+	return &node{kind: scalarNode, value: value, null: value == "~" || value == "null" || value == "", line: f.line, col: col}, nil
+}
+
+// yamlEscapes maps the single-character escapes of double-quoted YAML scalars
+var yamlEscapes = map[byte]rune{
+	'0': 0, 'a': '\a', 'b': '\b', 't': '\t', '\t': '\t', 'n': '\n', 'v': '\v', 'f': '\f', 'r': '\r',
+	'e': 0x1b, ' ': ' ', '"': '"', '/': '/', '\\': '\\',
+	'N': 0x85, '_': 0xa0, 'L': 0x2028, 'P': 0x2029,
+}
+
+// unquoteYAML decodes the body of a double-quoted scalar. YAML's escapes aren't Go's:
+// it has \e, \N, \_, \L and \P, no octal, and \x names a code point rather than a byte.
+func unquoteYAML(s string) (string, error) {
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		if s[i] != '\\' {
+			b.WriteByte(s[i])
+			continue
+		}
+		i++
+		if i == len(s) {
+			return "", fmt.Errorf("trailing backslash")
+		}
+		if r, ok := yamlEscapes[s[i]]; ok {
+			b.WriteRune(r)
+			continue
+		}
+		digits := map[byte]int{'x': 2, 'u': 4, 'U': 8}[s[i]]
+		if digits == 0 {
+			return "", fmt.Errorf("unknown escape \\%c", s[i])
+		}
+		if i+digits >= len(s) {
+			return "", fmt.Errorf("short escape \\%s", s[i:])
+		}
+		code, err := strconv.ParseUint(s[i+1:i+1+digits], 16, 32)
+		if err != nil || !utf8.ValidRune(rune(code)) {
+			return "", fmt.Errorf("invalid escape \\%s", s[i:i+1+digits])
+		}
+		b.WriteRune(rune(code))
+		i += digits
+	}
+	return b.String(), nil
+}
+
+// separator consumes a ',' between flow items, or leaves the closing bracket in place
+func (f *yamlFlow) separator(closing byte) error {
+	f.skipSpace()
+	if f.pos >= len(f.text) {
+		return fmt.Errorf("line %d: unterminated flow collection", f.line)
+	}
+	switch f.text[f.pos] {
+	case ',':
+		f.pos++
+		return nil
+	case closing:
+		return nil
+	}
+	return fmt.Errorf("line %d: expected ',' or '%c' in flow collection", f.line, closing)
+}
+
+// splitYAMLKey splits "key: value" outside of quotes and flow collections
+func splitYAMLKey(text string) (key, rest string, ok bool) {
+	if text == "" || text[0] == '[' || text[0] == '{' {
+		return "", "", false
+	}
+
+	var quote byte
+	for i := 0; i < len(text); i++ {
+		c := text[i]
+		switch {
+		case quote != 0:
+			switch {
+			case c == '\\' && quote == '"':
+				i++
+			case c == quote && quote == '\'' && i+1 < len(text) && text[i+1] == '\'':
+				i++
+			case c == quote:
+				quote = 0
+			}
+		case c == '\'' || c == '"':
+			if i == 0 {
+				quote = c
+			}
+		case c == ':' && (i+1 == len(text) || text[i+1] == ' '):
+			return strings.TrimSpace(text[:i]), strings.TrimSpace(text[i+1:]), true
+		}
+	}
+	return "", "", false
+}
+
+// stripYAMLComment removes a trailing # comment that isn't inside quotes
+func stripYAMLComment(line string) string {
+	var quote byte
+	for i := 0; i < len(line); i++ {
+		c := line[i]
+		switch {
+		case quote != 0:
+			switch {
+			case c == '\\' && quote == '"':
+				i++
+			case c == quote && quote == '\'' && i+1 < len(line) && line[i+1] == '\'':
+				i++
+			case c == quote:
+				quote = 0
+			}
+		case c == '\'' || c == '"':
+			if i == 0 || line[i-1] == ' ' || line[i-1] == '[' || line[i-1] == '{' || line[i-1] == ',' || line[i-1] == ':' {
+				quote = c
+			}
+		case c == '#' && (i == 0 || line[i-1] == ' ' || line[i-1] == '\t'):
+			return line[:i]
+		}
+	}
+	return line
+}
@@ -0,0 +1,134 @@
+package main
+
+import (
+	"strconv"
+	"strings"
+	"testing"
+)
+
+// nodeString renders a node tree compactly, with scalars quoted and null as null
+func nodeString(n *node) string {
+	switch n.kind {
+	case sequenceNode:
+		items := make([]string, len(n.items))
+		for i, item := range n.items {
+			items[i] = nodeString(item)
+		}
+		return "[" + strings.Join(items, ", ") + "]"
+	case mappingNode:
+		pairs := make([]string, len(n.keys))
+		for i := range n.keys {
+			pairs[i] = nodeString(n.keys[i]) + ": " + nodeString(n.vals[i])
+		}
+		return "{" + strings.Join(pairs, ", ") + "}"
+	}
+	if n.null {
+		return "null"
+	}
+	return strconv.Quote(n.value)
+}
+
+func TestParseYAML(t *testing.T) {
+	tests := []struct {
+		name  string
+		input string
+		want  string
+	}{
+		{"empty", "", `null`},
+		{"document marker", "---\na: 1\n", `{"a": "1"}`},
+		{"mapping", "a: 1\nb: two words\n", `{"a": "1", "b": "two words"}`},
+		{"nested mapping", "a:\n  b: 1\n  c:\n    d: 2\ne: 3\n", `{"a": {"b": "1", "c": {"d": "2"}}, "e": "3"}`},
+		{"nulls", "a:\nb: ~\nc: null\n", `{"a": null, "b": null, "c": null}`},
+		{"sequence", "- a\n- b\n", `["a", "b"]`},
+		{"sequence at the key's indent", "a:\n- x\n- y\nb: 1\n", `{"a": ["x", "y"], "b": "1"}`},
+		{"sequence of mappings", "- link:\n    ~/.vimrc: vimrc\n- clean: ['~']\n", `[{"link": {"~/.vimrc": "vimrc"}}, {"clean": ["~"]}]`},
+		{"comments", "# top\na: 1 # trailing\n  # indented\nb: x#y\n", `{"a": "1", "b": "x#y"}`},
+		{"hash in quotes", "a: 'x # y'\nb: \"p # q\"\n", `{"a": "x # y", "b": "p # q"}`},
+		{"escaped quotes before a hash", "a: \"say \\\"hi\\\" # not a comment\"\nb: 'it''s # here'\n", `{"a": "say \"hi\" # not a comment", "b": "it's # here"}`},
+		{"quoted keys", "\"a: b\": c\n'it''s: x': v\n", `{"a: b": "c", "it's: x": "v"}`},
+		{"single quotes keep backslashes", `a: 'C:\dots\n'`, `{"a": "C:\\dots\\n"}`},
+		{"double-quoted escapes", `a: "\e[0m \x41\u00e9\U0001F600 \t\/\\ \N\_\L\P \0"`, `{"a": "\x1b[0m Aé😀 \t/\\ \u0085\u00a0\u2028\u2029 \x00"}`},
+		{"escaped tab and space", "a: \"x\\\ty\\ \"\n", `{"a": "x\ty "}`},
+		{"flow sequence", "a: [x, 'y, z', \"w\", [1, 2], []]\n", `{"a": ["x", "y, z", "w", ["1", "2"], []]}`},
+		{"flow mapping", "a: {k: v, m: [1], n: }\n", `{"a": {"k": "v", "m": ["1"], "n": null}}`},
+		{"literal", "a: |\n  one\n    two\n\n  three\nb: 1\n", `{"a": "one\n  two\n\nthree\n", "b": "1"}`},
+		{"literal strip", "a: |-\n  one\n  two\n\nb: 1\n", `{"a": "one\ntwo", "b": "1"}`},
+		{"literal keep", "a: |+\n  one\n\n\nb: 1\n", `{"a": "one\n\n\n", "b": "1"}`},
+		{"literal at the end", "a: |\n  one\n  two", `{"a": "one\ntwo\n"}`},
+		{"literal with a comment header", "a: | # note\n  # kept\nb: 1\n", `{"a": "# kept\n", "b": "1"}`},
+		{"literal ended by a comment", "a: |\n  one\n# done\nb: 1\n", `{"a": "one\n", "b": "1"}`},
+		{"literal indentation indicator", "a: |2\n    code\n  text\n", `{"a": "  code\ntext\n"}`},
+		{"empty literal", "a: |\nb: 1\n", `{"a": "", "b": "1"}`},
+		{"folded", "a: >\n  one\n  two\n\n  three\n    indented\n  four\n", `{"a": "one two\nthree\n  indented\nfour\n"}`},
+		{"folded strip", "a: >-\n  one\n  two\n\n\n  three\n", `{"a": "one two\n\nthree"}`},
+		{"block scalar in a nested mapping", "a:\n  b: >\n    x\n    y\n  c: 1\n", `{"a": {"b": "x y\n", "c": "1"}}`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			n, err := parseYAML([]byte(tt.input))
+			if err != nil {
+				t.Fatalf("parseYAML: %v", err)
+			}
+			if got := nodeString(n); got != tt.want {
+				t.Errorf("got %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseYAMLPositions(t *testing.T) {
+	n, err := parseYAML([]byte("a: 1\nb:\n  - x\n  - [y, z]\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		name      string
+		n         *node
+		line, col int
+	}{
+		{"value", n.get("a"), 1, 4},
+		{"sequence", n.get("b"), 3, 3},
+		{"item", n.get("b").items[0], 3, 5},
+		{"flow item", n.get("b").items[1].items[1], 4, 9},
+	}
+	for _, tt := range tests {
+		if tt.n.line != tt.line || tt.n.col != tt.col {
+			t.Errorf("%s: got line %d col %d, want line %d col %d", tt.name, tt.n.line, tt.n.col, tt.line, tt.col)
+		}
+	}
+}
+
+func TestParseYAMLErrors(t *testing.T) {
+	tests := []struct {
+		name  string
+		input string
+		want  string
+	}{
+		{"tab indentation", "a:\n\tb: 1\n", "line 2: tabs are not allowed for indentation"},
+		{"unexpected indentation", "a: 1\n  b: 2\n", "line 2: unexpected indentation"},
+		{"anchor", "a: &x 1\n", "line 1: anchors, aliases and tags are not supported: &x 1"},
+		{"alias", "a: 1\nb:\n- *x\n", "line 3: anchors, aliases and tags are not supported: *x"},
+		{"tag", "a: !!str 1\n", "line 1: anchors, aliases and tags are not supported: !!str 1"},
+		{"anchored key", "&k a: 1\n", "line 1: anchors, aliases and tags are not supported: &k a"},
+		{"anchor in a flow sequence", "a: [1, &x 2]\n", "line 1: anchors, aliases and tags are not supported: &x 2]"},
+		{"unknown escape", `a: "\q"`, `line 1: invalid double-quoted string: unknown escape \q`},
+		{"octal escape", `a: "\101"`, `line 1: invalid double-quoted string: unknown escape \1`},
+		{"short escape", `a: "\x4"`, `line 1: invalid double-quoted string: short escape \x4`},
+		{"invalid code point", `a: "\UFFFFFFFF"`, `line 1: invalid double-quoted string: invalid escape \UFFFFFFFF`},
+		{"unterminated double quote", `a: "abc`, "line 1: unterminated double-quoted string"},
+		{"unterminated single quote", "a: 'abc", "line 1: unterminated single-quoted string"},
+		{"unterminated flow sequence", "a: [1, 2\n", "line 1: unterminated flow collection"},
+		{"flow mapping without a colon", "a: {k}\n", "line 1: expected ':' in flow mapping"},
+		{"trailing characters", "a: 'x' y\n", "line 1: unexpected characters after value: y"},
+		{"block scalar header", "a: |x\n  b\n", "line 1: invalid block scalar header: |x"},
+		{"two chomping indicators", "a: >-+\n  b\n", "line 1: invalid block scalar header: >-+"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := parseYAML([]byte(tt.input))
+			if err == nil || err.Error() != tt.want {
+				t.Errorf("got error %v, want %s", err, tt.want)
+			}
+		})
+	}
+}